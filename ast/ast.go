@@ -48,6 +48,14 @@ func (p *Program) String() string {
 type Identifier struct {
 	Token token.Token
 	Value string
+	// Resolved は resolver が割り当てたローカル変数の位置。nil なら名前で探す
+	Resolved *Resolution
+}
+
+// Resolution は識別子が depth 個外側の関数スコープの slot 番目の変数を指すことを表す
+type Resolution struct {
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expressionNode()      {}
@@ -57,6 +65,8 @@ func (i *Identifier) String() string       { return i.Value }
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
+	// Type は let x: int = 5; の型の注釈。注釈がなければ nil
+	Type  TypeExpression
 	Value Expression
 }
 
@@ -67,6 +77,9 @@ func (ls *LetStatement) String() string {
 
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -214,6 +227,11 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	// ParameterTypes は引数の型の注釈で、Parameters と同じ長さ。注釈のない引数は nil で、
+	// どの引数にも注釈がなければスライスも nil
+	ParameterTypes []TypeExpression
+	// ReturnType は戻り値の型の注釈。注釈がなければ nil
+	ReturnType TypeExpression
 	Body       *BlockStatement
 }
 
@@ -222,14 +240,13 @@ func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	var params []string
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(Parameters(fl.Parameters, fl.ParameterTypes))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -309,6 +326,34 @@ func (i *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression は left[low:high] を表す。Low と High は省略されると nil
+type SliceExpression struct {
+	Token token.Token
+	Left  Expression
+	Low   Expression
+	High  Expression
+}
+
+func (s *SliceExpression) expressionNode()      {}
+func (s *SliceExpression) TokenLiteral() string { return s.Token.Literal }
+func (s *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(s.Left.String())
+	out.WriteString("[")
+	if s.Low != nil {
+		out.WriteString(s.Low.String())
+	}
+	out.WriteString(":")
+	if s.High != nil {
+		out.WriteString(s.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 type HashLiteral struct {
 	Token token.Token
 	Pairs []HashPair
@@ -358,3 +403,46 @@ func (ml *MacroLiteral) String() string {
 
 	return out.String()
 }
+
+// ImportStatement は import "path" または import { a, b } from "path" を表す。
+// Names が nil のときはモジュールが export したものをすべて取り込む
+type ImportStatement struct {
+	Token token.Token
+	Names []*Identifier
+	Path  *StringLiteral
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(is.TokenLiteral() + " ")
+
+	if is.Names != nil {
+		var names []string
+		for _, n := range is.Names {
+			names = append(names, n.String())
+		}
+		out.WriteString("{ ")
+		out.WriteString(strings.Join(names, ", "))
+		out.WriteString(" } from ")
+	}
+
+	out.WriteString(`"` + is.Path.Value + `"`)
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// ExportStatement は export let name = value; を表す
+type ExportStatement struct {
+	Token token.Token
+	Let   *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Let.String()
+}
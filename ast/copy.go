@@ -0,0 +1,179 @@
+package ast
+
+// Copy は node の深い複製を返す。複製を書き換えても node には影響しない。
+// トークンと resolver の結果もそのまま複製する
+func Copy(node Node) Node {
+	switch node := node.(type) {
+	case *Program:
+		return &Program{Statements: copyStatements(node.Statements)}
+	case *LetStatement:
+		return copyLet(node)
+	case *ReturnStatement:
+		return &ReturnStatement{Token: node.Token, ReturnValue: copyExpression(node.ReturnValue)}
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: node.Token, Expression: copyExpression(node.Expression)}
+	case *BlockStatement:
+		return copyBlock(node)
+	case *ImportStatement:
+		stmt := &ImportStatement{Token: node.Token, Names: copyIdentifiers(node.Names)}
+		if node.Path != nil {
+			stmt.Path = &StringLiteral{Token: node.Path.Token, Value: node.Path.Value}
+		}
+		return stmt
+	case *ExportStatement:
+		return &ExportStatement{Token: node.Token, Let: copyLet(node.Let)}
+	case *Identifier:
+		return copyIdentifier(node)
+	case *IntegerLiteral:
+		return &IntegerLiteral{Token: node.Token, Value: node.Value}
+	case *Boolean:
+		return &Boolean{Token: node.Token, Value: node.Value}
+	case *StringLiteral:
+		return &StringLiteral{Token: node.Token, Value: node.Value}
+	case *PrefixExpression:
+		return &PrefixExpression{Token: node.Token, Operator: node.Operator, Right: copyExpression(node.Right)}
+	case *InfixExpression:
+		return &InfixExpression{
+			Token:    node.Token,
+			Left:     copyExpression(node.Left),
+			Operator: node.Operator,
+			Right:    copyExpression(node.Right),
+		}
+	case *IfExpression:
+		return &IfExpression{
+			Token:       node.Token,
+			Condition:   copyExpression(node.Condition),
+			Consequence: copyBlock(node.Consequence),
+			Alternative: copyBlock(node.Alternative),
+		}
+	case *FunctionLiteral:
+		return &FunctionLiteral{
+			Token:          node.Token,
+			Parameters:     copyIdentifiers(node.Parameters),
+			ParameterTypes: copyTypes(node.ParameterTypes),
+			ReturnType:     copyType(node.ReturnType),
+			Body:           copyBlock(node.Body),
+		}
+	case *MacroLiteral:
+		return &MacroLiteral{Token: node.Token, Parameters: copyIdentifiers(node.Parameters), Body: copyBlock(node.Body)}
+	case *CallExpression:
+		return &CallExpression{Token: node.Token, Function: copyExpression(node.Function), Arguments: copyExpressions(node.Arguments)}
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: node.Token, Elements: copyExpressions(node.Elements)}
+	case *IndexExpression:
+		return &IndexExpression{Token: node.Token, Left: copyExpression(node.Left), Index: copyExpression(node.Index)}
+	case *SliceExpression:
+		return &SliceExpression{
+			Token: node.Token,
+			Left:  copyExpression(node.Left),
+			Low:   copyExpression(node.Low),
+			High:  copyExpression(node.High),
+		}
+	case *HashLiteral:
+		var pairs []HashPair
+		if node.Pairs != nil {
+			pairs = make([]HashPair, len(node.Pairs))
+			for i, pair := range node.Pairs {
+				pairs[i] = HashPair{Key: copyExpression(pair.Key), Value: copyExpression(pair.Value)}
+			}
+		}
+		return &HashLiteral{Token: node.Token, Pairs: pairs}
+	case *NamedType:
+		return &NamedType{Token: node.Token, Name: node.Name}
+	case *ArrayType:
+		return &ArrayType{Token: node.Token, Elem: copyType(node.Elem)}
+	case *HashType:
+		return &HashType{Token: node.Token, Key: copyType(node.Key), Value: copyType(node.Value)}
+	case *FunctionType:
+		return &FunctionType{Token: node.Token, Params: copyTypes(node.Params), Return: copyType(node.Return)}
+	}
+	return node
+}
+
+func copyExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	return Copy(exp).(Expression)
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	copied := &Identifier{Token: ident.Token, Value: ident.Value}
+	if ident.Resolved != nil {
+		resolved := *ident.Resolved
+		copied.Resolved = &resolved
+	}
+	return copied
+}
+
+func copyLet(let *LetStatement) *LetStatement {
+	if let == nil {
+		return nil
+	}
+	return &LetStatement{Token: let.Token, Name: copyIdentifier(let.Name), Type: copyType(let.Type), Value: copyExpression(let.Value)}
+}
+
+func copyType(t TypeExpression) TypeExpression {
+	if t == nil {
+		return nil
+	}
+	return Copy(t).(TypeExpression)
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return &BlockStatement{Token: block.Token, Statements: copyStatements(block.Statements)}
+}
+
+// 以下の関数は nil と空のスライスを区別したまま複製する
+
+func copyStatements(statements []Statement) []Statement {
+	if statements == nil {
+		return nil
+	}
+	copied := make([]Statement, len(statements))
+	for i, stmt := range statements {
+		if stmt != nil {
+			copied[i] = Copy(stmt).(Statement)
+		}
+	}
+	return copied
+}
+
+func copyExpressions(expressions []Expression) []Expression {
+	if expressions == nil {
+		return nil
+	}
+	copied := make([]Expression, len(expressions))
+	for i, exp := range expressions {
+		copied[i] = copyExpression(exp)
+	}
+	return copied
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	copied := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		copied[i] = copyIdentifier(ident)
+	}
+	return copied
+}
+
+func copyTypes(types []TypeExpression) []TypeExpression {
+	if types == nil {
+		return nil
+	}
+	copied := make([]TypeExpression, len(types))
+	for i, t := range types {
+		copied[i] = copyType(t)
+	}
+	return copied
+}
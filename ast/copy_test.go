@@ -0,0 +1,78 @@
+package ast
+
+import (
+	"github.com/care0717/monkey-interpreter/token"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	build := func() Node {
+		ident := func(name string) *Identifier {
+			return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Pos: token.Position{Line: 1, Column: 1}}, Value: name}
+		}
+		param := ident("x")
+		param.Resolved = &Resolution{Depth: 0, Slot: 0}
+		return &Program{Statements: []Statement{
+			&ImportStatement{Names: []*Identifier{ident("lib")}, Path: &StringLiteral{Value: "lib"}},
+			&ImportStatement{Path: &StringLiteral{Value: "all"}},
+			&ExportStatement{Let: &LetStatement{Name: ident("f"), Value: &FunctionLiteral{
+				Parameters:     []*Identifier{param},
+				ParameterTypes: []TypeExpression{&ArrayType{Elem: &NamedType{Name: "int"}}},
+				ReturnType: &FunctionType{
+					Params: []TypeExpression{&HashType{Key: &NamedType{Name: "string"}, Value: &NamedType{Name: "any"}}},
+					Return: &NamedType{Name: "bool"},
+				},
+				Body: &BlockStatement{Statements: []Statement{
+					&ReturnStatement{ReturnValue: &PrefixExpression{Operator: "-", Right: param}},
+				}},
+			}}},
+			&LetStatement{Name: ident("n"), Type: &NamedType{Name: "int"}, Value: &IntegerLiteral{Value: 1}},
+			&LetStatement{Name: ident("m"), Value: &MacroLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{}}},
+			&ExpressionStatement{Expression: &IfExpression{
+				Condition:   &Boolean{Value: true},
+				Consequence: &BlockStatement{Statements: []Statement{}},
+			}},
+			&ExpressionStatement{Expression: &CallExpression{Function: ident("f"), Arguments: []Expression{
+				&InfixExpression{Left: &IntegerLiteral{Value: 1}, Operator: "+", Right: &IntegerLiteral{Value: 2}},
+				&ArrayLiteral{Elements: []Expression{}},
+				&IndexExpression{Left: ident("a"), Index: &IntegerLiteral{Value: 0}},
+				&SliceExpression{Left: ident("a"), High: &IntegerLiteral{Value: 1}},
+				&HashLiteral{Pairs: []HashPair{{Key: &StringLiteral{Value: "k"}, Value: ident("v")}}},
+			}}},
+		}}
+	}
+
+	original := build()
+	copied := Copy(original)
+	if !cmp.Equal(copied, build()) {
+		t.Fatal(cmp.Diff(copied, build()))
+	}
+
+	// 複製をその場で書き換えても、元のノードは変わらない
+	Inspect(copied, func(node Node) bool {
+		switch node := node.(type) {
+		case *Identifier:
+			node.Value = "changed"
+			if node.Resolved != nil {
+				node.Resolved.Slot = 9
+			}
+		case *IntegerLiteral:
+			node.Value = 99
+		case *HashLiteral:
+			node.Pairs[0].Key = &IntegerLiteral{Value: 99}
+		case *CallExpression:
+			node.Arguments[0] = &Boolean{Value: false}
+		case *LetStatement:
+			if node.Type != nil {
+				node.Type.(*NamedType).Name = "changed"
+			}
+		case *FunctionLiteral:
+			node.ParameterTypes[0].(*ArrayType).Elem = &NamedType{Name: "changed"}
+		}
+		return true
+	})
+	if !cmp.Equal(original, build()) {
+		t.Error(cmp.Diff(original, build()))
+	}
+}
@@ -0,0 +1,504 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/care0717/monkey-interpreter/token"
+)
+
+// MarshalNode は node を JSON にする。各ノードは "kind" に型名を持ち、トークンの位置も含めて
+// UnmarshalNode で元に戻せる。nil のスライスと空のスライスも区別して null と [] にする
+func MarshalNode(node Node) ([]byte, error) {
+	return json.Marshal(encodeNode(node))
+}
+
+// UnmarshalNode は MarshalNode が出力した JSON からノードを復元する
+func UnmarshalNode(data []byte) (Node, error) {
+	return decodeNode(data)
+}
+
+type jsonObject = map[string]interface{}
+
+func encodeNode(node Node) interface{} {
+	switch node := node.(type) {
+	case *Program:
+		return jsonObject{"kind": "Program", "statements": encodeStatements(node.Statements)}
+	case *Identifier:
+		if node == nil {
+			return nil
+		}
+		obj := jsonObject{"kind": "Identifier", "token": node.Token, "value": node.Value}
+		if node.Resolved != nil {
+			obj["resolved"] = jsonObject{"depth": node.Resolved.Depth, "slot": node.Resolved.Slot}
+		}
+		return obj
+	case *LetStatement:
+		if node == nil {
+			return nil
+		}
+		obj := jsonObject{"kind": "LetStatement", "token": node.Token, "name": encodeNode(node.Name), "value": encodeNode(node.Value)}
+		if node.Type != nil {
+			obj["type"] = encodeNode(node.Type)
+		}
+		return obj
+	case *ReturnStatement:
+		return jsonObject{"kind": "ReturnStatement", "token": node.Token, "returnValue": encodeNode(node.ReturnValue)}
+	case *ExpressionStatement:
+		return jsonObject{"kind": "ExpressionStatement", "token": node.Token, "expression": encodeNode(node.Expression)}
+	case *BlockStatement:
+		if node == nil {
+			return nil
+		}
+		return jsonObject{"kind": "BlockStatement", "token": node.Token, "statements": encodeStatements(node.Statements)}
+	case *ImportStatement:
+		return jsonObject{"kind": "ImportStatement", "token": node.Token, "names": encodeIdentifiers(node.Names), "path": encodeNode(node.Path)}
+	case *ExportStatement:
+		return jsonObject{"kind": "ExportStatement", "token": node.Token, "let": encodeNode(node.Let)}
+	case *IntegerLiteral:
+		return jsonObject{"kind": "IntegerLiteral", "token": node.Token, "value": node.Value}
+	case *Boolean:
+		return jsonObject{"kind": "Boolean", "token": node.Token, "value": node.Value}
+	case *StringLiteral:
+		if node == nil {
+			return nil
+		}
+		return jsonObject{"kind": "StringLiteral", "token": node.Token, "value": node.Value}
+	case *PrefixExpression:
+		return jsonObject{"kind": "PrefixExpression", "token": node.Token, "operator": node.Operator, "right": encodeNode(node.Right)}
+	case *InfixExpression:
+		return jsonObject{"kind": "InfixExpression", "token": node.Token, "operator": node.Operator, "left": encodeNode(node.Left), "right": encodeNode(node.Right)}
+	case *IfExpression:
+		return jsonObject{
+			"kind":        "IfExpression",
+			"token":       node.Token,
+			"condition":   encodeNode(node.Condition),
+			"consequence": encodeNode(node.Consequence),
+			"alternative": encodeNode(node.Alternative),
+		}
+	case *FunctionLiteral:
+		obj := jsonObject{"kind": "FunctionLiteral", "token": node.Token, "parameters": encodeIdentifiers(node.Parameters), "body": encodeNode(node.Body)}
+		if node.ParameterTypes != nil {
+			obj["parameterTypes"] = encodeTypes(node.ParameterTypes)
+		}
+		if node.ReturnType != nil {
+			obj["returnType"] = encodeNode(node.ReturnType)
+		}
+		return obj
+	case *MacroLiteral:
+		return jsonObject{"kind": "MacroLiteral", "token": node.Token, "parameters": encodeIdentifiers(node.Parameters), "body": encodeNode(node.Body)}
+	case *CallExpression:
+		return jsonObject{"kind": "CallExpression", "token": node.Token, "function": encodeNode(node.Function), "arguments": encodeExpressions(node.Arguments)}
+	case *ArrayLiteral:
+		return jsonObject{"kind": "ArrayLiteral", "token": node.Token, "elements": encodeExpressions(node.Elements)}
+	case *IndexExpression:
+		return jsonObject{"kind": "IndexExpression", "token": node.Token, "left": encodeNode(node.Left), "index": encodeNode(node.Index)}
+	case *SliceExpression:
+		return jsonObject{"kind": "SliceExpression", "token": node.Token, "left": encodeNode(node.Left), "low": encodeNode(node.Low), "high": encodeNode(node.High)}
+	case *HashLiteral:
+		var pairs []interface{}
+		if node.Pairs != nil {
+			pairs = []interface{}{}
+		}
+		for _, pair := range node.Pairs {
+			pairs = append(pairs, jsonObject{"key": encodeNode(pair.Key), "value": encodeNode(pair.Value)})
+		}
+		return jsonObject{"kind": "HashLiteral", "token": node.Token, "pairs": pairs}
+	case *NamedType:
+		return jsonObject{"kind": "NamedType", "token": node.Token, "name": node.Name}
+	case *ArrayType:
+		return jsonObject{"kind": "ArrayType", "token": node.Token, "elem": encodeNode(node.Elem)}
+	case *HashType:
+		return jsonObject{"kind": "HashType", "token": node.Token, "key": encodeNode(node.Key), "value": encodeNode(node.Value)}
+	case *FunctionType:
+		return jsonObject{"kind": "FunctionType", "token": node.Token, "params": encodeTypes(node.Params), "return": encodeNode(node.Return)}
+	}
+	return nil
+}
+
+func encodeStatements(statements []Statement) []interface{} {
+	if statements == nil {
+		return nil
+	}
+	result := []interface{}{}
+	for _, s := range statements {
+		result = append(result, encodeNode(s))
+	}
+	return result
+}
+
+func encodeExpressions(expressions []Expression) []interface{} {
+	if expressions == nil {
+		return nil
+	}
+	result := []interface{}{}
+	for _, e := range expressions {
+		result = append(result, encodeNode(e))
+	}
+	return result
+}
+
+func encodeIdentifiers(idents []*Identifier) []interface{} {
+	if idents == nil {
+		return nil
+	}
+	result := []interface{}{}
+	for _, ident := range idents {
+		result = append(result, encodeNode(ident))
+	}
+	return result
+}
+
+// encodeTypes は注釈のない引数の nil を null にする
+func encodeTypes(types []TypeExpression) []interface{} {
+	if types == nil {
+		return nil
+	}
+	result := []interface{}{}
+	for _, t := range types {
+		if t == nil {
+			result = append(result, nil)
+		} else {
+			result = append(result, encodeNode(t))
+		}
+	}
+	return result
+}
+
+// fields はひとつのノードの JSON のフィールド。最初に起きたエラーを覚えておき、デコードの最後にまとめて返す
+type fields struct {
+	kind   string
+	values map[string]json.RawMessage
+	err    error
+}
+
+func (f *fields) decode(name string, v interface{}) {
+	if f.err != nil {
+		return
+	}
+	raw, ok := f.values[name]
+	if !ok {
+		f.err = fmt.Errorf("%s: missing field %q", f.kind, name)
+		return
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		f.err = fmt.Errorf("%s.%s: %w", f.kind, name, err)
+	}
+}
+
+func (f *fields) token() token.Token {
+	var tok token.Token
+	f.decode("token", &tok)
+	return tok
+}
+
+func (f *fields) str(name string) string {
+	var s string
+	f.decode(name, &s)
+	return s
+}
+
+func (f *fields) node(name string) Node {
+	var raw json.RawMessage
+	f.decode(name, &raw)
+	if f.err != nil {
+		return nil
+	}
+	node, err := decodeNode(raw)
+	if err != nil {
+		f.err = fmt.Errorf("%s.%s: %w", f.kind, name, err)
+	}
+	return node
+}
+
+func (f *fields) nodes(name string) []Node {
+	var raws []json.RawMessage
+	f.decode(name, &raws)
+	if f.err != nil || raws == nil {
+		return nil
+	}
+	result := []Node{}
+	for i, raw := range raws {
+		node, err := decodeNode(raw)
+		if err != nil {
+			f.err = fmt.Errorf("%s.%s[%d]: %w", f.kind, name, i, err)
+			return nil
+		}
+		result = append(result, node)
+	}
+	return result
+}
+
+// expression などは復元したノードを期待する型にする。nil はそのまま nil にする
+func (f *fields) expression(name string) Expression {
+	node := f.node(name)
+	if node == nil {
+		return nil
+	}
+	exp, ok := node.(Expression)
+	if !ok {
+		f.fail(name, "an expression", node)
+	}
+	return exp
+}
+
+func (f *fields) identifier(name string) *Identifier {
+	node := f.node(name)
+	if node == nil {
+		return nil
+	}
+	ident, ok := node.(*Identifier)
+	if !ok {
+		f.fail(name, "an Identifier", node)
+	}
+	return ident
+}
+
+func (f *fields) block(name string) *BlockStatement {
+	node := f.node(name)
+	if node == nil {
+		return nil
+	}
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		f.fail(name, "a BlockStatement", node)
+	}
+	return block
+}
+
+func (f *fields) statements(name string) []Statement {
+	nodes := f.nodes(name)
+	if nodes == nil {
+		return nil
+	}
+	result := []Statement{}
+	for _, node := range nodes {
+		stmt, ok := node.(Statement)
+		if !ok {
+			f.fail(name, "a statement", node)
+			return nil
+		}
+		result = append(result, stmt)
+	}
+	return result
+}
+
+func (f *fields) expressions(name string) []Expression {
+	nodes := f.nodes(name)
+	if nodes == nil {
+		return nil
+	}
+	result := []Expression{}
+	for _, node := range nodes {
+		exp, ok := node.(Expression)
+		if !ok {
+			f.fail(name, "an expression", node)
+			return nil
+		}
+		result = append(result, exp)
+	}
+	return result
+}
+
+func (f *fields) identifiers(name string) []*Identifier {
+	nodes := f.nodes(name)
+	if nodes == nil {
+		return nil
+	}
+	result := []*Identifier{}
+	for _, node := range nodes {
+		ident, ok := node.(*Identifier)
+		if !ok {
+			f.fail(name, "an Identifier", node)
+			return nil
+		}
+		result = append(result, ident)
+	}
+	return result
+}
+
+// typeExpression は型の注釈を復元する。注釈は省略できるので、フィールドがなければ nil にする
+func (f *fields) typeExpression(name string) TypeExpression {
+	if _, ok := f.values[name]; !ok {
+		return nil
+	}
+	node := f.node(name)
+	if node == nil {
+		return nil
+	}
+	t, ok := node.(TypeExpression)
+	if !ok {
+		f.fail(name, "a type", node)
+	}
+	return t
+}
+
+// requiredType は型の中の型を復元する。省略できない
+func (f *fields) requiredType(name string) TypeExpression {
+	f.decode(name, new(json.RawMessage))
+	if t := f.typeExpression(name); t != nil || f.err != nil {
+		return t
+	}
+	f.fail(name, "a type", nil)
+	return nil
+}
+
+// types は引数の型の注釈を復元する。null の要素は注釈のない引数として nil のままにする
+func (f *fields) types(name string) []TypeExpression {
+	if _, ok := f.values[name]; !ok {
+		return nil
+	}
+	nodes := f.nodes(name)
+	if nodes == nil {
+		return nil
+	}
+	result := []TypeExpression{}
+	for _, node := range nodes {
+		if node == nil {
+			result = append(result, nil)
+			continue
+		}
+		t, ok := node.(TypeExpression)
+		if !ok {
+			f.fail(name, "a type", node)
+			return nil
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+func (f *fields) fail(name, expected string, got Node) {
+	if f.err == nil {
+		f.err = fmt.Errorf("%s.%s: expected %s, got %T", f.kind, name, expected, got)
+	}
+}
+
+func decodeNode(data []byte) (Node, error) {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	if values == nil {
+		return nil, nil
+	}
+
+	f := &fields{values: values}
+	f.kind = f.str("kind")
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var node Node
+	switch f.kind {
+	case "Program":
+		node = &Program{Statements: f.statements("statements")}
+	case "Identifier":
+		ident := &Identifier{Token: f.token(), Value: f.str("value")}
+		if raw, ok := values["resolved"]; ok {
+			var resolved struct{ Depth, Slot int }
+			if err := json.Unmarshal(raw, &resolved); err != nil {
+				return nil, fmt.Errorf("Identifier.resolved: %w", err)
+			}
+			ident.Resolved = &Resolution{Depth: resolved.Depth, Slot: resolved.Slot}
+		}
+		node = ident
+	case "LetStatement":
+		node = &LetStatement{Token: f.token(), Name: f.identifier("name"), Type: f.typeExpression("type"), Value: f.expression("value")}
+	case "ReturnStatement":
+		node = &ReturnStatement{Token: f.token(), ReturnValue: f.expression("returnValue")}
+	case "ExpressionStatement":
+		node = &ExpressionStatement{Token: f.token(), Expression: f.expression("expression")}
+	case "BlockStatement":
+		node = &BlockStatement{Token: f.token(), Statements: f.statements("statements")}
+	case "ImportStatement":
+		stmt := &ImportStatement{Token: f.token(), Names: f.identifiers("names")}
+		if path := f.node("path"); path != nil {
+			lit, ok := path.(*StringLiteral)
+			if !ok {
+				f.fail("path", "a StringLiteral", path)
+			}
+			stmt.Path = lit
+		}
+		node = stmt
+	case "ExportStatement":
+		stmt := &ExportStatement{Token: f.token()}
+		if let := f.node("let"); let != nil {
+			ls, ok := let.(*LetStatement)
+			if !ok {
+				f.fail("let", "a LetStatement", let)
+			}
+			stmt.Let = ls
+		}
+		node = stmt
+	case "IntegerLiteral":
+		lit := &IntegerLiteral{Token: f.token()}
+		f.decode("value", &lit.Value)
+		node = lit
+	case "Boolean":
+		b := &Boolean{Token: f.token()}
+		f.decode("value", &b.Value)
+		node = b
+	case "StringLiteral":
+		node = &StringLiteral{Token: f.token(), Value: f.str("value")}
+	case "PrefixExpression":
+		node = &PrefixExpression{Token: f.token(), Operator: f.str("operator"), Right: f.expression("right")}
+	case "InfixExpression":
+		node = &InfixExpression{Token: f.token(), Operator: f.str("operator"), Left: f.expression("left"), Right: f.expression("right")}
+	case "IfExpression":
+		node = &IfExpression{Token: f.token(), Condition: f.expression("condition"), Consequence: f.block("consequence"), Alternative: f.block("alternative")}
+	case "FunctionLiteral":
+		node = &FunctionLiteral{
+			Token:          f.token(),
+			Parameters:     f.identifiers("parameters"),
+			ParameterTypes: f.types("parameterTypes"),
+			ReturnType:     f.typeExpression("returnType"),
+			Body:           f.block("body"),
+		}
+	case "MacroLiteral":
+		node = &MacroLiteral{Token: f.token(), Parameters: f.identifiers("parameters"), Body: f.block("body")}
+	case "CallExpression":
+		node = &CallExpression{Token: f.token(), Function: f.expression("function"), Arguments: f.expressions("arguments")}
+	case "ArrayLiteral":
+		node = &ArrayLiteral{Token: f.token(), Elements: f.expressions("elements")}
+	case "IndexExpression":
+		node = &IndexExpression{Token: f.token(), Left: f.expression("left"), Index: f.expression("index")}
+	case "SliceExpression":
+		node = &SliceExpression{Token: f.token(), Left: f.expression("left"), Low: f.expression("low"), High: f.expression("high")}
+	case "HashLiteral":
+		hash := &HashLiteral{Token: f.token()}
+		var raws []map[string]json.RawMessage
+		f.decode("pairs", &raws)
+		if raws != nil {
+			hash.Pairs = []HashPair{}
+		}
+		for _, raw := range raws {
+			pair := &fields{kind: "HashPair", values: raw}
+			hash.Pairs = append(hash.Pairs, HashPair{Key: pair.expression("key"), Value: pair.expression("value")})
+			if pair.err != nil && f.err == nil {
+				f.err = pair.err
+			}
+		}
+		node = hash
+	case "NamedType":
+		node = &NamedType{Token: f.token(), Name: f.str("name")}
+	case "ArrayType":
+		node = &ArrayType{Token: f.token(), Elem: f.requiredType("elem")}
+	case "HashType":
+		node = &HashType{Token: f.token(), Key: f.requiredType("key"), Value: f.requiredType("value")}
+	case "FunctionType":
+		ft := &FunctionType{Token: f.token(), Params: f.types("params"), Return: f.requiredType("return")}
+		for _, param := range ft.Params {
+			if param == nil {
+				f.fail("params", "a type", nil)
+			}
+		}
+		node = ft
+	default:
+		return nil, fmt.Errorf("unknown node kind %q", f.kind)
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+	return node, nil
+}
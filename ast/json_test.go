@@ -0,0 +1,138 @@
+package ast
+
+import (
+	"github.com/care0717/monkey-interpreter/token"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestMarshalNode(t *testing.T) {
+	pos := func(line, column int) token.Position { return token.Position{Line: line, Column: column} }
+	ident := func(name string, column int) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Pos: pos(1, column)}, Value: name}
+	}
+	integer := func(value int64) *IntegerLiteral {
+		return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1", Pos: pos(2, 1)}, Value: value}
+	}
+	str := func(value string) *StringLiteral {
+		return &StringLiteral{Token: token.Token{Type: token.STRING, Literal: value, Pos: pos(3, 1)}, Value: value}
+	}
+	block := func(statements ...Statement) *BlockStatement {
+		return &BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Statements: statements}
+	}
+	resolved := ident("x", 4)
+	resolved.Resolved = &Resolution{Depth: 1, Slot: 2}
+
+	program := &Program{Statements: []Statement{
+		&ImportStatement{Token: token.Token{Type: token.IMPORT, Literal: "import"}, Names: []*Identifier{ident("a", 1)}, Path: str("lib")},
+		&ImportStatement{Token: token.Token{Type: token.IMPORT, Literal: "import"}, Path: str("all")},
+		&ExportStatement{Token: token.Token{Type: token.EXPORT, Literal: "export"}, Let: &LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  ident("f", 5),
+			Value: &FunctionLiteral{
+				Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+				Parameters: []*Identifier{resolved, ident("y", 7)},
+				ParameterTypes: []TypeExpression{
+					&ArrayType{Token: token.Token{Type: token.LBRACKET, Literal: "["}, Elem: &NamedType{Token: token.Token{Type: token.IDENT, Literal: "int"}, Name: "int"}},
+					nil,
+				},
+				ReturnType: &FunctionType{
+					Token: token.Token{Type: token.FUNCTION, Literal: "fn"},
+					Params: []TypeExpression{&HashType{
+						Token: token.Token{Type: token.LBRACE, Literal: "{"},
+						Key:   &NamedType{Token: token.Token{Type: token.IDENT, Literal: "string"}, Name: "string"},
+						Value: &NamedType{Token: token.Token{Type: token.IDENT, Literal: "any"}, Name: "any"},
+					}},
+					Return: &NamedType{Token: token.Token{Type: token.IDENT, Literal: "bool"}, Name: "bool"},
+				},
+				Body: block(
+					&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}, ReturnValue: &PrefixExpression{
+						Token:    token.Token{Type: token.MINUS, Literal: "-"},
+						Operator: "-",
+						Right:    resolved,
+					}},
+				),
+			},
+		}},
+		&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  ident("n", 5),
+			Type:  &NamedType{Token: token.Token{Type: token.IDENT, Literal: "int"}, Name: "int"},
+			Value: integer(1),
+		},
+		&LetStatement{Token: token.Token{Type: token.LET, Literal: "let"}, Name: ident("m", 5), Value: &MacroLiteral{
+			Token:      token.Token{Type: token.MACRO, Literal: "macro"},
+			Parameters: []*Identifier{},
+			Body:       block(),
+		}},
+		&ExpressionStatement{Token: token.Token{Type: token.IF, Literal: "if"}, Expression: &IfExpression{
+			Token:       token.Token{Type: token.IF, Literal: "if"},
+			Condition:   &Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true},
+			Consequence: block(&ExpressionStatement{Expression: integer(1)}),
+		}},
+		&ExpressionStatement{Expression: &CallExpression{
+			Token:    token.Token{Type: token.LPAREN, Literal: "("},
+			Function: ident("f", 1),
+			Arguments: []Expression{
+				&InfixExpression{Token: token.Token{Type: token.PLUS, Literal: "+"}, Operator: "+", Left: integer(1), Right: integer(-2)},
+				&ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "["}, Elements: []Expression{}},
+				&IndexExpression{Token: token.Token{Type: token.LBRACKET, Literal: "["}, Left: ident("a", 1), Index: integer(0)},
+				&SliceExpression{Token: token.Token{Type: token.LBRACKET, Literal: "["}, Left: ident("a", 1), High: integer(2)},
+				&HashLiteral{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Pairs: []HashPair{
+					{Key: str("k"), Value: &Boolean{Token: token.Token{Type: token.FALSE, Literal: "false"}}},
+				}},
+			},
+		}},
+	}}
+
+	data, err := MarshalNode(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalNode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(decoded, Node(program)) {
+		t.Errorf("round trip changed the node. diff %s[-got, +expected]", cmp.Diff(decoded, Node(program)))
+	}
+}
+
+func TestUnmarshalNodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `{"kind": "Nothing"}`,
+			expected: `unknown node kind "Nothing"`,
+		},
+		{
+			input:    `{"kind": "ExpressionStatement", "token": {}}`,
+			expected: `ExpressionStatement: missing field "expression"`,
+		},
+		{
+			input:    `{"kind": "LetStatement", "token": {}, "name": {"kind": "Boolean", "token": {}, "value": true}, "value": null}`,
+			expected: `LetStatement.name: expected an Identifier, got *ast.Boolean`,
+		},
+		{
+			input:    `{"kind": "ArrayType", "token": {}, "elem": {"kind": "Boolean", "token": {}, "value": true}}`,
+			expected: `ArrayType.elem: expected a type, got *ast.Boolean`,
+		},
+		{
+			input:    `{"kind": "ArrayType", "token": {}, "elem": null}`,
+			expected: `ArrayType.elem: expected a type, got <nil>`,
+		},
+		{
+			input:    `{"kind": "Program", "statements": [{"kind": "Identifier", "token": {}, "value": "x"}]}`,
+			expected: `Program.statements: expected a statement, got *ast.Identifier`,
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := UnmarshalNode([]byte(tt.input))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("case: %s. expected error %q, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -1,51 +1,223 @@
 package ast
 
+import "fmt"
+
 type ModifierFunc func(Node) Node
 
-func Modify(node Node, modifier ModifierFunc) Node {
+// ModifyError は modifier が返したノードを元の場所に置けなかったことを表す
+type ModifyError struct {
+	// Parent は置き換えようとした子を持つノード。Field はその子のフィールド名
+	Parent Node
+	Field  string
+	// Want は Field に置けるノードの種類、Got は modifier が実際に返したノード
+	Want string
+	Got  Node
+}
+
+func (e *ModifyError) Error() string {
+	got := "nil"
+	if e.Got != nil {
+		got = fmt.Sprintf("%T", e.Got)
+	}
+	return fmt.Sprintf("cannot use %s as %s in %T.%s", got, e.Want, e.Parent, e.Field)
+}
+
+// Modify は node を深さ優先で辿り、子から順に modifier の結果で置き換える。
+// node はその場で書き換えられる。modifier が置けない種類のノードを返すと *ModifyError を返し、そこで辿るのをやめる
+func Modify(node Node, modifier ModifierFunc) (Node, error) {
+	m := &modification{modifier: modifier}
+	modified := m.modify(node)
+	if m.err != nil {
+		return node, m.err
+	}
+	return modified, nil
+}
+
+// ModifyCopy は node を書き換えずに、node の複製に Modify を適用したものを返す
+func ModifyCopy(node Node, modifier ModifierFunc) (Node, error) {
+	return Modify(Copy(node), modifier)
+}
+
+type modification struct {
+	modifier ModifierFunc
+	err      error
+}
+
+// field は子の場所。エラーになったときだけ文字列にする
+type field struct {
+	name  string
+	index int
+	// suffix はスライスの要素のさらに中のフィールド (HashPair の Key など)
+	suffix string
+}
+
+func (f field) String() string {
+	if f.index < 0 {
+		return f.name
+	}
+	return fmt.Sprintf("%s[%d]%s", f.name, f.index, f.suffix)
+}
+
+func (m *modification) fail(parent Node, f field, want string, got Node) {
+	if m.err == nil {
+		m.err = &ModifyError{Parent: parent, Field: f.String(), Want: want, Got: got}
+	}
+}
+
+func (m *modification) modify(node Node) Node {
 	switch node := node.(type) {
 	case *Program:
-		for i, state := range node.Statements {
-			node.Statements[i], _ = Modify(state, modifier).(Statement)
-		}
+		m.statements(node, "Statements", node.Statements)
+	case *LetStatement:
+		node.Name = m.identifier(node, field{name: "Name", index: -1}, node.Name)
+		node.Value = m.expression(node, field{name: "Value", index: -1}, node.Value)
+	case *ReturnStatement:
+		node.ReturnValue = m.expression(node, field{name: "ReturnValue", index: -1}, node.ReturnValue)
 	case *ExpressionStatement:
-		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
-	case *InfixExpression:
-		node.Left, _ = Modify(node.Left, modifier).(Expression)
-		node.Right, _ = Modify(node.Right, modifier).(Expression)
+		node.Expression = m.expression(node, field{name: "Expression", index: -1}, node.Expression)
+	case *BlockStatement:
+		m.statements(node, "Statements", node.Statements)
+	case *ImportStatement:
+		m.identifiers(node, "Names", node.Names)
+		node.Path = m.stringLiteral(node, field{name: "Path", index: -1}, node.Path)
+	case *ExportStatement:
+		node.Let = m.letStatement(node, field{name: "Let", index: -1}, node.Let)
+	case *Identifier, *IntegerLiteral, *Boolean, *StringLiteral:
+		// 子を持たない
 	case *PrefixExpression:
-		node.Right, _ = Modify(node.Right, modifier).(Expression)
-	case *IndexExpression:
-		node.Left, _ = Modify(node.Left, modifier).(Expression)
-		node.Index, _ = Modify(node.Index, modifier).(Expression)
+		node.Right = m.expression(node, field{name: "Right", index: -1}, node.Right)
+	case *InfixExpression:
+		node.Left = m.expression(node, field{name: "Left", index: -1}, node.Left)
+		node.Right = m.expression(node, field{name: "Right", index: -1}, node.Right)
 	case *IfExpression:
-		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
-		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
-		if node.Alternative != nil {
-			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
-		}
-	case *BlockStatement:
-		for i, _ := range node.Statements {
-			node.Statements[i] = Modify(node.Statements[i], modifier).(Statement)
-		}
-	case *ReturnStatement:
-		node.ReturnValue = Modify(node.ReturnValue, modifier).(Expression)
-	case *LetStatement:
-		node.Value = Modify(node.Value, modifier).(Expression)
+		node.Condition = m.expression(node, field{name: "Condition", index: -1}, node.Condition)
+		node.Consequence = m.block(node, field{name: "Consequence", index: -1}, node.Consequence)
+		node.Alternative = m.block(node, field{name: "Alternative", index: -1}, node.Alternative)
 	case *FunctionLiteral:
-		for i, _ := range node.Parameters {
-			node.Parameters[i], _ = Modify(node.Parameters[i], modifier).(*Identifier)
-		}
-		node.Body = Modify(node.Body, modifier).(*BlockStatement)
+		m.identifiers(node, "Parameters", node.Parameters)
+		node.Body = m.block(node, field{name: "Body", index: -1}, node.Body)
+	case *MacroLiteral:
+		m.identifiers(node, "Parameters", node.Parameters)
+		node.Body = m.block(node, field{name: "Body", index: -1}, node.Body)
+	case *CallExpression:
+		node.Function = m.expression(node, field{name: "Function", index: -1}, node.Function)
+		m.expressions(node, "Arguments", node.Arguments)
 	case *ArrayLiteral:
-		for i, _ := range node.Elements {
-			node.Elements[i], _ = Modify(node.Elements[i], modifier).(Expression)
-		}
+		m.expressions(node, "Elements", node.Elements)
+	case *IndexExpression:
+		node.Left = m.expression(node, field{name: "Left", index: -1}, node.Left)
+		node.Index = m.expression(node, field{name: "Index", index: -1}, node.Index)
+	case *SliceExpression:
+		node.Left = m.expression(node, field{name: "Left", index: -1}, node.Left)
+		node.Low = m.expression(node, field{name: "Low", index: -1}, node.Low)
+		node.High = m.expression(node, field{name: "High", index: -1}, node.High)
 	case *HashLiteral:
-		for i, _ := range node.Pairs {
-			node.Pairs[i].Key, _ = Modify(node.Pairs[i].Key, modifier).(Expression)
-			node.Pairs[i].Value, _ = Modify(node.Pairs[i].Value, modifier).(Expression)
+		for i := range node.Pairs {
+			node.Pairs[i].Key = m.expression(node, field{name: "Pairs", index: i, suffix: ".Key"}, node.Pairs[i].Key)
+			node.Pairs[i].Value = m.expression(node, field{name: "Pairs", index: i, suffix: ".Value"}, node.Pairs[i].Value)
 		}
 	}
-	return modifier(node)
+	if m.err != nil {
+		return node
+	}
+	return m.modifier(node)
+}
+
+// 以下の関数は、もともと nil の子 (else のない if や範囲を省いたスライスなど) は辿らずにそのまま返す。
+// 失敗したときは元の子を残す
+
+func (m *modification) expression(parent Node, f field, exp Expression) Expression {
+	if m.err != nil || exp == nil {
+		return exp
+	}
+	modified := m.modify(exp)
+	result, ok := modified.(Expression)
+	if !ok {
+		m.fail(parent, f, "Expression", modified)
+		return exp
+	}
+	return result
+}
+
+func (m *modification) statement(parent Node, f field, stmt Statement) Statement {
+	if m.err != nil || stmt == nil {
+		return stmt
+	}
+	modified := m.modify(stmt)
+	result, ok := modified.(Statement)
+	if !ok {
+		m.fail(parent, f, "Statement", modified)
+		return stmt
+	}
+	return result
+}
+
+func (m *modification) identifier(parent Node, f field, ident *Identifier) *Identifier {
+	if m.err != nil || ident == nil {
+		return ident
+	}
+	modified := m.modify(ident)
+	result, ok := modified.(*Identifier)
+	if !ok || result == nil {
+		m.fail(parent, f, "*ast.Identifier", modified)
+		return ident
+	}
+	return result
+}
+
+func (m *modification) block(parent Node, f field, block *BlockStatement) *BlockStatement {
+	if m.err != nil || block == nil {
+		return block
+	}
+	modified := m.modify(block)
+	result, ok := modified.(*BlockStatement)
+	if !ok || result == nil {
+		m.fail(parent, f, "*ast.BlockStatement", modified)
+		return block
+	}
+	return result
+}
+
+func (m *modification) letStatement(parent Node, f field, let *LetStatement) *LetStatement {
+	if m.err != nil || let == nil {
+		return let
+	}
+	modified := m.modify(let)
+	result, ok := modified.(*LetStatement)
+	if !ok || result == nil {
+		m.fail(parent, f, "*ast.LetStatement", modified)
+		return let
+	}
+	return result
+}
+
+func (m *modification) stringLiteral(parent Node, f field, str *StringLiteral) *StringLiteral {
+	if m.err != nil || str == nil {
+		return str
+	}
+	modified := m.modify(str)
+	result, ok := modified.(*StringLiteral)
+	if !ok || result == nil {
+		m.fail(parent, f, "*ast.StringLiteral", modified)
+		return str
+	}
+	return result
+}
+
+func (m *modification) statements(parent Node, name string, statements []Statement) {
+	for i := range statements {
+		statements[i] = m.statement(parent, field{name: name, index: i}, statements[i])
+	}
+}
+
+func (m *modification) expressions(parent Node, name string, expressions []Expression) {
+	for i := range expressions {
+		expressions[i] = m.expression(parent, field{name: name, index: i}, expressions[i])
+	}
+}
+
+func (m *modification) identifiers(parent Node, name string, idents []*Identifier) {
+	for i := range idents {
+		idents[i] = m.identifier(parent, field{name: name, index: i}, idents[i])
+	}
 }
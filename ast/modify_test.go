@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"errors"
 	"github.com/google/go-cmp/cmp"
 	"testing"
 )
@@ -55,6 +56,14 @@ func TestModify(t *testing.T) {
 			input:    &IndexExpression{Left: one(), Index: one()},
 			expected: &IndexExpression{Left: two(), Index: two()},
 		},
+		{
+			input:    &SliceExpression{Left: one(), Low: one(), High: one()},
+			expected: &SliceExpression{Left: two(), Low: two(), High: two()},
+		},
+		{
+			input:    &SliceExpression{Left: one()},
+			expected: &SliceExpression{Left: two()},
+		},
 		{
 			input: &IfExpression{
 				Condition: one(),
@@ -91,6 +100,10 @@ func TestModify(t *testing.T) {
 			input:    &LetStatement{Value: one()},
 			expected: &LetStatement{Value: two()},
 		},
+		{
+			input:    &ExportStatement{Let: &LetStatement{Value: one()}},
+			expected: &ExportStatement{Let: &LetStatement{Value: two()}},
+		},
 		{
 			input: &FunctionLiteral{
 				Parameters: []*Identifier{},
@@ -109,6 +122,36 @@ func TestModify(t *testing.T) {
 				},
 			},
 		},
+		{
+			input: &MacroLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: one()},
+					},
+				},
+			},
+			expected: &MacroLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{
+						&ExpressionStatement{Expression: two()},
+					},
+				},
+			},
+		},
+		{
+			input:    &CallExpression{Function: one(), Arguments: []Expression{one(), two()}},
+			expected: &CallExpression{Function: two(), Arguments: []Expression{two(), two()}},
+		},
+		{
+			input: &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{
+				&CallExpression{Function: &Identifier{Value: "g"}, Arguments: []Expression{one()}},
+			}},
+			expected: &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{
+				&CallExpression{Function: &Identifier{Value: "g"}, Arguments: []Expression{two()}},
+			}},
+		},
 		{
 			input:    &ArrayLiteral{Elements: []Expression{one(), one()}},
 			expected: &ArrayLiteral{Elements: []Expression{two(), two()}},
@@ -138,7 +181,10 @@ func TestModify(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		modified := Modify(tt.input, turnOneIntoTwo)
+		modified, err := Modify(tt.input, turnOneIntoTwo)
+		if err != nil {
+			t.Fatalf("Modify returned error: %s", err)
+		}
 
 		if !cmp.Equal(modified, tt.expected) {
 			t.Error(cmp.Diff(modified, tt.expected))
@@ -146,3 +192,147 @@ func TestModify(t *testing.T) {
 	}
 
 }
+
+func TestModifyIdentifiers(t *testing.T) {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+	rename := func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "x" {
+			return &Identifier{Value: "y"}
+		}
+		return node
+	}
+
+	input := &Program{Statements: []Statement{
+		&ImportStatement{Names: []*Identifier{ident("x")}, Path: &StringLiteral{Value: "lib"}},
+		&LetStatement{Name: ident("x"), Value: &FunctionLiteral{
+			Parameters: []*Identifier{ident("x")},
+			Body:       &BlockStatement{Statements: []Statement{&ReturnStatement{ReturnValue: ident("x")}}},
+		}},
+	}}
+	expected := &Program{Statements: []Statement{
+		&ImportStatement{Names: []*Identifier{ident("y")}, Path: &StringLiteral{Value: "lib"}},
+		&LetStatement{Name: ident("y"), Value: &FunctionLiteral{
+			Parameters: []*Identifier{ident("y")},
+			Body:       &BlockStatement{Statements: []Statement{&ReturnStatement{ReturnValue: ident("y")}}},
+		}},
+	}}
+
+	modified, err := Modify(input, rename)
+	if err != nil {
+		t.Fatalf("Modify returned error: %s", err)
+	}
+	if !cmp.Equal(modified, expected) {
+		t.Error(cmp.Diff(modified, expected))
+	}
+}
+
+func TestModifyErrors(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	replaceOneWith := func(replacement Node) ModifierFunc {
+		return func(node Node) Node {
+			if integer, ok := node.(*IntegerLiteral); ok && integer.Value == 1 {
+				return replacement
+			}
+			return node
+		}
+	}
+
+	tests := []struct {
+		input       Node
+		replacement Node
+		expected    string
+	}{
+		{
+			input:       &InfixExpression{Left: &IntegerLiteral{Value: 2}, Right: one()},
+			replacement: nil,
+			expected:    "cannot use nil as Expression in *ast.InfixExpression.Right",
+		},
+		{
+			input:       &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{&IntegerLiteral{Value: 2}, one()}},
+			replacement: &LetStatement{},
+			expected:    "cannot use *ast.LetStatement as Expression in *ast.CallExpression.Arguments[1]",
+		},
+		{
+			input:       &HashLiteral{Pairs: []HashPair{{Key: &StringLiteral{Value: "a"}, Value: one()}}},
+			replacement: &BlockStatement{},
+			expected:    "cannot use *ast.BlockStatement as Expression in *ast.HashLiteral.Pairs[0].Value",
+		},
+		{
+			input:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: &IntegerLiteral{Value: 2}}}},
+			replacement: nil,
+			expected:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := Modify(tt.input, replaceOneWith(tt.replacement))
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("expected error %q, got nil", tt.expected)
+			continue
+		}
+		if diff := cmp.Diff(err.Error(), tt.expected); diff != "" {
+			t.Error(diff)
+		}
+	}
+
+	// 文の位置に式を返すと、modifier の型に合わないのでエラーになる
+	toExpression := func(node Node) Node {
+		if stmt, ok := node.(*ExpressionStatement); ok {
+			return stmt.Expression
+		}
+		return node
+	}
+	program := &Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}}
+	_, err := Modify(program, toExpression)
+	var modifyErr *ModifyError
+	if !errors.As(err, &modifyErr) {
+		t.Fatalf("expected *ModifyError, got %T (%v)", err, err)
+	}
+	if modifyErr.Parent != program || modifyErr.Field != "Statements[0]" || modifyErr.Want != "Statement" {
+		t.Errorf("unexpected ModifyError: %+v", modifyErr)
+	}
+}
+
+func TestModifyCopy(t *testing.T) {
+	build := func() Node {
+		return &Program{Statements: []Statement{
+			&LetStatement{Name: &Identifier{Value: "a"}, Value: &IntegerLiteral{Value: 1}},
+			&ExpressionStatement{Expression: &CallExpression{
+				Function:  &Identifier{Value: "f"},
+				Arguments: []Expression{&IntegerLiteral{Value: 1}},
+			}},
+		}}
+	}
+	turnOneIntoTwo := func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok && integer.Value == 1 {
+			integer.Value = 2
+		}
+		return node
+	}
+
+	input := build()
+	modified, err := ModifyCopy(input, turnOneIntoTwo)
+	if err != nil {
+		t.Fatalf("ModifyCopy returned error: %s", err)
+	}
+	if !cmp.Equal(input, build()) {
+		t.Errorf("ModifyCopy modified its input: %s", cmp.Diff(input, build()))
+	}
+
+	expected := &Program{Statements: []Statement{
+		&LetStatement{Name: &Identifier{Value: "a"}, Value: &IntegerLiteral{Value: 2}},
+		&ExpressionStatement{Expression: &CallExpression{
+			Function:  &Identifier{Value: "f"},
+			Arguments: []Expression{&IntegerLiteral{Value: 2}},
+		}},
+	}}
+	if !cmp.Equal(modified, expected) {
+		t.Error(cmp.Diff(modified, expected))
+	}
+}
@@ -0,0 +1,95 @@
+package ast
+
+import (
+	"bytes"
+	"github.com/care0717/monkey-interpreter/token"
+	"strings"
+)
+
+// TypeExpression は let や関数の引数・戻り値につける型の注釈。
+// 注釈は式ではないので、Walk や Modify は辿らない
+type TypeExpression interface {
+	Node
+	// ダミーメソッド
+	typeNode()
+}
+
+// BasicTypes は注釈に名前だけで書ける型
+var BasicTypes = map[string]bool{
+	"int":    true,
+	"string": true,
+	"bool":   true,
+	"null":   true,
+	"quote":  true,
+	"any":    true,
+}
+
+// NamedType は int や string のような名前だけの型
+type NamedType struct {
+	Token token.Token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// ArrayType は要素の型が Elem の配列の型 [Elem]
+type ArrayType struct {
+	Token token.Token
+	Elem  TypeExpression
+}
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) String() string       { return "[" + at.Elem.String() + "]" }
+
+// HashType はキーの型が Key、値の型が Value のハッシュの型 {Key: Value}
+type HashType struct {
+	Token token.Token
+	Key   TypeExpression
+	Value TypeExpression
+}
+
+func (ht *HashType) typeNode()            {}
+func (ht *HashType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+// FunctionType は引数の型が Params、戻り値の型が Return の関数の型 fn(Params) -> Return
+type FunctionType struct {
+	Token  token.Token
+	Params []TypeExpression
+	Return TypeExpression
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) String() string {
+	var out bytes.Buffer
+
+	var params []string
+	for _, p := range ft.Params {
+		params = append(params, p.String())
+	}
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") -> ")
+	out.WriteString(ft.Return.String())
+
+	return out.String()
+}
+
+// Parameters は関数の引数を、注釈があれば name: type の形にして並べる
+func Parameters(params []*Identifier, types []TypeExpression) string {
+	var out []string
+	for i, p := range params {
+		if i < len(types) && types[i] != nil {
+			out = append(out, p.String()+": "+types[i].String())
+		} else {
+			out = append(out, p.String())
+		}
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,121 @@
+package ast
+
+// Visitor は Walk が出会ったノードごとに Visit を呼ばれる。
+// 返した Visitor が nil でなければ、それを使って node の子を辿り、最後に Visit(nil) を呼ぶ
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk は node を深さ優先で辿る。ノードを書き換えないので、解析に使える
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		Walk(v, n.Name)
+		walkExpression(v, n.Value)
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+	case *ImportStatement:
+		walkIdentifiers(v, n.Names)
+		if n.Path != nil {
+			Walk(v, n.Path)
+		}
+	case *ExportStatement:
+		if n.Let != nil {
+			Walk(v, n.Let)
+		}
+	case *Identifier, *IntegerLiteral, *Boolean, *StringLiteral:
+		// 子を持たない
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		if n.Consequence != nil {
+			Walk(v, n.Consequence)
+		}
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+	case *FunctionLiteral:
+		walkIdentifiers(v, n.Parameters)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *MacroLiteral:
+		walkIdentifiers(v, n.Parameters)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		for _, arg := range n.Arguments {
+			walkExpression(v, arg)
+		}
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			walkExpression(v, e)
+		}
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+	case *SliceExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Low)
+		walkExpression(v, n.High)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walkExpression(v, pair.Key)
+			walkExpression(v, pair.Value)
+		}
+	}
+
+	v.Visit(nil)
+}
+
+// walkExpression は省略できる式 (else のない if や範囲を省いたスライスなど) の nil を飛ばす
+func walkExpression(v Visitor, exp Expression) {
+	if exp != nil {
+		Walk(v, exp)
+	}
+}
+
+func walkStatements(v Visitor, statements []Statement) {
+	for _, stmt := range statements {
+		if stmt != nil {
+			Walk(v, stmt)
+		}
+	}
+}
+
+func walkIdentifiers(v Visitor, idents []*Identifier) {
+	for _, ident := range idents {
+		Walk(v, ident)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect は node を深さ優先で辿り、各ノードで f を呼ぶ。f が false を返すとそのノードの子は辿らない。
+// 子を辿り終えると f(nil) を呼ぶ
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,122 @@
+package ast
+
+import (
+	"fmt"
+	"github.com/care0717/monkey-interpreter/token"
+	"github.com/google/go-cmp/cmp"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	ident := func(name string) *Identifier {
+		return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+	integer := func(value int64) *IntegerLiteral { return &IntegerLiteral{Value: value} }
+
+	// let f = fn(x) { if (x) { x[1:] } else { m({"k": [x]}) } };
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: ident("f"), Value: &FunctionLiteral{
+			Parameters: []*Identifier{ident("x")},
+			Body: &BlockStatement{Statements: []Statement{
+				&ExpressionStatement{Expression: &IfExpression{
+					Condition: ident("x"),
+					Consequence: &BlockStatement{Statements: []Statement{
+						&ExpressionStatement{Expression: &SliceExpression{Left: ident("x"), Low: integer(1)}},
+					}},
+					Alternative: &BlockStatement{Statements: []Statement{
+						&ExpressionStatement{Expression: &CallExpression{
+							Function: ident("m"),
+							Arguments: []Expression{&HashLiteral{Pairs: []HashPair{
+								{Key: &StringLiteral{Value: "k"}, Value: &ArrayLiteral{Elements: []Expression{ident("x")}}},
+							}}},
+						}},
+					}},
+				}},
+			}},
+		}},
+	}}
+
+	kinds := func(stop func(Node) bool) []string {
+		var visited []string
+		Inspect(program, func(node Node) bool {
+			if node == nil {
+				return false
+			}
+			switch node := node.(type) {
+			case *Identifier:
+				visited = append(visited, node.Value)
+			case *IntegerLiteral:
+				visited = append(visited, "1")
+			case *StringLiteral:
+				visited = append(visited, `"k"`)
+			default:
+				visited = append(visited, strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+			}
+			return !stop(node)
+		})
+		return visited
+	}
+
+	tests := []struct {
+		stop     func(Node) bool
+		expected []string
+	}{
+		{
+			stop: func(Node) bool { return false },
+			expected: []string{
+				"Program", "LetStatement", "f", "FunctionLiteral", "x", "BlockStatement",
+				"ExpressionStatement", "IfExpression", "x",
+				"BlockStatement", "ExpressionStatement", "SliceExpression", "x", "1",
+				"BlockStatement", "ExpressionStatement", "CallExpression", "m", "HashLiteral", `"k"`, "ArrayLiteral", "x",
+			},
+		},
+		{
+			stop: func(node Node) bool {
+				_, ok := node.(*IfExpression)
+				return ok
+			},
+			expected: []string{
+				"Program", "LetStatement", "f", "FunctionLiteral", "x", "BlockStatement",
+				"ExpressionStatement", "IfExpression",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		got := kinds(tt.stop)
+		if !cmp.Equal(got, tt.expected) {
+			t.Errorf("test[%d] diff %s[-got, +expected]", i, cmp.Diff(got, tt.expected))
+		}
+	}
+}
+
+type depthVisitor struct {
+	depth    int
+	maxDepth *int
+}
+
+func (v depthVisitor) Visit(node Node) Visitor {
+	if node == nil {
+		return nil
+	}
+	if v.depth > *v.maxDepth {
+		*v.maxDepth = v.depth
+	}
+	return depthVisitor{depth: v.depth + 1, maxDepth: v.maxDepth}
+}
+
+func TestWalk(t *testing.T) {
+	// -(1 + 2) は Prefix, Infix, Integer の3段になる
+	node := &PrefixExpression{Operator: "-", Right: &InfixExpression{
+		Operator: "+",
+		Left:     &IntegerLiteral{Value: 1},
+		Right:    &IntegerLiteral{Value: 2},
+	}}
+
+	maxDepth := 0
+	Walk(depthVisitor{maxDepth: &maxDepth}, node)
+	if maxDepth != 2 {
+		t.Errorf("max depth wrong. expected=2, got=%d", maxDepth)
+	}
+}
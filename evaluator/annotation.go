@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/token"
+)
+
+// namedTypes は注釈の名前だけの型と、その型の値のオブジェクトの型
+var namedTypes = map[string]object.Type{
+	"int":    object.INTEGER_OBJ,
+	"string": object.STRING_OBJ,
+	"bool":   object.BOOLEAN_OBJ,
+	"null":   object.NULL_OBJ,
+	"quote":  object.QUOTE_OBJ,
+}
+
+// conforms は obj が型の注釈 t に合うかを返す。配列とハッシュは要素もすべて確かめる。
+// 関数は引数の個数だけを確かめ、引数と戻り値の型は呼び出したときに確かめる
+func conforms(obj object.Object, t ast.TypeExpression) bool {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		if t.Name == "any" {
+			return true
+		}
+		return obj.Type() == namedTypes[t.Name]
+	case *ast.ArrayType:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return false
+		}
+		for _, e := range arr.Elements {
+			if !conforms(e, t.Elem) {
+				return false
+			}
+		}
+		return true
+	case *ast.HashType:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return false
+		}
+		for _, pair := range hash.Pairs {
+			if !conforms(pair.Key, t.Key) || !conforms(pair.Value, t.Value) {
+				return false
+			}
+		}
+		return true
+	case *ast.FunctionType:
+		switch fn := obj.(type) {
+		case *object.Function:
+			return len(fn.Parameters) == len(t.Params)
+		case *object.Builtin:
+			return true
+		}
+	}
+	return false
+}
+
+// checkLet は strict モードで、let の値が注釈の型に合わなければエラーを返す
+func (ip *Interpreter) checkLet(stmt *ast.LetStatement, val object.Object) *object.Error {
+	if !ip.Strict || stmt.Type == nil || conforms(val, stmt.Type) {
+		return nil
+	}
+	return newErrorAt(stmt.Name.Token.Pos, "cannot use %s as %s in let %s", val.Type(), stmt.Type, stmt.Name.Value)
+}
+
+// checkArguments は strict モードで、引数が注釈の型に合わなければエラーを返す
+func (ip *Interpreter) checkArguments(fn *object.Function, args []object.Object, pos token.Position) *object.Error {
+	if !ip.Strict {
+		return nil
+	}
+	for i, t := range fn.ParameterTypes {
+		if t != nil && !conforms(args[i], t) {
+			return newErrorAt(pos, "cannot use %s as %s in argument %s", args[i].Type(), t, fn.Parameters[i].Value)
+		}
+	}
+	return nil
+}
+
+// checkReturn は strict モードで、戻り値が注釈の型に合わなければエラーを返す
+func (ip *Interpreter) checkReturn(fn *object.Function, result object.Object, pos token.Position) *object.Error {
+	if !ip.Strict || fn.ReturnType == nil || isError(result) {
+		return nil
+	}
+	// 空の本体や let で終わる本体は値を持たない
+	if result == nil {
+		result = object.NULL
+	}
+	if conforms(result, fn.ReturnType) {
+		return nil
+	}
+	return newErrorAt(pos, "cannot use %s as %s in return value", result.Type(), fn.ReturnType)
+}
@@ -0,0 +1,52 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/object"
+	"testing"
+)
+
+func TestStrictTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let n: int = 1; n`, "1"},
+		{`let n: any = "a"; n`, "a"},
+		{`let xs: [int] = [1, 2]; xs`, "[1, 2]"},
+		{`let h: {string: [bool]} = {"a": [true]}; len(h)`, "1"},
+		{`let f: fn(int) -> int = fn(x) { x }; f(1)`, "1"},
+		{`let f: fn(string) -> int = len; f("ab")`, "2"},
+		{`let add = fn(a: int, b: int) -> int { a + b }; add(1, 2)`, "3"},
+		{`let f = fn(x: int) -> null { if (false) { x } }; f(1)`, "null"},
+		{`map([1, 2], fn(x: int) -> int { x * 2 })`, "[2, 4]"},
+		{`let n: int = "a";`, `ERROR: 1:5: cannot use STRING as int in let n`},
+		{`let xs: [int] = [1, "a"];`, `ERROR: 1:5: cannot use ARRAY as [int] in let xs`},
+		{`let h: {string: int} = {1: 1};`, `ERROR: 1:5: cannot use HASH as {string: int} in let h`},
+		{`let f: fn(int) -> int = fn(a, b) { a };`, `ERROR: 1:5: cannot use FUNCTION as fn(int) -> int in let f`},
+		{`let add = fn(a: int, b: int) { a + b }; add(1, "b")`, `ERROR: 1:44: cannot use STRING as int in argument b`},
+		{`let f = fn() -> string { return 1; }; f()`, `ERROR: 1:40: cannot use INTEGER as string in return value`},
+		{`map(["a"], fn(x: int) { x })`, `ERROR: 1:4: cannot use STRING as int in argument x`},
+	}
+
+	for _, tt := range tests {
+		ip := New(nil, nil)
+		ip.Strict = true
+		evaluated := ip.Eval(testParseProgram(tt.input), object.NewEnvironment())
+		if evaluated == nil {
+			t.Errorf("case: %s. expected=%q, got nil", tt.input, tt.expected)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("case: %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// strict モードでなければ注釈は評価に影響しない
+func TestTypeAnnotationsWithoutStrict(t *testing.T) {
+	input := `let n: int = "a"; let f = fn(x: int) -> int { x + "b" }; f(n)`
+	evaluated := New(nil, nil).Eval(testParseProgram(input), object.NewEnvironment())
+	if evaluated.Inspect() != "ab" {
+		t.Errorf("expected=%q, got=%q", "ab", evaluated.Inspect())
+	}
+}
@@ -0,0 +1,75 @@
+package evaluator
+
+// Arity は builtin が受け取る引数の個数の範囲。Max が負なら上限はない
+type Arity struct {
+	Min int
+	Max int
+}
+
+// Accepts は n 個の引数で呼び出せるかを返す
+func (a Arity) Accepts(n int) bool {
+	return n >= a.Min && (a.Max < 0 || n <= a.Max)
+}
+
+// builtinArities は静的解析のための builtin の引数の個数。builtin を追加したらここにも加える
+var builtinArities = map[string]Arity{
+	"len":   {Min: 1, Max: 1},
+	"first": {Min: 1, Max: 1},
+	"last":  {Min: 1, Max: 1},
+	"rest":  {Min: 1, Max: 1},
+	"push":  {Min: 2, Max: 2},
+	"puts":  {Min: 0, Max: -1},
+
+	"map":     {Min: 2, Max: 2},
+	"filter":  {Min: 2, Max: 2},
+	"reduce":  {Min: 3, Max: 3},
+	"each":    {Min: 2, Max: 2},
+	"find":    {Min: 2, Max: 2},
+	"any":     {Min: 2, Max: 2},
+	"all":     {Min: 2, Max: 2},
+	"sort":    {Min: 1, Max: 2},
+	"reverse": {Min: 1, Max: 1},
+	"zip":     {Min: 1, Max: -1},
+	"range":   {Min: 1, Max: 3},
+	"flatten": {Min: 1, Max: 1},
+	"uniq":    {Min: 1, Max: 1},
+
+	"keys":    {Min: 1, Max: 1},
+	"values":  {Min: 1, Max: 1},
+	"entries": {Min: 1, Max: 1},
+	"has_key": {Min: 2, Max: 2},
+	"delete":  {Min: 2, Max: 2},
+	"put":     {Min: 3, Max: 3},
+	"merge":   {Min: 1, Max: -1},
+
+	"split":       {Min: 2, Max: 2},
+	"join":        {Min: 2, Max: 2},
+	"trim":        {Min: 1, Max: 2},
+	"upper":       {Min: 1, Max: 1},
+	"lower":       {Min: 1, Max: 1},
+	"replace":     {Min: 3, Max: 3},
+	"contains":    {Min: 2, Max: 2},
+	"starts_with": {Min: 2, Max: 2},
+	"ends_with":   {Min: 2, Max: 2},
+	"index_of":    {Min: 2, Max: 2},
+	"substr":      {Min: 2, Max: 3},
+	"repeat":      {Min: 2, Max: 2},
+	"chars":       {Min: 1, Max: 1},
+	"format":      {Min: 1, Max: -1},
+	"to_int":      {Min: 1, Max: 1},
+	"to_string":   {Min: 1, Max: 1},
+
+	"gensym":        {Min: 0, Max: 1},
+	"macroexpand":   {Min: 1, Max: 1},
+	"macroexpand_1": {Min: 1, Max: 1},
+
+	"node_kind":  {Min: 1, Max: 1},
+	"node_field": {Min: 2, Max: 2},
+	"make_node":  {Min: 2, Max: 2},
+}
+
+// BuiltinArity は name という builtin があれば、その引数の個数の範囲を返す
+func BuiltinArity(name string) (Arity, bool) {
+	arity, ok := builtinArities[name]
+	return arity, ok
+}
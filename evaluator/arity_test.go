@@ -0,0 +1,48 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/object"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinArity(t *testing.T) {
+	for name := range builtins {
+		if _, ok := BuiltinArity(name); !ok {
+			t.Errorf("builtin %s has no arity", name)
+		}
+	}
+
+	args := func(n int) []object.Object {
+		result := make([]object.Object, n)
+		for i := range result {
+			result[i] = &object.Integer{Value: 1}
+		}
+		return result
+	}
+	ctx := &callContext{ip: New(nil, &strings.Builder{})}
+	for name, arity := range builtinArities {
+		builtin, ok := builtins[name]
+		if !ok {
+			t.Errorf("arity of unknown builtin %s", name)
+			continue
+		}
+
+		var counts []int
+		if arity.Min > 0 {
+			counts = append(counts, arity.Min-1)
+		}
+		if arity.Max >= 0 {
+			counts = append(counts, arity.Max+1)
+		}
+		for _, n := range counts {
+			if arity.Accepts(n) {
+				t.Errorf("arity of %s accepts %d arguments", name, n)
+			}
+			err, ok := builtin.Fn(ctx, args(n)...).(*object.Error)
+			if !ok || !strings.HasPrefix(err.Message, "wrong number of arguments") {
+				t.Errorf("%s with %d arguments: expected arity error, got %v", name, n, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,534 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/token"
+	"sort"
+)
+
+func init() {
+	for name, builtin := range astBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// astBuiltins はマクロの本体で quote された AST を調べたり組み立てたりする関数。
+// ノードの種類とフィールドの名前は parse -json の出力と同じ
+var astBuiltins = map[string]*object.Builtin{
+	// node_kind は quote されたノードの種類を "InfixExpression" のような文字列で返す
+	"node_kind": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("node_kind", args, object.QUOTE_OBJ); err != nil {
+			return err
+		}
+		kind, _ := nodeFields(args[0].(*object.Quote).Node)
+		return &object.String{Value: kind}
+	}},
+	// node_field は quote されたノードのフィールドを返す。子のノードは quote、ノードの並びは quote の配列にする
+	"node_field": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("node_field", args, object.QUOTE_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+		kind, fields := nodeFields(args[0].(*object.Quote).Node)
+		name := args[1].(*object.String).Value
+		for _, f := range fields {
+			if f.name == name {
+				return f.value
+			}
+		}
+		return newError("%s: unknown field %q", kind, name)
+	}},
+	// make_node は種類とフィールドのハッシュからノードを作って quote で返す。
+	// ノードを受け取るフィールドには quote のほか、unquote できる値も渡せる
+	"make_node": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("make_node", args, object.STRING_OBJ, object.HASH_OBJ); err != nil {
+			return err
+		}
+		node, err := buildNode(args[0].(*object.String).Value, args[1].(*object.Hash), ctx.Pos())
+		if err != nil {
+			return err
+		}
+		return &object.Quote{Node: node}
+	}},
+}
+
+// nodeField はノードのフィールドの名前と、マクロから見える値
+type nodeField struct {
+	name  string
+	value object.Object
+}
+
+// nodeFields は node の種類とフィールドを返す
+func nodeFields(node ast.Node) (string, []nodeField) {
+	switch node := node.(type) {
+	case *ast.Program:
+		return "Program", []nodeField{{"statements", quoteStatements(node.Statements)}}
+	case *ast.Identifier:
+		return "Identifier", []nodeField{{"value", &object.String{Value: node.Value}}}
+	case *ast.LetStatement:
+		return "LetStatement", []nodeField{{"name", quoteNode(node.Name)}, {"value", quoteNode(node.Value)}}
+	case *ast.ReturnStatement:
+		return "ReturnStatement", []nodeField{{"returnValue", quoteNode(node.ReturnValue)}}
+	case *ast.ExpressionStatement:
+		return "ExpressionStatement", []nodeField{{"expression", quoteNode(node.Expression)}}
+	case *ast.BlockStatement:
+		return "BlockStatement", []nodeField{{"statements", quoteStatements(node.Statements)}}
+	case *ast.ImportStatement:
+		return "ImportStatement", []nodeField{{"names", quoteIdentifiers(node.Names)}, {"path", quoteNode(node.Path)}}
+	case *ast.ExportStatement:
+		return "ExportStatement", []nodeField{{"let", quoteNode(node.Let)}}
+	case *ast.IntegerLiteral:
+		return "IntegerLiteral", []nodeField{{"value", &object.Integer{Value: node.Value}}}
+	case *ast.Boolean:
+		return "Boolean", []nodeField{{"value", nativeBoolToBooleanObject(node.Value)}}
+	case *ast.StringLiteral:
+		return "StringLiteral", []nodeField{{"value", &object.String{Value: node.Value}}}
+	case *ast.PrefixExpression:
+		return "PrefixExpression", []nodeField{{"operator", &object.String{Value: node.Operator}}, {"right", quoteNode(node.Right)}}
+	case *ast.InfixExpression:
+		return "InfixExpression", []nodeField{
+			{"operator", &object.String{Value: node.Operator}},
+			{"left", quoteNode(node.Left)},
+			{"right", quoteNode(node.Right)},
+		}
+	case *ast.IfExpression:
+		return "IfExpression", []nodeField{
+			{"condition", quoteNode(node.Condition)},
+			{"consequence", quoteNode(node.Consequence)},
+			{"alternative", quoteNode(node.Alternative)},
+		}
+	case *ast.FunctionLiteral:
+		return "FunctionLiteral", []nodeField{{"parameters", quoteIdentifiers(node.Parameters)}, {"body", quoteNode(node.Body)}}
+	case *ast.MacroLiteral:
+		return "MacroLiteral", []nodeField{{"parameters", quoteIdentifiers(node.Parameters)}, {"body", quoteNode(node.Body)}}
+	case *ast.CallExpression:
+		return "CallExpression", []nodeField{{"function", quoteNode(node.Function)}, {"arguments", quoteExpressions(node.Arguments)}}
+	case *ast.ArrayLiteral:
+		return "ArrayLiteral", []nodeField{{"elements", quoteExpressions(node.Elements)}}
+	case *ast.IndexExpression:
+		return "IndexExpression", []nodeField{{"left", quoteNode(node.Left)}, {"index", quoteNode(node.Index)}}
+	case *ast.SliceExpression:
+		return "SliceExpression", []nodeField{{"left", quoteNode(node.Left)}, {"low", quoteNode(node.Low)}, {"high", quoteNode(node.High)}}
+	case *ast.HashLiteral:
+		pairs := make([]object.Object, len(node.Pairs))
+		for i, pair := range node.Pairs {
+			pairs[i] = newStringHash(map[string]object.Object{"key": quoteNode(pair.Key), "value": quoteNode(pair.Value)})
+		}
+		return "HashLiteral", []nodeField{{"pairs", &object.Array{Elements: pairs}}}
+	}
+	return "Unknown", nil
+}
+
+// quoteNode は子のノードを quote にする。ないノードは null にする
+func quoteNode(node ast.Node) object.Object {
+	if isNilNode(node) {
+		return object.NULL
+	}
+	return &object.Quote{Node: node}
+}
+
+// isNilNode は node が nil か、nil のポインタを持つインターフェースなら true を返す
+func isNilNode(node ast.Node) bool {
+	switch node := node.(type) {
+	case nil:
+		return true
+	case *ast.Identifier:
+		return node == nil
+	case *ast.BlockStatement:
+		return node == nil
+	case *ast.LetStatement:
+		return node == nil
+	case *ast.StringLiteral:
+		return node == nil
+	}
+	return false
+}
+
+func quoteStatements(statements []ast.Statement) *object.Array {
+	elements := make([]object.Object, len(statements))
+	for i, stmt := range statements {
+		elements[i] = quoteNode(stmt)
+	}
+	return &object.Array{Elements: elements}
+}
+
+func quoteExpressions(exps []ast.Expression) *object.Array {
+	elements := make([]object.Object, len(exps))
+	for i, exp := range exps {
+		elements[i] = quoteNode(exp)
+	}
+	return &object.Array{Elements: elements}
+}
+
+func quoteIdentifiers(idents []*ast.Identifier) *object.Array {
+	elements := make([]object.Object, len(idents))
+	for i, ident := range idents {
+		elements[i] = quoteNode(ident)
+	}
+	return &object.Array{Elements: elements}
+}
+
+func newStringHash(values map[string]object.Object) *object.Hash {
+	pairs := make(map[object.HashKey]object.HashPair, len(values))
+	for k, v := range values {
+		key := &object.String{Value: k}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: v}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+var (
+	prefixOperators = map[string]bool{"!": true, "-": true}
+	infixOperators  = map[string]bool{"+": true, "-": true, "*": true, "/": true, "==": true, "!=": true, "<": true, ">": true}
+)
+
+// nodeBuilder は make_node に渡されたフィールドを取り出してノードの部品にする。最初のエラーを覚えておく
+type nodeBuilder struct {
+	kind   string
+	fields map[string]object.Object
+	used   map[string]bool
+	pos    token.Position
+	err    *object.Error
+}
+
+// buildNode は kind の種類のノードを fields から作る
+func buildNode(kind string, fields *object.Hash, pos token.Position) (ast.Node, *object.Error) {
+	b := &nodeBuilder{kind: kind, fields: make(map[string]object.Object), used: make(map[string]bool), pos: pos}
+	for _, pair := range fields.Pairs {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			return nil, newError("%s: field names must be STRING, got %s", kind, pair.Key.Type())
+		}
+		b.fields[key.Value] = pair.Value
+	}
+
+	var node ast.Node
+	switch kind {
+	case "Identifier":
+		node = b.identifierFrom(b.require("value"), "value")
+	case "LetStatement":
+		node = &ast.LetStatement{Token: b.token(token.LET, "let"), Name: b.identifier("name"), Value: b.expression("value")}
+	case "ReturnStatement":
+		node = &ast.ReturnStatement{Token: b.token(token.RETURN, "return"), ReturnValue: b.expression("returnValue")}
+	case "ExpressionStatement":
+		exp := b.expression("expression")
+		node = &ast.ExpressionStatement{Token: b.expressionToken(exp), Expression: exp}
+	case "BlockStatement":
+		node = &ast.BlockStatement{Token: b.token(token.LBRACE, "{"), Statements: b.statements("statements")}
+	case "IntegerLiteral", "Boolean", "StringLiteral":
+		node = b.literal(kind)
+	case "PrefixExpression":
+		op := b.operator(prefixOperators)
+		node = &ast.PrefixExpression{Token: b.token(token.Type(op), op), Operator: op, Right: b.expression("right")}
+	case "InfixExpression":
+		op := b.operator(infixOperators)
+		node = &ast.InfixExpression{Token: b.token(token.Type(op), op), Operator: op, Left: b.expression("left"), Right: b.expression("right")}
+	case "IfExpression":
+		node = &ast.IfExpression{
+			Token:       b.token(token.IF, "if"),
+			Condition:   b.expression("condition"),
+			Consequence: b.block("consequence"),
+			Alternative: b.optionalBlock("alternative"),
+		}
+	case "FunctionLiteral":
+		node = &ast.FunctionLiteral{Token: b.token(token.FUNCTION, "fn"), Parameters: b.identifiers("parameters"), Body: b.block("body")}
+	case "MacroLiteral":
+		node = &ast.MacroLiteral{Token: b.token(token.MACRO, "macro"), Parameters: b.identifiers("parameters"), Body: b.block("body")}
+	case "CallExpression":
+		node = &ast.CallExpression{Token: b.token(token.LPAREN, "("), Function: b.expression("function"), Arguments: b.expressions("arguments")}
+	case "ArrayLiteral":
+		node = &ast.ArrayLiteral{Token: b.token(token.LBRACKET, "["), Elements: b.expressions("elements")}
+	case "IndexExpression":
+		node = &ast.IndexExpression{Token: b.token(token.LBRACKET, "["), Left: b.expression("left"), Index: b.expression("index")}
+	case "SliceExpression":
+		node = &ast.SliceExpression{
+			Token: b.token(token.LBRACKET, "["),
+			Left:  b.expression("left"),
+			Low:   b.optionalExpression("low"),
+			High:  b.optionalExpression("high"),
+		}
+	case "HashLiteral":
+		node = &ast.HashLiteral{Token: b.token(token.LBRACE, "{"), Pairs: b.pairs("pairs")}
+	case "Program", "ImportStatement", "ExportStatement":
+		return nil, newError("cannot make %s", kind)
+	default:
+		return nil, newError("unknown node kind %q", kind)
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	var unknown []string
+	for name := range b.fields {
+		if !b.used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return nil, newError("%s: unknown field %q", kind, unknown[0])
+	}
+	return node, nil
+}
+
+func (b *nodeBuilder) fail(format string, a ...interface{}) {
+	if b.err == nil {
+		b.err = newError(format, a...)
+	}
+}
+
+func (b *nodeBuilder) token(t token.Type, literal string) token.Token {
+	return token.Token{Type: t, Literal: literal, Pos: b.pos}
+}
+
+// expressionToken は式文のトークン。パーサーと同じく式の先頭のトークンにする
+func (b *nodeBuilder) expressionToken(exp ast.Expression) token.Token {
+	for {
+		switch e := exp.(type) {
+		case *ast.InfixExpression:
+			exp = e.Left
+		case *ast.CallExpression:
+			exp = e.Function
+		case *ast.IndexExpression:
+			exp = e.Left
+		case *ast.SliceExpression:
+			exp = e.Left
+		case *ast.Identifier:
+			return e.Token
+		case *ast.IntegerLiteral:
+			return e.Token
+		case *ast.Boolean:
+			return e.Token
+		case *ast.StringLiteral:
+			return e.Token
+		case *ast.PrefixExpression:
+			return e.Token
+		case *ast.IfExpression:
+			return e.Token
+		case *ast.FunctionLiteral:
+			return e.Token
+		case *ast.MacroLiteral:
+			return e.Token
+		case *ast.ArrayLiteral:
+			return e.Token
+		case *ast.HashLiteral:
+			return e.Token
+		default:
+			return b.token(token.ILLEGAL, "")
+		}
+	}
+}
+
+// optional はフィールドの値を返す。ないか null なら nil を返す
+func (b *nodeBuilder) optional(name string) object.Object {
+	b.used[name] = true
+	value, ok := b.fields[name]
+	if !ok || value == object.NULL {
+		return nil
+	}
+	return value
+}
+
+func (b *nodeBuilder) require(name string) object.Object {
+	value := b.optional(name)
+	if value == nil {
+		b.fail("%s: missing field %q", b.kind, name)
+	}
+	return value
+}
+
+func (b *nodeBuilder) expression(name string) ast.Expression {
+	return b.expressionFrom(b.require(name), name)
+}
+
+func (b *nodeBuilder) optionalExpression(name string) ast.Expression {
+	value := b.optional(name)
+	if value == nil {
+		return nil
+	}
+	return b.expressionFrom(value, name)
+}
+
+func (b *nodeBuilder) expressionFrom(value object.Object, name string) ast.Expression {
+	if value == nil || b.err != nil {
+		return nil
+	}
+	exp, err := convertObjectToExpression(value, b.pos)
+	if err != nil {
+		b.fail("%s.%s: %s", b.kind, name, err.Message)
+		return nil
+	}
+	return exp
+}
+
+func (b *nodeBuilder) expressions(name string) []ast.Expression {
+	elements := b.array(name)
+	exps := make([]ast.Expression, 0, len(elements))
+	for _, element := range elements {
+		exps = append(exps, b.expressionFrom(element, name))
+	}
+	return exps
+}
+
+func (b *nodeBuilder) identifier(name string) *ast.Identifier {
+	return b.identifierFrom(b.require(name), name)
+}
+
+// identifierFrom は識別子の quote か、名前の文字列を識別子にする
+func (b *nodeBuilder) identifierFrom(value object.Object, name string) *ast.Identifier {
+	if value == nil || b.err != nil {
+		return nil
+	}
+	switch value := value.(type) {
+	case *object.String:
+		return &ast.Identifier{Token: b.token(token.IDENT, value.Value), Value: value.Value}
+	case *object.Quote:
+		if ident, ok := value.Node.(*ast.Identifier); ok {
+			return ident
+		}
+	}
+	b.fail("%s.%s: expected an Identifier or STRING, got %s", b.kind, name, describeValue(value))
+	return nil
+}
+
+func (b *nodeBuilder) identifiers(name string) []*ast.Identifier {
+	elements := b.array(name)
+	idents := make([]*ast.Identifier, 0, len(elements))
+	for _, element := range elements {
+		idents = append(idents, b.identifierFrom(element, name))
+	}
+	return idents
+}
+
+func (b *nodeBuilder) block(name string) *ast.BlockStatement {
+	return b.blockFrom(b.require(name), name)
+}
+
+func (b *nodeBuilder) optionalBlock(name string) *ast.BlockStatement {
+	value := b.optional(name)
+	if value == nil {
+		return nil
+	}
+	return b.blockFrom(value, name)
+}
+
+// blockFrom はブロックの quote か、文の配列をブロックにする
+func (b *nodeBuilder) blockFrom(value object.Object, name string) *ast.BlockStatement {
+	if value == nil || b.err != nil {
+		return nil
+	}
+	if quote, ok := value.(*object.Quote); ok {
+		if block, ok := quote.Node.(*ast.BlockStatement); ok {
+			return block
+		}
+	}
+	array, ok := value.(*object.Array)
+	if !ok {
+		b.fail("%s.%s: expected a BlockStatement or ARRAY, got %s", b.kind, name, describeValue(value))
+		return nil
+	}
+	return &ast.BlockStatement{Token: b.token(token.LBRACE, "{"), Statements: b.statementsFrom(array.Elements, name)}
+}
+
+func (b *nodeBuilder) statements(name string) []ast.Statement {
+	return b.statementsFrom(b.array(name), name)
+}
+
+// statementsFrom は文の quote と、式文にする値を文の並びにする
+func (b *nodeBuilder) statementsFrom(elements []object.Object, name string) []ast.Statement {
+	statements := make([]ast.Statement, 0, len(elements))
+	for _, element := range elements {
+		if quote, ok := element.(*object.Quote); ok {
+			if stmt, ok := quote.Node.(ast.Statement); ok {
+				statements = append(statements, stmt)
+				continue
+			}
+		}
+		exp := b.expressionFrom(element, name)
+		if exp == nil {
+			return statements
+		}
+		statements = append(statements, &ast.ExpressionStatement{Token: b.expressionToken(exp), Expression: exp})
+	}
+	return statements
+}
+
+func (b *nodeBuilder) array(name string) []object.Object {
+	value := b.require(name)
+	if value == nil || b.err != nil {
+		return nil
+	}
+	array, ok := value.(*object.Array)
+	if !ok {
+		b.fail("%s.%s: expected ARRAY, got %s", b.kind, name, describeValue(value))
+		return nil
+	}
+	return array.Elements
+}
+
+// pairs はキーと値の組のハッシュの配列を、ハッシュリテラルの組にする
+func (b *nodeBuilder) pairs(name string) []ast.HashPair {
+	elements := b.array(name)
+	pairs := make([]ast.HashPair, 0, len(elements))
+	for _, element := range elements {
+		hash, ok := element.(*object.Hash)
+		if !ok {
+			b.fail("%s.%s: expected HASH, got %s", b.kind, name, describeValue(element))
+			return pairs
+		}
+		pair := &nodeBuilder{kind: "HashPair", fields: make(map[string]object.Object), used: make(map[string]bool), pos: b.pos}
+		for _, p := range hash.Pairs {
+			if key, ok := p.Key.(*object.String); ok {
+				pair.fields[key.Value] = p.Value
+			}
+		}
+		key, value := pair.expression("key"), pair.expression("value")
+		if pair.err != nil {
+			b.fail("%s", pair.err.Message)
+			return pairs
+		}
+		pairs = append(pairs, ast.HashPair{Key: key, Value: value})
+	}
+	return pairs
+}
+
+func (b *nodeBuilder) operator(valid map[string]bool) string {
+	value := b.require("operator")
+	if value == nil {
+		return ""
+	}
+	op, ok := value.(*object.String)
+	if !ok || !valid[op.Value] {
+		b.fail("%s.operator: invalid operator %s", b.kind, value.Inspect())
+		return ""
+	}
+	return op.Value
+}
+
+// literal は値からリテラルのノードを作る。値の型は kind に合っていなければならない
+func (b *nodeBuilder) literal(kind string) ast.Node {
+	value := b.require("value")
+	if value == nil {
+		return nil
+	}
+	want := map[string]object.Type{
+		"IntegerLiteral": object.INTEGER_OBJ,
+		"Boolean":        object.BOOLEAN_OBJ,
+		"StringLiteral":  object.STRING_OBJ,
+	}[kind]
+	if value.Type() != want {
+		b.fail("%s.value: expected %s, got %s", kind, want, value.Type())
+		return nil
+	}
+	node, _ := convertObjectToASTNode(value, b.pos)
+	return node
+}
+
+// describeValue はエラーメッセージのための値の説明。quote ならノードの種類を返す
+func describeValue(value object.Object) string {
+	if quote, ok := value.(*object.Quote); ok {
+		kind, _ := nodeFields(quote.Node)
+		return kind
+	}
+	return string(value.Type())
+}
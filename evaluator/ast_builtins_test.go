@@ -0,0 +1,159 @@
+package evaluator
+
+import (
+	"testing"
+)
+
+func TestNodeKind(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`node_kind(quote(x))`, `Identifier`},
+		{`node_kind(quote(1))`, `IntegerLiteral`},
+		{`node_kind(quote(true))`, `Boolean`},
+		{`node_kind(quote("s"))`, `StringLiteral`},
+		{`node_kind(quote(-x))`, `PrefixExpression`},
+		{`node_kind(quote(1 + 2))`, `InfixExpression`},
+		{`node_kind(quote(if (x) { 1 }))`, `IfExpression`},
+		{`node_kind(quote(fn(x) { x }))`, `FunctionLiteral`},
+		{`node_kind(quote(f(1)))`, `CallExpression`},
+		{`node_kind(quote([1]))`, `ArrayLiteral`},
+		{`node_kind(quote(a[0]))`, `IndexExpression`},
+		{`node_kind(quote(a[1:]))`, `SliceExpression`},
+		{`node_kind(quote({"a": 1}))`, `HashLiteral`},
+		{`node_kind(node_field(quote(fn() { 1 }), "body"))`, `BlockStatement`},
+		{`node_kind(1)`, "ERROR: 1:10: argument 1 to `node_kind` must be QUOTE, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testASTBuiltin(t, tt.input, tt.expected)
+	}
+}
+
+func TestNodeField(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`node_field(quote(x), "value")`, `x`},
+		{`node_field(quote(42), "value")`, `42`},
+		{`node_field(quote(false), "value")`, `false`},
+		{`node_field(quote("s"), "value")`, `s`},
+		{`node_field(quote(!x), "operator")`, `!`},
+		{`node_field(quote(1 + 2 * 3), "operator")`, `+`},
+		{`node_field(quote(1 + 2 * 3), "left")`, `QUOTE(1)`},
+		{`node_field(quote(1 + 2 * 3), "right")`, `QUOTE((2 * 3))`},
+		{`node_field(quote(f(a, 1)), "function")`, `QUOTE(f)`},
+		{`node_field(quote(f(a, 1)), "arguments")`, `[QUOTE(a), QUOTE(1)]`},
+		{`node_field(quote(fn(a, b) { a }), "parameters")`, `[QUOTE(a), QUOTE(b)]`},
+		{`node_field(quote(if (x) { 1 }), "alternative")`, `null`},
+		{`node_field(quote(a[:2]), "low")`, `null`},
+		{`node_field(quote({"a": 1}), "pairs")[0]["key"]`, `QUOTE(a)`},
+		{`node_field(quote({"a": 1}), "pairs")[0]["value"]`, `QUOTE(1)`},
+		{`len(node_field(node_field(quote(fn() { let a = 1; a }), "body"), "statements"))`, `2`},
+		{`node_field(quote(x), "operator")`, `ERROR: 1:11: Identifier: unknown field "operator"`},
+		{`node_field(quote(x), 1)`, "ERROR: 1:11: argument 2 to `node_field` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testASTBuiltin(t, tt.input, tt.expected)
+	}
+}
+
+func TestMakeNode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`make_node("Identifier", {"value": "x"})`, `QUOTE(x)`},
+		{`make_node("IntegerLiteral", {"value": 3})`, `QUOTE(3)`},
+		{`make_node("PrefixExpression", {"operator": "-", "right": quote(x)})`, `QUOTE((-x))`},
+		{`make_node("InfixExpression", {"operator": "*", "left": quote(a + b), "right": 2})`, `QUOTE(((a + b) * 2))`},
+		{`make_node("CallExpression", {"function": quote(f), "arguments": [1, quote(x), "s"]})`, `QUOTE(f(1, x, s))`},
+		{`make_node("ArrayLiteral", {"elements": []})`, `QUOTE([])`},
+		{`make_node("IndexExpression", {"left": quote(a), "index": 0})`, `QUOTE((a[0]))`},
+		{`make_node("SliceExpression", {"left": quote(a), "high": 2})`, `QUOTE((a[:2]))`},
+		{`make_node("HashLiteral", {"pairs": [{"key": "k", "value": quote(v)}]})`, `QUOTE({k:v})`},
+		{`make_node("IfExpression", {"condition": quote(x), "consequence": [1], "alternative": [2]})`, `QUOTE(if x 1 else 2)`},
+		{
+			`make_node("FunctionLiteral", {"parameters": ["a", quote(b)], "body": [make_node("LetStatement", {"name": "c", "value": quote(a + b)}), quote(c)]})`,
+			`QUOTE(fn(a, b) let c = (a + b);c)`,
+		},
+		{`make_node("FunctionLiteral", {"parameters": [], "body": node_field(quote(fn() { 1 }), "body")})`, `QUOTE(fn() 1)`},
+		{
+			`make_node("FunctionLiteral", {"parameters": [], "body": [make_node("ReturnStatement", {"returnValue": 1})]})`,
+			`QUOTE(fn() return 1;)`,
+		},
+		{`make_node("InfixExpression", {"operator": "+", "left": 1})`, `ERROR: 1:10: InfixExpression: missing field "right"`},
+		{`make_node("InfixExpression", {"operator": "%", "left": 1, "right": 2})`, `ERROR: 1:10: InfixExpression.operator: invalid operator %`},
+		{`make_node("Identifier", {"value": "x", "name": "y"})`, `ERROR: 1:10: Identifier: unknown field "name"`},
+		{`make_node("IntegerLiteral", {"value": "1"})`, `ERROR: 1:10: IntegerLiteral.value: expected INTEGER, got STRING`},
+		{`make_node("LetStatement", {"name": 1, "value": 1})`, `ERROR: 1:10: LetStatement.name: expected an Identifier or STRING, got INTEGER`},
+		{`make_node("LetStatement", {"name": quote(1), "value": 1})`, `ERROR: 1:10: LetStatement.name: expected an Identifier or STRING, got IntegerLiteral`},
+		{`make_node("CallExpression", {"function": quote(f), "arguments": 1})`, `ERROR: 1:10: CallExpression.arguments: expected ARRAY, got INTEGER`},
+		{`make_node("CallExpression", {"function": puts, "arguments": []})`, `ERROR: 1:10: CallExpression.function: cannot unquote BUILTIN`},
+		{`make_node("Program", {"statements": []})`, `ERROR: 1:10: cannot make Program`},
+		{`make_node("Foo", {})`, `ERROR: 1:10: unknown node kind "Foo"`},
+	}
+
+	for _, tt := range tests {
+		testASTBuiltin(t, tt.input, tt.expected)
+	}
+}
+
+func TestASTBuiltinsInMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			// 二項演算の左右を入れ替える
+			input: `
+let swap = macro(e) {
+  if (starts_with(node_kind(e), "Infix")) {
+    make_node("InfixExpression", {"operator": node_field(e, "operator"), "left": node_field(e, "right"), "right": node_field(e, "left")})
+  } else {
+    e
+  }
+};
+[swap(10 - 3), swap(7)]`,
+			expected: `[-7, 7]`,
+		},
+		{
+			// 呼び出しの引数をそれぞれ 2 倍にする
+			input: `
+let double_args = macro(call) {
+  let args = map(node_field(call, "arguments"), fn(arg) { make_node("InfixExpression", {"operator": "*", "left": arg, "right": 2}) });
+  make_node("CallExpression", {"function": node_field(call, "function"), "arguments": args})
+};
+let add = fn(a, b) { a + b };
+double_args(add(1, 2))`,
+			expected: `6`,
+		},
+		{
+			// 識別子なら名前を文字列にする
+			input: `
+let name_of = macro(x) { make_node("StringLiteral", {"value": node_field(x, "value")}) };
+name_of(foo)`,
+			expected: `foo`,
+		},
+	}
+
+	for _, tt := range tests {
+		testASTBuiltin(t, tt.input, tt.expected)
+	}
+}
+
+func testASTBuiltin(t *testing.T, input string, expected string) {
+	t.Helper()
+	ip := New(nil, nil)
+	expanded, errors := ip.ExpandMacros(testParseProgram(input))
+	if len(errors) != 0 {
+		t.Fatalf("case: %s. macro errors: %v", input, errors)
+	}
+	evaluated := ip.Eval(expanded, ip.NewEnvironment())
+	if evaluated.Inspect() != expected {
+		t.Errorf("case: %s. expected=%q, got=%q", input, expected, evaluated.Inspect())
+	}
+}
@@ -3,24 +3,27 @@ package evaluator
 import (
 	"fmt"
 	"github.com/care0717/monkey-interpreter/object"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
-	"len": {Fn: func(args ...object.Object) object.Object {
+	"len": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
 		if len(args) != 1 {
 			return newError("wrong number of arguments. got=%d, want=1", len(args))
 		}
 
 		switch arg := args[0].(type) {
 		case *object.String:
-			return &object.Integer{Value: int64(len(arg.Value))}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 		case *object.Array:
 			return &object.Integer{Value: int64(len(arg.Elements))}
+		case *object.Hash:
+			return &object.Integer{Value: int64(len(arg.Pairs))}
 		default:
 			return newError("argument to `len` not supported, got %s", arg.Type())
 		}
 	}},
-	"first": {Fn: func(args ...object.Object) object.Object {
+	"first": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
 		if len(args) != 1 {
 			return newError("wrong number of arguments. got=%d, want=1", len(args))
 		}
@@ -32,11 +35,16 @@ var builtins = map[string]*object.Builtin{
 			} else {
 				return object.NULL
 			}
+		case *object.String:
+			for _, r := range arg.Value {
+				return &object.String{Value: string(r)}
+			}
+			return object.NULL
 		default:
 			return newError("argument to `first` not supported, got %s", arg.Type())
 		}
 	}},
-	"last": {Fn: func(args ...object.Object) object.Object {
+	"last": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
 		if len(args) != 1 {
 			return newError("wrong number of arguments. got=%d, want=1", len(args))
 		}
@@ -49,11 +57,16 @@ var builtins = map[string]*object.Builtin{
 			} else {
 				return object.NULL
 			}
+		case *object.String:
+			if r, size := utf8.DecodeLastRuneInString(arg.Value); size > 0 {
+				return &object.String{Value: string(r)}
+			}
+			return object.NULL
 		default:
 			return newError("argument to `last` not supported, got %s", arg.Type())
 		}
 	}},
-	"rest": {Fn: func(args ...object.Object) object.Object {
+	"rest": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
 		if len(args) != 1 {
 			return newError("wrong number of arguments. got=%d, want=1", len(args))
 		}
@@ -61,14 +74,23 @@ var builtins = map[string]*object.Builtin{
 		switch arg := args[0].(type) {
 		case *object.Array:
 			length := len(arg.Elements)
+			if length == 0 {
+				return object.NULL
+			}
 			newElements := make([]object.Object, length-1, length-1)
 			copy(newElements, arg.Elements[1:length])
 			return &object.Array{Elements: newElements}
+		case *object.String:
+			if len(arg.Value) == 0 {
+				return object.NULL
+			}
+			_, size := utf8.DecodeRuneInString(arg.Value)
+			return &object.String{Value: arg.Value[size:]}
 		default:
 			return newError("argument to `rest` not supported, got %s", arg.Type())
 		}
 	}},
-	"push": {Fn: func(args ...object.Object) object.Object {
+	"push": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
 		if len(args) != 2 {
 			return newError("wrong number of arguments. got=%d, want=2", len(args))
 		}
@@ -84,9 +106,9 @@ var builtins = map[string]*object.Builtin{
 			return newError("argument to `push` not supported, got %s", arg.Type())
 		}
 	}},
-	"puts": {Fn: func(args ...object.Object) object.Object {
+	"puts": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
 		for _, arg := range args {
-			fmt.Println(arg.Inspect())
+			fmt.Fprintln(ctx.Out(), arg.Inspect())
 		}
 		return object.NULL
 	}},
@@ -0,0 +1,341 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/object"
+	"sort"
+	"strings"
+)
+
+func init() {
+	for name, builtin := range collectionBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// コールバックには object.Function と object.Builtin のどちらも渡せる
+var collectionBuiltins = map[string]*object.Builtin{
+	"map": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkCallbackArgs("map", args); err != nil {
+			return err
+		}
+
+		elements := elementsOf(args[0])
+		result := make([]object.Object, len(elements))
+		for i, e := range elements {
+			mapped := ctx.Apply(args[1], e)
+			if isError(mapped) {
+				return mapped
+			}
+			result[i] = mapped
+		}
+		return &object.Array{Elements: result}
+	}},
+	"filter": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkCallbackArgs("filter", args); err != nil {
+			return err
+		}
+
+		result := []object.Object{}
+		for _, e := range elementsOf(args[0]) {
+			ok := ctx.Apply(args[1], e)
+			if isError(ok) {
+				return ok
+			}
+			if isTruthy(ok) {
+				result = append(result, e)
+			}
+		}
+		return &object.Array{Elements: result}
+	}},
+	"reduce": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if len(args) != 3 {
+			return newError("wrong number of arguments. got=%d, want=3", len(args))
+		}
+		if err := checkCallbackArgs("reduce", []object.Object{args[0], args[2]}); err != nil {
+			return err
+		}
+
+		acc := args[1]
+		for _, e := range elementsOf(args[0]) {
+			acc = ctx.Apply(args[2], acc, e)
+			if isError(acc) {
+				return acc
+			}
+		}
+		return acc
+	}},
+	"each": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkCallbackArgs("each", args); err != nil {
+			return err
+		}
+
+		for _, e := range elementsOf(args[0]) {
+			if result := ctx.Apply(args[1], e); isError(result) {
+				return result
+			}
+		}
+		return object.NULL
+	}},
+	"find": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkCallbackArgs("find", args); err != nil {
+			return err
+		}
+
+		for _, e := range elementsOf(args[0]) {
+			ok := ctx.Apply(args[1], e)
+			if isError(ok) {
+				return ok
+			}
+			if isTruthy(ok) {
+				return e
+			}
+		}
+		return object.NULL
+	}},
+	"any": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkCallbackArgs("any", args); err != nil {
+			return err
+		}
+
+		for _, e := range elementsOf(args[0]) {
+			ok := ctx.Apply(args[1], e)
+			if isError(ok) {
+				return ok
+			}
+			if isTruthy(ok) {
+				return object.TRUE
+			}
+		}
+		return object.FALSE
+	}},
+	"all": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkCallbackArgs("all", args); err != nil {
+			return err
+		}
+
+		for _, e := range elementsOf(args[0]) {
+			ok := ctx.Apply(args[1], e)
+			if isError(ok) {
+				return ok
+			}
+			if !isTruthy(ok) {
+				return object.FALSE
+			}
+		}
+		return object.TRUE
+	}},
+	"sort": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if len(args) != 1 && len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+		}
+		array, ok := args[0].(*object.Array)
+		if !ok {
+			return newError("argument 1 to `sort` must be ARRAY, got %s", args[0].Type())
+		}
+
+		var less func(a, b object.Object) object.Object
+		if len(args) == 2 {
+			if !isCallable(args[1]) {
+				return newError("argument 2 to `sort` must be FUNCTION, got %s", args[1].Type())
+			}
+			less = func(a, b object.Object) object.Object {
+				return ctx.Apply(args[1], a, b)
+			}
+		} else {
+			less = defaultLess
+		}
+
+		// 比較中に起きた最初のエラーを覚えておき、ソート後に返す
+		var sortErr object.Object
+		sorted := make([]object.Object, len(array.Elements))
+		copy(sorted, array.Elements)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if sortErr != nil {
+				return false
+			}
+			result := less(sorted[i], sorted[j])
+			if isError(result) {
+				sortErr = result
+				return false
+			}
+			return isTruthy(result)
+		})
+		if sortErr != nil {
+			return sortErr
+		}
+		return &object.Array{Elements: sorted}
+	}},
+	"reverse": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("reverse", args, object.ARRAY_OBJ); err != nil {
+			return err
+		}
+
+		elements := args[0].(*object.Array).Elements
+		length := len(elements)
+		reversed := make([]object.Object, length)
+		for i, e := range elements {
+			reversed[length-1-i] = e
+		}
+		return &object.Array{Elements: reversed}
+	}},
+	"zip": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if len(args) < 1 {
+			return newError("wrong number of arguments. got=%d, want=1 or more", len(args))
+		}
+
+		length := -1
+		for i, arg := range args {
+			array, ok := arg.(*object.Array)
+			if !ok {
+				return newError("argument %d to `zip` must be ARRAY, got %s", i+1, arg.Type())
+			}
+			if length < 0 || len(array.Elements) < length {
+				length = len(array.Elements)
+			}
+		}
+
+		// 最も短い配列の長さに揃える
+		zipped := make([]object.Object, length)
+		for i := range zipped {
+			tuple := make([]object.Object, len(args))
+			for j, arg := range args {
+				tuple[j] = arg.(*object.Array).Elements[i]
+			}
+			zipped[i] = &object.Array{Elements: tuple}
+		}
+		return &object.Array{Elements: zipped}
+	}},
+	"range": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		var start, end, step int64 = 0, 0, 1
+		for i, arg := range args {
+			if arg.Type() != object.INTEGER_OBJ {
+				return newError("argument %d to `range` must be INTEGER, got %s", i+1, arg.Type())
+			}
+		}
+		switch len(args) {
+		case 1:
+			end = args[0].(*object.Integer).Value
+		case 2:
+			start = args[0].(*object.Integer).Value
+			end = args[1].(*object.Integer).Value
+		case 3:
+			start = args[0].(*object.Integer).Value
+			end = args[1].(*object.Integer).Value
+			step = args[2].(*object.Integer).Value
+		default:
+			return newError("wrong number of arguments. got=%d, want=1, 2 or 3", len(args))
+		}
+		if step == 0 {
+			return newError("step to `range` must not be 0")
+		}
+
+		count := rangeLength(start, end, step)
+		if count > maxRangeLength {
+			return newError("result of `range` too long: %d elements", count)
+		}
+		elements := make([]object.Object, count)
+		for i := range elements {
+			elements[i] = &object.Integer{Value: start}
+			start += step
+		}
+		return &object.Array{Elements: elements}
+	}},
+	"flatten": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("flatten", args, object.ARRAY_OBJ); err != nil {
+			return err
+		}
+		return &object.Array{Elements: flatten(args[0].(*object.Array).Elements, []object.Object{})}
+	}},
+	"uniq": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("uniq", args, object.ARRAY_OBJ); err != nil {
+			return err
+		}
+
+		seen := make(map[object.HashKey]bool)
+		result := []object.Object{}
+		for _, e := range args[0].(*object.Array).Elements {
+			hashable, ok := e.(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", e.Type())
+			}
+			key := hashable.HashKey()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result = append(result, e)
+		}
+		return &object.Array{Elements: result}
+	}},
+}
+
+// checkCallbackArgs は (ARRAY または STRING, FUNCTION) の形の引数を検査する
+func checkCallbackArgs(name string, args []object.Object) *object.Error {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	if args[0].Type() != object.ARRAY_OBJ && args[0].Type() != object.STRING_OBJ {
+		return newError("argument 1 to `%s` must be ARRAY or STRING, got %s", name, args[0].Type())
+	}
+	if !isCallable(args[1]) {
+		return newError("argument 2 to `%s` must be FUNCTION, got %s", name, args[1].Type())
+	}
+	return nil
+}
+
+// elementsOf は配列の要素か、文字列を1文字ずつに分けたものを返す
+func elementsOf(obj object.Object) []object.Object {
+	if str, ok := obj.(*object.String); ok {
+		return stringsToArray(strings.Split(str.Value, "")).Elements
+	}
+	return obj.(*object.Array).Elements
+}
+
+func isCallable(obj object.Object) bool {
+	switch obj.Type() {
+	case object.FUNCTION_OBJ, object.BUILTIN_OBJ:
+		return true
+	default:
+		return false
+	}
+}
+
+// defaultLess は比較関数が渡されなかったときの順序で、整数と文字列を比較できる
+func defaultLess(a, b object.Object) object.Object {
+	if a.Type() == object.STRING_OBJ && b.Type() == object.STRING_OBJ {
+		return nativeBoolToBooleanObject(a.(*object.String).Value < b.(*object.String).Value)
+	}
+	return evalInfixExpression("<", a, b)
+}
+
+// maxRangeLength は range が作る配列の長さの上限
+const maxRangeLength = 1 << 24
+
+// rangeLength は range(start, end, step) の要素の数。int64 では差があふれるので符号なしで数える
+func rangeLength(start, end, step int64) uint64 {
+	var span, stride uint64
+	switch {
+	case step > 0 && start < end:
+		span, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && start > end:
+		span, stride = uint64(start)-uint64(end), -uint64(step)
+	default:
+		return 0
+	}
+	count := span / stride
+	if span%stride != 0 {
+		count++
+	}
+	return count
+}
+
+func flatten(elements []object.Object, result []object.Object) []object.Object {
+	for _, e := range elements {
+		if array, ok := e.(*object.Array); ok {
+			result = flatten(array.Elements, result)
+		} else {
+			result = append(result, e)
+		}
+	}
+	return result
+}
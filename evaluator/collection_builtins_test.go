@@ -0,0 +1,182 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/object"
+	"math"
+	"testing"
+)
+
+func TestCollectionBuiltins(t *testing.T) {
+	one := &object.Integer{Value: 1}
+	two := &object.Integer{Value: 2}
+	three := &object.Integer{Value: 3}
+	array := func(elements ...object.Object) *object.Array {
+		return &object.Array{Elements: elements}
+	}
+
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{
+			input:    `map([1, 2, 3], fn(x) { x * 2 })`,
+			expected: array(two, &object.Integer{Value: 4}, &object.Integer{Value: 6}),
+		},
+		{
+			input:    `map([1, 2], len)`,
+			expected: &object.Error{Message: "argument to `len` not supported, got INTEGER"},
+		},
+		{
+			input:    `map([1, 2], 1)`,
+			expected: &object.Error{Message: "argument 2 to `map` must be FUNCTION, got INTEGER"},
+		},
+		{
+			input:    `map(1, fn(x) { x })`,
+			expected: &object.Error{Message: "argument 1 to `map` must be ARRAY or STRING, got INTEGER"},
+		},
+		{
+			input:    `map([1, 2], fn(x, i) { x })`,
+			expected: &object.Error{Message: "wrong number of arguments. got=1, want=2"},
+		},
+		{
+			input:    `map([1, 2], fn(x) { x + true })`,
+			expected: &object.Error{Message: "type mismatch: INTEGER + BOOLEAN"},
+		},
+		{
+			input: `map("aあ", fn(c) { c + c })`,
+			expected: array(
+				&object.String{Value: "aa"},
+				&object.String{Value: "ああ"},
+			),
+		},
+		{
+			input:    `reduce("abc", "", fn(acc, c) { c + acc })`,
+			expected: &object.String{Value: "cba"},
+		},
+		{
+			input:    `filter([1, 2, 3], fn(x) { x > 1 })`,
+			expected: array(two, three),
+		},
+		{
+			input:    `filter([1, 2, 3], fn(x) { false })`,
+			expected: &object.Array{Elements: []object.Object{}},
+		},
+		{
+			input:    `reduce([1, 2, 3], 0, fn(acc, x) { acc + x })`,
+			expected: &object.Integer{Value: 6},
+		},
+		{
+			input:    `reduce([], 10, fn(acc, x) { acc + x })`,
+			expected: &object.Integer{Value: 10},
+		},
+		{
+			input:    `each([1, 2], fn(x) { x })`,
+			expected: object.NULL,
+		},
+		{
+			input:    `find([1, 2, 3], fn(x) { x > 1 })`,
+			expected: two,
+		},
+		{
+			input:    `find([1, 2, 3], fn(x) { x > 3 })`,
+			expected: object.NULL,
+		},
+		{
+			input:    `any([1, 2, 3], fn(x) { x == 2 })`,
+			expected: object.TRUE,
+		},
+		{
+			input:    `all([1, 2, 3], fn(x) { x < 3 })`,
+			expected: object.FALSE,
+		},
+		{
+			input:    `all([], fn(x) { false })`,
+			expected: object.TRUE,
+		},
+		{
+			input:    `sort([3, 1, 2])`,
+			expected: array(one, two, three),
+		},
+		{
+			input: `sort(["b", "c", "a"])`,
+			expected: array(
+				&object.String{Value: "a"},
+				&object.String{Value: "b"},
+				&object.String{Value: "c"},
+			),
+		},
+		{
+			input:    `sort([3, 1, 2], fn(a, b) { a > b })`,
+			expected: array(three, two, one),
+		},
+		{
+			input:    `sort([1, true])`,
+			expected: &object.Error{Message: "type mismatch: BOOLEAN < INTEGER"},
+		},
+		{
+			input:    `let a = [3, 1, 2]; sort(a); a`,
+			expected: array(three, one, two),
+		},
+		{
+			input:    `reverse([1, 2, 3])`,
+			expected: array(three, two, one),
+		},
+		{
+			input:    `zip([1, 2, 3], ["a", "b"])`,
+			expected: array(array(one, &object.String{Value: "a"}), array(two, &object.String{Value: "b"})),
+		},
+		{
+			input:    `zip([1], 2)`,
+			expected: &object.Error{Message: "argument 2 to `zip` must be ARRAY, got INTEGER"},
+		},
+		{
+			input:    `range(3)`,
+			expected: array(&object.Integer{Value: 0}, one, two),
+		},
+		{
+			input:    `range(1, 3)`,
+			expected: array(one, two),
+		},
+		{
+			input:    `range(3, 0, -1)`,
+			expected: array(three, two, one),
+		},
+		{
+			input:    `range(0, 9223372036854775807, 4611686018427387904)`,
+			expected: array(&object.Integer{Value: 0}, &object.Integer{Value: 4611686018427387904}),
+		},
+		{
+			input:    `range(0, -9223372036854775807, -4611686018427387904)`,
+			expected: array(&object.Integer{Value: 0}, &object.Integer{Value: -4611686018427387904}),
+		},
+		{
+			input:    `range(-9223372036854775807 - 1, 9223372036854775807, 9223372036854775807)`,
+			expected: array(&object.Integer{Value: math.MinInt64}, &object.Integer{Value: -1}, &object.Integer{Value: math.MaxInt64 - 1}),
+		},
+		{
+			input:    `range(1000000000000)`,
+			expected: &object.Error{Message: "result of `range` too long: 1000000000000 elements"},
+		},
+		{
+			input:    `range(0, 3, 0)`,
+			expected: &object.Error{Message: "step to `range` must not be 0"},
+		},
+		{
+			input:    `flatten([1, [2, [3]], []])`,
+			expected: array(one, two, three),
+		},
+		{
+			input:    `uniq([1, 2, 1, 3, 2])`,
+			expected: array(one, two, three),
+		},
+		{
+			input:    `uniq([[1]])`,
+			expected: &object.Error{Message: "unusable as hash key: ARRAY"},
+		},
+	}
+	if errors := testEval(tests); errors != nil {
+		for _, err := range errors {
+			t.Error(err)
+		}
+	}
+}
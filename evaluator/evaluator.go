@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/care0717/monkey-interpreter/ast"
 	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/token"
 )
 
 func isError(obj object.Object) bool {
@@ -13,12 +14,12 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func Eval(node ast.Node, env object.Environment) object.Object {
+func (ip *Interpreter) Eval(node ast.Node, env object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
-		return evalProgram(node, env)
+		return ip.evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return ip.Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 	case *ast.Boolean:
@@ -26,84 +27,97 @@ func Eval(node ast.Node, env object.Environment) object.Object {
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := ip.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := ip.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := ip.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return ip.evalBlockStatement(node, env)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return ip.evalIfExpression(node, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := ip.Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := ip.Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if err := ip.checkLet(node, val); err != nil {
+			return err
+		}
+		bind(env, node.Name, val)
+	case *ast.ImportStatement:
+		return newErrorAt(node.Token.Pos, "import is only allowed at the top level")
+	case *ast.ExportStatement:
+		return newErrorAt(node.Token.Pos, "export is only allowed at the top level")
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{
+			Parameters:     node.Parameters,
+			ParameterTypes: node.ParameterTypes,
+			ReturnType:     node.ReturnType,
+			Body:           node.Body,
+			Env:            env,
+		}
 	case *ast.CallExpression:
 		if node.Function.TokenLiteral() == "quote" {
-			return quote(node.Arguments[0], env)
+			return ip.quote(node.Arguments[0], env)
 		}
-		function := Eval(node.Function, env)
+		function := ip.Eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
+		args := ip.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return ip.applyFunction(function, args, node.Token.Pos)
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := ip.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := ip.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		index := Eval(node.Index, env)
+		index := ip.Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return ip.evalSliceExpression(node, env)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return ip.evalHashLiteral(node, env)
 	}
 	return nil
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env object.Environment) object.Object {
+func (ip *Interpreter) evalHashLiteral(node *ast.HashLiteral, env object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for _, pair := range node.Pairs {
-		key := Eval(pair.Key, env)
+		key := ip.Eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
@@ -113,7 +127,7 @@ func evalHashLiteral(node *ast.HashLiteral, env object.Environment) object.Objec
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(pair.Value, env)
+		value := ip.Eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
@@ -129,6 +143,8 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -153,26 +169,125 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	return pair.Value
 }
 
+// evalArrayIndexExpression は負のインデックスを末尾から数え、範囲外なら null を返す
 func evalArrayIndexExpression(array object.Object, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	length := int64(len(arrayObject.Elements))
 
-	if idx < 0 || idx > max {
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
 		return object.NULL
 	}
 
 	return arrayObject.Elements[idx]
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalStringIndexExpression はルーン単位で数えた位置の1文字を文字列として返す。
+// 配列と同じく負のインデックスは末尾から数え、範囲外なら null を返す
+func evalStringIndexExpression(str object.Object, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	length := int64(len(runes))
+
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return object.NULL
+	}
+
+	return &object.String{Value: string(runes[idx])}
+}
+
+func (ip *Interpreter) evalSliceExpression(node *ast.SliceExpression, env object.Environment) object.Object {
+	left := ip.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var bounds [2]object.Object
+	for i, exp := range []ast.Expression{node.Low, node.High} {
+		if exp == nil {
+			continue
+		}
+		bound := ip.Eval(exp, env)
+		if isError(bound) {
+			return bound
+		}
+		if bound.Type() != object.INTEGER_OBJ {
+			return newError("slice index must be INTEGER, got %s", bound.Type())
+		}
+		bounds[i] = bound
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		low, high := sliceBounds(bounds[0], bounds[1], len(left.Elements))
+		elements := make([]object.Object, high-low)
+		copy(elements, left.Elements[low:high])
+		return &object.Array{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		low, high := sliceBounds(bounds[0], bounds[1], len(runes))
+		return &object.String{Value: string(runes[low:high])}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// sliceBounds は省略された境界を両端とみなし、負の境界を末尾から数える。
+// 範囲外の境界は長さに切り詰め、low が high を超えるときは空の範囲にする
+func sliceBounds(lowObj, highObj object.Object, length int) (int, int) {
+	clamp := func(obj object.Object, def int) int {
+		if obj == nil {
+			return def
+		}
+		idx := obj.(*object.Integer).Value
+		if idx < 0 {
+			idx += int64(length)
+		}
+		if idx < 0 {
+			return 0
+		}
+		if idx > int64(length) {
+			return length
+		}
+		return int(idx)
+	}
+
+	low := clamp(lowObj, 0)
+	high := clamp(highObj, length)
+	if low > high {
+		low = high
+	}
+	return low, high
+}
+
+func (ip *Interpreter) applyFunction(fn object.Object, args []object.Object, pos token.Position) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		}
+		if err := ip.checkArguments(fn, args, pos); err != nil {
+			return err
+		}
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		evaluated := unwrapReturnValue(ip.Eval(fn.Body, extendedEnv))
+		if err := ip.checkReturn(fn, evaluated, pos); err != nil {
+			return err
+		}
+		return evaluated
 	case *object.Builtin:
-		return fn.Fn(args...)
+		result := fn.Fn(&callContext{ip: ip, pos: pos}, args...)
+		// 位置を持たないエラーには呼び出し位置をつける
+		if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+			err.Pos = pos
+		}
+		return result
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
@@ -182,12 +297,21 @@ func extendFunctionEnv(fn *object.Function, args []object.Object) object.Environ
 	env := object.NewEnclosedEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		bind(env, param, args[paramIdx])
 	}
 
 	return env
 }
 
+// bind は resolver がスロットを割り当てた変数をスロットに、それ以外を名前で束縛する
+func bind(env object.Environment, ident *ast.Identifier, val object.Object) {
+	if ident.Resolved != nil {
+		env.SetAt(ident.Resolved.Slot, val)
+		return
+	}
+	env.Set(ident.Value, val)
+}
+
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
@@ -195,11 +319,11 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalExpressions(expressions []ast.Expression, env object.Environment) []object.Object {
+func (ip *Interpreter) evalExpressions(expressions []ast.Expression, env object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, e := range expressions {
-		evaluated := Eval(e, env)
+		evaluated := ip.Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -210,6 +334,13 @@ func evalExpressions(expressions []ast.Expression, env object.Environment) []obj
 }
 
 func evalIdentifier(node *ast.Identifier, env object.Environment) object.Object {
+	if node.Resolved != nil {
+		if val, ok := env.GetAt(node.Resolved.Depth, node.Resolved.Slot); ok {
+			return val
+		}
+		return newErrorAt(node.Token.Pos, "identifier not found: "+node.Value)
+	}
+
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
@@ -218,19 +349,19 @@ func evalIdentifier(node *ast.Identifier, env object.Environment) object.Object
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newErrorAt(node.Token.Pos, "identifier not found: "+node.Value)
 }
 
-func evalIfExpression(ie *ast.IfExpression, env object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func (ip *Interpreter) evalIfExpression(ie *ast.IfExpression, env object.Environment) object.Object {
+	condition := ip.Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return ip.Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return ip.Eval(ie.Alternative, env)
 	} else {
 		return object.NULL
 	}
@@ -249,11 +380,11 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func evalProgram(program *ast.Program, env object.Environment) object.Object {
+func (ip *Interpreter) evalProgram(program *ast.Program, env object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = ip.evalTopLevelStatement(statement, env)
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
@@ -265,11 +396,11 @@ func evalProgram(program *ast.Program, env object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env object.Environment) object.Object {
+func (ip *Interpreter) evalBlockStatement(block *ast.BlockStatement, env object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = ip.Eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
@@ -380,3 +511,7 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
+
+func newErrorAt(pos token.Position, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Pos: pos}
+}
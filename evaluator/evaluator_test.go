@@ -8,6 +8,7 @@ import (
 	"github.com/care0717/monkey-interpreter/parser"
 	"github.com/care0717/monkey-interpreter/token"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"testing"
 )
 
@@ -524,6 +525,38 @@ func TestBuiltinFunctions(t *testing.T) {
 			input:    `len([1, "two"])`,
 			expected: &object.Integer{Value: 2},
 		},
+		{
+			input:    `len("おさる")`,
+			expected: &object.Integer{Value: 3},
+		},
+		{
+			input:    `first("おさる")`,
+			expected: &object.String{Value: "お"},
+		},
+		{
+			input:    `first("")`,
+			expected: object.NULL,
+		},
+		{
+			input:    `last("おさる")`,
+			expected: &object.String{Value: "る"},
+		},
+		{
+			input:    `last("")`,
+			expected: object.NULL,
+		},
+		{
+			input:    `rest("おさる")`,
+			expected: &object.String{Value: "さる"},
+		},
+		{
+			input:    `rest("")`,
+			expected: object.NULL,
+		},
+		{
+			input:    `rest([])`,
+			expected: object.NULL,
+		},
 	}
 	if errors := testEval(tests); errors != nil {
 		for _, err := range errors {
@@ -582,6 +615,14 @@ func TestArrayIndexExpression(t *testing.T) {
 		},
 		{
 			input:    `[1, 2, 3][-1]`,
+			expected: &object.Integer{Value: 3},
+		},
+		{
+			input:    `[1, 2, 3][-3]`,
+			expected: &object.Integer{Value: 1},
+		},
+		{
+			input:    `[1, 2, 3][-4]`,
 			expected: object.NULL,
 		},
 	}
@@ -592,6 +633,128 @@ func TestArrayIndexExpression(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{
+			input:    `"abc"[0]`,
+			expected: &object.String{Value: "a"},
+		},
+		{
+			input:    `"おさる"[1]`,
+			expected: &object.String{Value: "さ"},
+		},
+		{
+			input:    `"おさる"[-1]`,
+			expected: &object.String{Value: "る"},
+		},
+		{
+			input:    `"abc"[3]`,
+			expected: object.NULL,
+		},
+		{
+			input:    `"abc"[-4]`,
+			expected: object.NULL,
+		},
+		{
+			input:    `let s = "abc"; s[len(s) - 1]`,
+			expected: &object.String{Value: "c"},
+		},
+		{
+			input:    `"abc"["a"]`,
+			expected: &object.Error{Message: "index operator not supported: STRING"},
+		},
+	}
+	if errors := testEval(tests); errors != nil {
+		for _, err := range errors {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSliceExpression(t *testing.T) {
+	array := func(values ...int64) *object.Array {
+		elements := []object.Object{}
+		for _, v := range values {
+			elements = append(elements, &object.Integer{Value: v})
+		}
+		return &object.Array{Elements: elements}
+	}
+
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{
+			input:    `[1, 2, 3, 4][1:3]`,
+			expected: array(2, 3),
+		},
+		{
+			input:    `[1, 2, 3, 4][:2]`,
+			expected: array(1, 2),
+		},
+		{
+			input:    `[1, 2, 3, 4][2:]`,
+			expected: array(3, 4),
+		},
+		{
+			input:    `[1, 2, 3, 4][:]`,
+			expected: array(1, 2, 3, 4),
+		},
+		{
+			input:    `[1, 2, 3, 4][:-1]`,
+			expected: array(1, 2, 3),
+		},
+		{
+			input:    `[1, 2, 3, 4][-2:]`,
+			expected: array(3, 4),
+		},
+		{
+			input:    `[1, 2, 3, 4][1:100]`,
+			expected: array(2, 3, 4),
+		},
+		{
+			input:    `[1, 2, 3, 4][-100:1]`,
+			expected: array(1),
+		},
+		{
+			input:    `[1, 2, 3, 4][3:1]`,
+			expected: array(),
+		},
+		{
+			input:    `let a = [1, 2, 3]; let b = a[:]; a[0]`,
+			expected: &object.Integer{Value: 1},
+		},
+		{
+			input:    `"hello"[1:3]`,
+			expected: &object.String{Value: "el"},
+		},
+		{
+			input:    `"おさるさん"[-2:]`,
+			expected: &object.String{Value: "さん"},
+		},
+		{
+			input:    `"hello"[10:]`,
+			expected: &object.String{Value: ""},
+		},
+		{
+			input:    `[1, 2]["a":]`,
+			expected: &object.Error{Message: "slice index must be INTEGER, got STRING"},
+		},
+		{
+			input:    `{"a": 1}[0:1]`,
+			expected: &object.Error{Message: "slice operator not supported: HASH"},
+		},
+	}
+	if errors := testEval(tests); errors != nil {
+		for _, err := range errors {
+			t.Error(err)
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -792,6 +955,28 @@ quote(unquote(true == false))`,
 				Value: false,
 			}},
 		},
+		{
+			// 同じ quote を何度評価しても、前回の unquote の結果は残らない
+			input: `
+let f = fn(x) { quote(unquote(x)) };
+f(1);
+f(2)`,
+			expected: &object.Quote{Node: &ast.IntegerLiteral{
+				Token: token.Token{
+					Type:    token.INT,
+					Literal: "2",
+				},
+				Value: 2,
+			}},
+		},
+		{
+			input:    `quote(1 + unquote(missing))`,
+			expected: &object.Error{Message: "identifier not found: missing"},
+		},
+		{
+			input:    `quote(unquote(fn(x) { x }))`,
+			expected: &object.Error{Message: "cannot unquote FUNCTION"},
+		},
 	}
 	if errors := testEval(tests); errors != nil {
 		for _, err := range errors {
@@ -800,6 +985,69 @@ quote(unquote(true == false))`,
 	}
 }
 
+func TestUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `quote(unquote("a" + "b"))`,
+			expected: `QUOTE(ab)`,
+		},
+		{
+			input:    `quote(len(unquote([1, "a", [true]])))`,
+			expected: `QUOTE(len([1, a, [true]]))`,
+		},
+		{
+			input:    `quote(unquote({"b": 2, "a": [1]}))`,
+			expected: `QUOTE({a:[1], b:2})`,
+		},
+		{
+			input:    `quote(unquote(if (false) { 1 }))`,
+			expected: `QUOTE(if false )`,
+		},
+		{
+			input:    `quote(unquote([quote(x + y), 1]))`,
+			expected: `QUOTE([(x + y), 1])`,
+		},
+		{
+			input:    `let args = [quote(a), 2]; quote(f(0, unquote_splice(args), 3))`,
+			expected: `QUOTE(f(0, a, 2, 3))`,
+		},
+		{
+			input:    `quote([unquote_splice([]), unquote_splice(["a", quote(b)])])`,
+			expected: `QUOTE([a, b])`,
+		},
+		{
+			input:    `let stmts = [quote(puts(1)), quote(x)]; quote(fn() { unquote_splice(stmts); x })`,
+			expected: `QUOTE(fn() puts(1)xx)`,
+		},
+		{
+			input:    `quote(1 + unquote_splice([1]))`,
+			expected: `ERROR: 1:25: unquote_splice is only allowed in call arguments, array elements or block statements`,
+		},
+		{
+			input:    `quote(f(unquote_splice(1)))`,
+			expected: `ERROR: 1:23: argument to ` + "`unquote_splice`" + ` must be ARRAY, got INTEGER`,
+		},
+		{
+			input:    `quote(f(unquote_splice([1], [2])))`,
+			expected: `ERROR: 1:23: wrong number of arguments to ` + "`unquote_splice`" + `. got=2, want=1`,
+		},
+		{
+			input:    `quote(unquote([1, puts]))`,
+			expected: `ERROR: 1:14: cannot unquote BUILTIN`,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := New(nil, nil).Eval(testParseProgram(tt.input), object.NewEnvironment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("case: %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func testEval(tests []struct {
 	input    string
 	expected object.Object
@@ -819,9 +1067,15 @@ func testEval(tests []struct {
 	return errors
 }
 
+// ignorePos はトークンとエラーの位置を比較から外す。位置は個別のテストで検査する
+var ignorePos = cmp.Options{
+	cmpopts.IgnoreFields(token.Token{}, "Pos"),
+	cmpopts.IgnoreFields(object.Error{}, "Pos"),
+}
+
 func testObject(got, expected object.Object) error {
-	if !cmp.Equal(got, expected) {
-		return fmt.Errorf("%T diff %s[-got, +expected]", expected, cmp.Diff(got, expected))
+	if !cmp.Equal(got, expected, ignorePos) {
+		return fmt.Errorf("%T diff %s[-got, +expected]", expected, cmp.Diff(got, expected, ignorePos))
 	}
 	return nil
 }
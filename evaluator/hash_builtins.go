@@ -0,0 +1,153 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/object"
+	"sort"
+)
+
+func init() {
+	for name, builtin := range hashBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// object.Hash は順序を持たないので、keys、values、entries はキーの順に並べて返す
+// 更新系の関数は引数のハッシュを変更せず新しいハッシュを返す
+var hashBuiltins = map[string]*object.Builtin{
+	"keys": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("keys", args, object.HASH_OBJ); err != nil {
+			return err
+		}
+
+		keys := []object.Object{}
+		for _, pair := range sortedPairs(args[0].(*object.Hash)) {
+			keys = append(keys, pair.Key)
+		}
+		return &object.Array{Elements: keys}
+	}},
+	"values": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("values", args, object.HASH_OBJ); err != nil {
+			return err
+		}
+
+		values := []object.Object{}
+		for _, pair := range sortedPairs(args[0].(*object.Hash)) {
+			values = append(values, pair.Value)
+		}
+		return &object.Array{Elements: values}
+	}},
+	"entries": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("entries", args, object.HASH_OBJ); err != nil {
+			return err
+		}
+
+		entries := []object.Object{}
+		for _, pair := range sortedPairs(args[0].(*object.Hash)) {
+			entries = append(entries, &object.Array{Elements: []object.Object{pair.Key, pair.Value}})
+		}
+		return &object.Array{Elements: entries}
+	}},
+	"has_key": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		hash, ok := args[0].(*object.Hash)
+		if !ok {
+			return newError("argument 1 to `has_key` must be HASH, got %s", args[0].Type())
+		}
+		key, ok := args[1].(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", args[1].Type())
+		}
+
+		_, ok = hash.Pairs[key.HashKey()]
+		return nativeBoolToBooleanObject(ok)
+	}},
+	"delete": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		hash, ok := args[0].(*object.Hash)
+		if !ok {
+			return newError("argument 1 to `delete` must be HASH, got %s", args[0].Type())
+		}
+		key, ok := args[1].(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", args[1].Type())
+		}
+
+		deleted := copyHash(hash)
+		delete(deleted.Pairs, key.HashKey())
+		return deleted
+	}},
+	"put": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if len(args) != 3 {
+			return newError("wrong number of arguments. got=%d, want=3", len(args))
+		}
+		hash, ok := args[0].(*object.Hash)
+		if !ok {
+			return newError("argument 1 to `put` must be HASH, got %s", args[0].Type())
+		}
+		key, ok := args[1].(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", args[1].Type())
+		}
+
+		put := copyHash(hash)
+		put.Pairs[key.HashKey()] = object.HashPair{Key: args[1], Value: args[2]}
+		return put
+	}},
+	"merge": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if len(args) < 1 {
+			return newError("wrong number of arguments. got=%d, want=1 or more", len(args))
+		}
+
+		// 同じキーは後ろの引数の値で上書きする
+		merged := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+		for i, arg := range args {
+			hash, ok := arg.(*object.Hash)
+			if !ok {
+				return newError("argument %d to `merge` must be HASH, got %s", i+1, arg.Type())
+			}
+			for k, pair := range hash.Pairs {
+				merged.Pairs[k] = pair
+			}
+		}
+		return merged
+	}},
+}
+
+func copyHash(hash *object.Hash) *object.Hash {
+	pairs := make(map[object.HashKey]object.HashPair, len(hash.Pairs))
+	for k, pair := range hash.Pairs {
+		pairs[k] = pair
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+// sortedPairs はハッシュの組をキーの順に並べる。キーは型ごとにまとめ、整数は数の順、文字列は辞書順、真偽値は false を先にする
+func sortedPairs(hash *object.Hash) []object.HashPair {
+	pairs := make([]object.HashPair, 0, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return keyLess(pairs[i].Key, pairs[j].Key)
+	})
+	return pairs
+}
+
+func keyLess(a, b object.Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+	switch a := a.(type) {
+	case *object.Integer:
+		return a.Value < b.(*object.Integer).Value
+	case *object.String:
+		return a.Value < b.(*object.String).Value
+	case object.Boolean:
+		return !a.Value() && b.(object.Boolean).Value()
+	}
+	return false
+}
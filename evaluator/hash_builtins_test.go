@@ -0,0 +1,114 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/object"
+	"testing"
+)
+
+func TestHashBuiltins(t *testing.T) {
+	a := &object.String{Value: "a"}
+	b := &object.String{Value: "b"}
+	one := &object.Integer{Value: 1}
+	two := &object.Integer{Value: 2}
+	hash := func(pairs ...object.HashPair) *object.Hash {
+		h := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+		for _, pair := range pairs {
+			h.Pairs[pair.Key.(object.Hashable).HashKey()] = pair
+		}
+		return h
+	}
+
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{
+			input:    `len({"a": 1, "b": 2})`,
+			expected: two,
+		},
+		{
+			input:    `sort(keys({"b": 1, "a": 2}))`,
+			expected: &object.Array{Elements: []object.Object{a, b}},
+		},
+		{
+			input:    `keys({"b": 1, "a": 2, "c": 3})`,
+			expected: &object.Array{Elements: []object.Object{a, b, &object.String{Value: "c"}}},
+		},
+		{
+			input:    `values({"b": 1, "a": 2, "c": 3})`,
+			expected: &object.Array{Elements: []object.Object{two, one, &object.Integer{Value: 3}}},
+		},
+		{
+			input: `entries({"b": 1, "a": 2})`,
+			expected: &object.Array{Elements: []object.Object{
+				&object.Array{Elements: []object.Object{a, two}},
+				&object.Array{Elements: []object.Object{b, one}},
+			}},
+		},
+		{
+			input: `keys({2: 1, 10: 2, "b": 3, "a": 4, true: 5, false: 6})`,
+			expected: &object.Array{Elements: []object.Object{
+				object.FALSE, object.TRUE, two, &object.Integer{Value: 10}, a, b,
+			}},
+		},
+		{
+			input:    `keys({})`,
+			expected: &object.Array{Elements: []object.Object{}},
+		},
+		{
+			input:    `keys([])`,
+			expected: &object.Error{Message: "argument 1 to `keys` must be HASH, got ARRAY"},
+		},
+		{
+			input:    `sort(values({"b": 1, "a": 2}))`,
+			expected: &object.Array{Elements: []object.Object{one, two}},
+		},
+		{
+			input: `entries({"a": 1})`,
+			expected: &object.Array{Elements: []object.Object{
+				&object.Array{Elements: []object.Object{a, one}},
+			}},
+		},
+		{
+			input:    `has_key({"a": 1}, "a")`,
+			expected: object.TRUE,
+		},
+		{
+			input:    `has_key({"a": 1}, "b")`,
+			expected: object.FALSE,
+		},
+		{
+			input:    `has_key({"a": 1}, [])`,
+			expected: &object.Error{Message: "unusable as hash key: ARRAY"},
+		},
+		{
+			input:    `delete({"a": 1, "b": 2}, "a")`,
+			expected: hash(object.HashPair{Key: b, Value: two}),
+		},
+		{
+			input:    `let h = {"a": 1}; delete(h, "a"); h`,
+			expected: hash(object.HashPair{Key: a, Value: one}),
+		},
+		{
+			input:    `put({"a": 1}, "b", 2)`,
+			expected: hash(object.HashPair{Key: a, Value: one}, object.HashPair{Key: b, Value: two}),
+		},
+		{
+			input:    `let h = {"a": 1}; put(h, "a", 2); h["a"]`,
+			expected: one,
+		},
+		{
+			input:    `merge({"a": 1}, {"a": 2, "b": 2})`,
+			expected: hash(object.HashPair{Key: a, Value: two}, object.HashPair{Key: b, Value: two}),
+		},
+		{
+			input:    `merge({"a": 1}, 1)`,
+			expected: &object.Error{Message: "argument 2 to `merge` must be HASH, got INTEGER"},
+		},
+	}
+	if errors := testEval(tests); errors != nil {
+		for _, err := range errors {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/care0717/monkey-interpreter/ast"
+)
+
+// gensym は prefix で始まる新しい識別子の名前を返す。# は識別子に使えないので、ユーザーのコードの名前とは衝突しない
+func (ip *Interpreter) gensym(prefix string) string {
+	ip.gensyms++
+	return fmt.Sprintf("%s#%d", prefix, ip.gensyms)
+}
+
+// renameScope はマクロが導入した束縛の元の名前と新しい名前の対応。評価器と同じく関数ごとに作る
+type renameScope struct {
+	names map[string]string
+	outer *renameScope
+}
+
+func (s *renameScope) lookup(name string) (string, bool) {
+	for ; s != nil; s = s.outer {
+		if renamed, ok := s.names[name]; ok {
+			return renamed, true
+		}
+	}
+	return "", false
+}
+
+// hygiene はマクロ呼び出しひとつ分の展開結果の束縛を付け替える
+type hygiene struct {
+	ip *Interpreter
+	// args はマクロに渡された引数のノード。ユーザーのコードなので名前を変えない
+	args map[ast.Node]bool
+}
+
+// renameBindings は展開結果 expansion の中でマクロ自身が let や関数の引数で束縛した名前を、gensym で作った名前に付け替える。
+// これで展開先の変数をマクロが上書きしたり、引数の中の識別子がマクロの変数を指したりしなくなる
+func (ip *Interpreter) renameBindings(expansion ast.Node, args []ast.Expression) {
+	h := &hygiene{ip: ip, args: make(map[ast.Node]bool)}
+	for _, arg := range args {
+		h.args[arg] = true
+	}
+
+	s := &renameScope{names: make(map[string]string)}
+	h.declareLets(s, expansion)
+	h.rename(s, expansion)
+}
+
+// declareLets は関数リテラルの内側を除いて node の中の let を s に宣言する
+func (h *hygiene) declareLets(s *renameScope, node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if h.args[n] {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return n == node
+		case *ast.LetStatement:
+			h.declare(s, n.Name)
+		}
+		return true
+	})
+}
+
+func (h *hygiene) declare(s *renameScope, ident *ast.Identifier) {
+	if h.args[ident] {
+		return
+	}
+	if _, ok := s.names[ident.Value]; !ok {
+		s.names[ident.Value] = h.ip.gensym(ident.Value)
+	}
+}
+
+func (h *hygiene) rename(s *renameScope, node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if h.args[n] {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.Identifier:
+			if renamed, ok := s.lookup(n.Value); ok {
+				n.Value = renamed
+			}
+		case *ast.FunctionLiteral:
+			h.renameFunction(s, n.Parameters, n.Body)
+			return false
+		case *ast.MacroLiteral:
+			h.renameFunction(s, n.Parameters, n.Body)
+			return false
+		}
+		return true
+	})
+}
+
+func (h *hygiene) renameFunction(outer *renameScope, params []*ast.Identifier, body *ast.BlockStatement) {
+	s := &renameScope{names: make(map[string]string), outer: outer}
+	for _, param := range params {
+		h.declare(s, param)
+	}
+	h.declareLets(s, body)
+
+	for _, param := range params {
+		h.rename(s, param)
+	}
+	h.rename(s, body)
+}
@@ -0,0 +1,86 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/object"
+	"testing"
+)
+
+func TestHygiene(t *testing.T) {
+	tests := []struct {
+		input    string
+		expanded string
+		expected object.Object
+	}{
+		{
+			// マクロの let は引数の中の同名の変数を捕まえない
+			input: `
+let with_one = macro(body) { quote(fn() { let x = 1; x + unquote(body) }()) };
+let x = 10;
+with_one(x * 2)`,
+			expanded: "let x = 10;fn() let x#1 = 1;(x#1 + (x * 2))()",
+			expected: &object.Integer{Value: 21},
+		},
+		{
+			// マクロの let は展開先の関数の変数を上書きしない
+			input: `
+let double = macro(e) { quote(if (true) { let tmp = unquote(e); tmp * 2 }) };
+let f = fn() { let tmp = 100; let y = double(1); tmp + y };
+f()`,
+			expected: &object.Integer{Value: 102},
+		},
+		{
+			// 関数の引数も付け替える。引数として渡した関数の引数はそのまま
+			input: `
+let apply_twice = macro(f, v) { quote(fn(f) { f(f(unquote(v))) }(unquote(f))) };
+let f = 3;
+apply_twice(fn(f) { f * 2 }, f)`,
+			expanded: "let f = 3;fn(f#1) f#1(f#1(f))(fn(f) (f * 2))",
+			expected: &object.Integer{Value: 12},
+		},
+		{
+			// 同じマクロを何度展開しても別の名前になる
+			input: `
+let square = macro(e) { quote(fn() { let v = unquote(e); v * v }()) };
+square(2) + square(3)`,
+			expanded: "(fn() let v#1 = 2;(v#1 * v#1)() + fn() let v#2 = 3;(v#2 * v#2)())",
+			expected: &object.Integer{Value: 13},
+		},
+		{
+			// マクロが束縛していない名前は展開先の変数を指す
+			input: `
+let add_y = macro(e) { quote(unquote(e) + y) };
+let y = 5;
+add_y(1)`,
+			expected: &object.Integer{Value: 6},
+		},
+		{
+			// 同じ引数を深さの違う場所に展開しても、それぞれの場所で解決する
+			input: `
+let twice = macro(e) { quote(fn() { unquote(e) }() + unquote(e)) };
+let f = fn(v) { twice(v) };
+f(3)`,
+			expected: &object.Integer{Value: 6},
+		},
+	}
+
+	for _, tt := range tests {
+		ip := New(nil, nil)
+		program := testParseProgram(tt.input)
+		expanded, errors := ip.ExpandMacros(program)
+		if len(errors) != 0 {
+			t.Fatalf("case: %s. macro errors: %v", tt.input, errors)
+		}
+		if tt.expanded != "" && expanded.String() != tt.expanded {
+			t.Errorf("case: %s. expanded wrong. expected=%q, got=%q", tt.input, tt.expanded, expanded.String())
+		}
+
+		env := ip.NewEnvironment()
+		if errors := ip.Resolve(program, env); len(errors) != 0 {
+			t.Fatalf("case: %s. resolve errors: %v", tt.input, errors)
+		}
+		evaluated := ip.Eval(expanded, env)
+		if err := testObject(evaluated, tt.expected); err != nil {
+			t.Errorf("case: %s. err: %s", tt.input, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/resolver"
+	"github.com/care0717/monkey-interpreter/token"
+	"io"
+	"os"
+)
+
+// Interpreter は評価中に共有される状態を持つ
+type Interpreter struct {
+	in  io.Reader
+	out io.Writer
+
+	// SearchPath は import のパスが評価中のファイルからの相対パスで見つからないときに探すディレクトリ
+	SearchPath []string
+	// Optimize が true なら、読み込んだファイルをマクロの展開の後に optimizer で最適化してから評価する
+	Optimize bool
+	// Strict が true なら、型の注釈のある let と関数の呼び出しで、値が注釈の型に合うかを確かめる
+	Strict bool
+
+	dir     string             // 評価中のファイルがあるディレクトリ
+	modules map[string]*module // 読み込み済みのモジュール
+	loading []string           // 評価途中のモジュール。循環の検出に使う
+	parsing []string           // 構文解析とマクロの展開の途中のモジュール
+
+	preludeEnv      object.Environment // 標準ライブラリの束縛。読み込んでいなければ nil
+	preludeMacroEnv object.Environment // 標準ライブラリのマクロ
+
+	sessionMacros object.Environment // ExpandMacros で定義されたトップレベルのマクロ
+	macroEnv      object.Environment // 評価中のコードのトップレベルのマクロの環境。macroexpand が使う
+	gensyms       int                // gensym で作った名前の数
+}
+
+func New(in io.Reader, out io.Writer) *Interpreter {
+	return &Interpreter{
+		in:      in,
+		out:     out,
+		modules: make(map[string]*module),
+	}
+}
+
+var defaultInterpreter = New(os.Stdin, os.Stdout)
+
+// Eval は標準入出力につながったインタプリタで node を評価する
+func Eval(node ast.Node, env object.Environment) object.Object {
+	return defaultInterpreter.Eval(node, env)
+}
+
+// Resolve は env で評価する前の program の識別子を解決し、見つかった問題を返す。
+// env に定義済みの名前と組み込み関数は定義済みとみなす
+func (ip *Interpreter) Resolve(program *ast.Program, env object.Environment) []string {
+	return ip.resolve(program, env, false)
+}
+
+// ResolveInput は Resolve と同じだが、REPL の入力のように、関数の中から参照した未定義の名前は
+// 後の入力で定義されるものとみなし、呼び出しのときに名前で引く
+func (ip *Interpreter) ResolveInput(program *ast.Program, env object.Environment) []string {
+	return ip.resolve(program, env, true)
+}
+
+func (ip *Interpreter) resolve(program *ast.Program, env object.Environment, lateBound bool) []string {
+	r := resolver.New(func(name string) bool {
+		if _, ok := env.Get(name); ok {
+			return true
+		}
+		_, ok := builtins[name]
+		return ok
+	})
+	r.LateBound = lateBound
+	r.Resolve(program)
+	return r.Errors()
+}
+
+// callContext は builtin の呼び出しごとに作られる object.CallContext の実装
+type callContext struct {
+	ip  *Interpreter
+	pos token.Position
+}
+
+func (c *callContext) Apply(fn object.Object, args ...object.Object) object.Object {
+	return c.ip.applyFunction(fn, args, c.pos)
+}
+
+func (c *callContext) Errorf(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Pos: c.pos}
+}
+
+func (c *callContext) Gensym(prefix string) string { return c.ip.gensym(prefix) }
+
+func (c *callContext) MacroExpand(node ast.Node, once bool) (ast.Node, []string) {
+	return c.ip.macroExpand(node, once)
+}
+
+func (c *callContext) Pos() token.Position { return c.pos }
+func (c *callContext) In() io.Reader       { return c.ip.in }
+func (c *callContext) Out() io.Writer      { return c.ip.out }
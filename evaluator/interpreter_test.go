@@ -0,0 +1,156 @@
+package evaluator
+
+import (
+	"bytes"
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/token"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinOutput(t *testing.T) {
+	var out bytes.Buffer
+	ip := New(strings.NewReader(""), &out)
+
+	program := testParseProgram(`puts("hello", 1); puts([1, 2])`)
+	ip.Eval(program, object.NewEnvironment())
+
+	expected := "hello\n1\n[1, 2]\n"
+	if out.String() != expected {
+		t.Errorf("output wrong. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestBuiltinCallContext(t *testing.T) {
+	var got object.CallContext
+	twice := &object.Builtin{Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		got = ctx
+		if len(args) != 2 {
+			return ctx.Errorf("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		return ctx.Apply(args[0], ctx.Apply(args[0], args[1]))
+	}}
+
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{
+			input:    `twice(fn(x) { x * 2 }, 3)`,
+			expected: &object.Integer{Value: 12},
+		},
+		{
+			input:    `twice(fn(x) { x + "a" }, "b")`,
+			expected: &object.String{Value: "baa"},
+		},
+		{
+			input:    `twice(len, "b")`,
+			expected: &object.Error{Message: "argument to `len` not supported, got INTEGER"},
+		},
+		{
+			input:    `twice(1)`,
+			expected: &object.Error{Message: "wrong number of arguments. got=1, want=2"},
+		},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.Set("twice", twice)
+		evaluated := New(nil, nil).Eval(testParseProgram(tt.input), env)
+		if err := testObject(evaluated, tt.expected); err != nil {
+			t.Errorf("case: %s. err: %s", tt.input, err)
+		}
+		if got.Pos() != (token.Position{Line: 1, Column: 6}) {
+			t.Errorf("case: %s. call position wrong. got=%v", tt.input, got.Pos())
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `len(1)`,
+			expected: "ERROR: 1:4: argument to `len` not supported, got INTEGER",
+		},
+		{
+			input: `
+let f = fn(x) {
+  first(x)
+};
+f(1)`,
+			expected: "ERROR: 3:8: argument to `first` not supported, got INTEGER",
+		},
+		{
+			input:    `map([1], fn(x) { len(x) })`,
+			expected: "ERROR: 1:21: argument to `len` not supported, got INTEGER",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := Eval(testParseProgram(tt.input), object.NewEnvironment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("case: %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestResolvedEval(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{
+			input:    `let add = fn(a, b) { let c = a + b; c }; add(1, 2)`,
+			expected: &object.Integer{Value: 3},
+		},
+		{
+			input:    `let adder = fn(x) { fn(y) { fn(z) { x + y + z } } }; adder(1)(2)(3)`,
+			expected: &object.Integer{Value: 6},
+		},
+		{
+			input:    `let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5)`,
+			expected: &object.Integer{Value: 120},
+		},
+		{
+			input:    `let x = 10; let f = fn(x) { let g = fn() { x }; g() }; f(1) + x`,
+			expected: &object.Integer{Value: 11},
+		},
+		{
+			input:    `let f = fn() { let g = fn() { h() }; let h = fn() { 5 }; g() }; f()`,
+			expected: &object.Integer{Value: 5},
+		},
+		// let より前の参照は外側の変数を指す
+		{
+			input:    `let x = 1; let f = fn() { let x = x + 1; x }; f()`,
+			expected: &object.Integer{Value: 2},
+		},
+		{
+			input:    `let x = 1; let f = fn() { let y = x; let x = 2; y }; f() + x`,
+			expected: &object.Integer{Value: 2},
+		},
+		{
+			input:    `let x = 1; let f = fn() { let g = fn() { x }; let x = 2; g() }; f()`,
+			expected: &object.Integer{Value: 2},
+		},
+		{
+			input:    `let f = fn(n) { quote(unquote(n) + 1) }; f(2)`,
+			expected: &object.Quote{Node: testParseProgram(`2 + 1`).Statements[0].(*ast.ExpressionStatement).Expression},
+		},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment()
+		ip := New(nil, &bytes.Buffer{})
+		if errors := ip.Resolve(program, env); len(errors) != 0 {
+			t.Fatalf("case: %s. resolver errors: %v", tt.input, errors)
+		}
+		if err := testObject(ip.Eval(program, env), tt.expected); err != nil {
+			t.Errorf("case: %s. err: %s", tt.input, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/token"
+	"strings"
+)
+
+func init() {
+	for name, builtin := range macroBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// macroBuiltins はマクロの本体で AST を組み立てるための関数
+var macroBuiltins = map[string]*object.Builtin{
+	// gensym は展開先のどの名前とも衝突しない識別子を quote して返す。引数で名前の頭につける文字列を指定できる
+	"gensym": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		prefix := "g"
+		switch len(args) {
+		case 0:
+		case 1:
+			if err := checkArgs("gensym", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			prefix = args[0].(*object.String).Value
+		default:
+			return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+		}
+
+		name := ctx.Gensym(prefix)
+		return &object.Quote{Node: &ast.Identifier{
+			Token: token.Token{Type: token.IDENT, Literal: name, Pos: ctx.Pos()},
+			Value: name,
+		}}
+	}},
+	// macroexpand は quote の中のマクロ呼び出しを、マクロ呼び出しがなくなるまで展開する
+	"macroexpand": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		return macroExpand(ctx, "macroexpand", args, false)
+	}},
+	// macroexpand_1 は quote の中のマクロ呼び出しを一段だけ展開する
+	"macroexpand_1": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		return macroExpand(ctx, "macroexpand_1", args, true)
+	}},
+}
+
+func macroExpand(ctx object.CallContext, name string, args []object.Object, once bool) object.Object {
+	if err := checkArgs(name, args, object.QUOTE_OBJ); err != nil {
+		return err
+	}
+	expanded, errors := ctx.MacroExpand(args[0].(*object.Quote).Node, once)
+	if len(errors) != 0 {
+		return ctx.Errorf("could not expand macros: %s", strings.Join(errors, "; "))
+	}
+	return &object.Quote{Node: expanded}
+}
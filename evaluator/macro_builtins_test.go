@@ -0,0 +1,104 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/token"
+	"testing"
+)
+
+func TestGensym(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{
+			input:    `gensym()`,
+			expected: &object.Quote{Node: testIdentifier("g#1")},
+		},
+		{
+			input:    `gensym("tmp")`,
+			expected: &object.Quote{Node: testIdentifier("tmp#1")},
+		},
+		{
+			input: `let a = gensym("x"); let b = gensym("x"); [a, b]`,
+			expected: &object.Array{Elements: []object.Object{
+				&object.Quote{Node: testIdentifier("x#1")},
+				&object.Quote{Node: testIdentifier("x#2")},
+			}},
+		},
+		{
+			input:    `gensym(1)`,
+			expected: &object.Error{Message: "argument 1 to `gensym` must be STRING, got INTEGER"},
+		},
+		{
+			input:    `gensym("a", "b")`,
+			expected: &object.Error{Message: "wrong number of arguments. got=2, want=0 or 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		ip := New(nil, nil)
+		program := testParseProgram(tt.input)
+		expanded, errors := ip.ExpandMacros(program)
+		if len(errors) != 0 {
+			t.Fatalf("case: %s. macro errors: %v", tt.input, errors)
+		}
+		evaluated := ip.Eval(expanded, ip.NewEnvironment())
+		if err := testObject(evaluated, tt.expected); err != nil {
+			t.Errorf("case: %s. err: %s", tt.input, err)
+		}
+	}
+}
+
+func TestMacroExpand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `macroexpand(quote(quad(y)))`,
+			expected: `QUOTE(((y * 2) * 2))`,
+		},
+		{
+			input:    `macroexpand_1(quote(quad(y)))`,
+			expected: `QUOTE(twice(twice(y)))`,
+		},
+		{
+			input:    `macroexpand_1(macroexpand_1(quote(quad(y))))`,
+			expected: `QUOTE(((y * 2) * 2))`,
+		},
+		{
+			input:    `let q = quote(1 + twice(x)); macroexpand(q); q`,
+			expected: `QUOTE((1 + twice(x)))`,
+		},
+		{
+			input:    `macroexpand(quote(twice(1, 2)))`,
+			expected: `ERROR: 4:12: could not expand macros: 4:24: wrong number of arguments to macro twice. got=2, want=1`,
+		},
+		{
+			input:    `macroexpand(1)`,
+			expected: "ERROR: 4:12: argument 1 to `macroexpand` must be QUOTE, got INTEGER",
+		},
+	}
+
+	for _, tt := range tests {
+		ip := New(nil, nil)
+		program := testParseProgram(`
+let twice = macro(x) { quote(unquote(x) * 2) };
+let quad = macro(x) { quote(twice(twice(unquote(x)))) };
+` + tt.input)
+		expanded, errors := ip.ExpandMacros(program)
+		if len(errors) != 0 {
+			t.Fatalf("case: %s. macro errors: %v", tt.input, errors)
+		}
+		evaluated := ip.Eval(expanded, ip.NewEnvironment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("case: %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func testIdentifier(name string) *ast.Identifier {
+	return &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
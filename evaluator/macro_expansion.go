@@ -1,45 +1,363 @@
 package evaluator
 
 import (
+	"fmt"
 	"github.com/care0717/monkey-interpreter/ast"
 	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/token"
+	"path/filepath"
 )
 
-func DefineMacros(program *ast.Program, env object.Environment) {
-	var definitions []int
+// maxExpansionRounds は展開を繰り返す回数の上限。自分自身を含む形に展開されるマクロで止まらなくなるのを防ぐ
+const maxExpansionRounds = 100
 
-	for i, statement := range program.Statements {
-		if isMacroDefinition(statement) {
-			addMacro(statement, env)
-			definitions = append(definitions, i)
+// ExpandMacros は標準入出力につながったインタプリタでマクロを展開する
+func ExpandMacros(program *ast.Program) (*ast.Program, []string) {
+	return defaultInterpreter.ExpandMacros(program)
+}
+
+// ExpandMacros は program からマクロの定義を取り除いて登録し、マクロ呼び出しを展開する。
+// トップレベルで定義したマクロは、REPL の入力のように続けて展開するプログラムからも使える。
+// 展開できなかった呼び出しはそのまま残し、"行:列: メッセージ" の形のエラーとして返す
+func (ip *Interpreter) ExpandMacros(program *ast.Program) (*ast.Program, []string) {
+	if ip.sessionMacros == nil {
+		ip.sessionMacros = ip.newMacroScope()
+	}
+	ip.macroEnv = ip.sessionMacros
+
+	expanded, _, errors := ip.expandMacros(program, ip.sessionMacros)
+	return expanded.(*ast.Program), errors
+}
+
+// ExpandFile は path から読み込んだ program のマクロを展開する。loadFile と同じく、
+// import はファイルからの相対パスで探し、マクロはファイルごとの新しい環境に登録する
+func (ip *Interpreter) ExpandFile(path string, program *ast.Program) (*ast.Program, []string) {
+	savedDir, savedMacros := ip.dir, ip.macroEnv
+	scope := ip.newMacroScope()
+	ip.dir, ip.macroEnv = filepath.Dir(path), scope
+	defer func() { ip.dir, ip.macroEnv = savedDir, savedMacros }()
+
+	expanded, _, errors := ip.expandMacros(program, scope)
+	return expanded.(*ast.Program), errors
+}
+
+// newMacroScope はファイルや REPL のトップレベルのマクロの環境を作る。標準ライブラリのマクロが見える
+func (ip *Interpreter) newMacroScope() object.Environment {
+	if ip.preludeMacroEnv == nil {
+		return object.NewEnvironment()
+	}
+	return object.NewEnclosedEnvironment(ip.preludeMacroEnv)
+}
+
+// macroExpand は node の複製の中のマクロ呼び出しを、評価中のコードのマクロで展開する。
+// once なら一段だけ展開し、展開結果の中のマクロ呼び出しはそのまま残す
+func (ip *Interpreter) macroExpand(node ast.Node, once bool) (ast.Node, []string) {
+	scope := ip.macroEnv
+	if scope == nil {
+		scope = ip.newMacroScope()
+	}
+
+	node = ast.Copy(node)
+	if once {
+		e := ip.newExpander(node, scope)
+		expanded, _ := e.expandOnce(node)
+		return expanded, e.errors
+	}
+	expanded, _, errors := ip.expandMacros(node, scope)
+	return expanded, errors
+}
+
+// expandMacros は node の中で定義されたマクロを取り除いて登録し、マクロ呼び出しがなくなるまで繰り返し展開する。
+// トップレベルのマクロは scope に登録し、ブロックの中のマクロはそのブロックの中でだけ使える。
+// quote の中は unquote の引数を除いて展開しない。export されたマクロも返す
+func (ip *Interpreter) expandMacros(node ast.Node, scope object.Environment) (ast.Node, map[string]*object.Macro, []string) {
+	e := ip.newExpander(node, scope)
+	for round := 0; round < maxExpansionRounds; round++ {
+		expanded, count := e.expandOnce(node)
+		node = expanded
+		if len(e.errors) != 0 || count == 0 {
+			return node, e.exports, e.errors
 		}
 	}
 
-	for i := len(definitions) - 1; i >= 0; i-- {
-		index := definitions[i]
-		program.Statements = append(
-			program.Statements[:index],
-			program.Statements[index+1:]...)
+	for _, site := range e.macroCallSites(node) {
+		e.errorf(site.pos, "macro %s is still not expanded after %d rounds", site.call.Function, maxExpansionRounds)
 	}
+	return node, e.exports, e.errors
 }
 
-func isMacroDefinition(node ast.Statement) bool {
-	letStatement, ok := node.(*ast.LetStatement)
-	if !ok {
-		return false
+// expander はひとつのプログラムを展開する間の状態を持つ
+type expander struct {
+	ip   *Interpreter
+	root ast.Node
+	// scopes はマクロを定義したプログラムとブロックごとのマクロの環境。展開を繰り返しても同じ環境を使う
+	scopes map[ast.Node]object.Environment
+	// origins は展開結果のノードから、ユーザーが書いた元の呼び出しの位置を引く
+	origins  map[ast.Node]token.Position
+	imported map[*ast.ImportStatement]bool
+	exports  map[string]*object.Macro
+	errors   []string
+}
+
+func (ip *Interpreter) newExpander(root ast.Node, scope object.Environment) *expander {
+	return &expander{
+		ip:       ip,
+		root:     root,
+		scopes:   map[ast.Node]object.Environment{root: scope},
+		origins:  make(map[ast.Node]token.Position),
+		imported: make(map[*ast.ImportStatement]bool),
+		exports:  make(map[string]*object.Macro),
+		errors:   []string{},
+	}
+}
+
+func (e *expander) errorf(pos token.Position, format string, a ...interface{}) {
+	e.errors = append(e.errors, fmt.Sprintf("%s: %s", pos, fmt.Sprintf(format, a...)))
+}
+
+// expandOnce は node 中のマクロ呼び出しを一段だけ展開し、展開した数を返す
+func (e *expander) expandOnce(node ast.Node) (ast.Node, int) {
+	sites := make(map[*ast.CallExpression]macroCallSite)
+	for _, site := range e.macroCallSites(node) {
+		sites[site.call] = site
+	}
+
+	count := 0
+	expanded, err := ast.Modify(node, func(node ast.Node) ast.Node {
+		callExpression, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+		site, ok := sites[callExpression]
+		if !ok {
+			return node
+		}
+
+		quote, errObj := e.ip.expandMacroCall(callExpression, site.macro)
+		if errObj != nil {
+			e.errorf(site.pos, "%s", errObj.Message)
+			return node
+		}
+		e.ip.renameBindings(quote.Node, callExpression.Arguments)
+		expansion := e.unshare(quote.Node)
+		if !isArgument(quote.Node, callExpression) {
+			e.origins[expansion] = site.pos
+		}
+		count++
+		return expansion
+	})
+	if err != nil {
+		e.errors = append(e.errors, err.Error())
+	}
+	return expanded, count
+}
+
+// unshare は展開結果の中で二度目以降に現れるノードを複製に置き換える。
+// unquote(e) を何度も書いたマクロは同じ引数のノードを何か所にも置くが、resolver は識別子のノードに参照先を書き込むので、
+// 深さの違う場所で同じノードを共有すると後から解決した方で上書きされてしまう
+func (e *expander) unshare(node ast.Node) ast.Node {
+	seen := make(map[ast.Node]bool)
+	unshared, _ := ast.Modify(node, func(n ast.Node) ast.Node {
+		if !seen[n] {
+			seen[n] = true
+			return n
+		}
+		copied := ast.Copy(n)
+		e.copyState(n, copied)
+		return copied
+	})
+	return unshared
+}
+
+// copyState は from のブロックのマクロの環境と、元の呼び出しの位置を、from を複製した to の対応するノードにも記録する
+func (e *expander) copyState(from, to ast.Node) {
+	var src, dst []ast.Node
+	ast.Inspect(from, func(n ast.Node) bool {
+		src = append(src, n)
+		return true
+	})
+	ast.Inspect(to, func(n ast.Node) bool {
+		dst = append(dst, n)
+		return true
+	})
+	for i := range src {
+		if scope, ok := e.scopes[src[i]]; ok {
+			e.scopes[dst[i]] = scope
+		}
+		if pos, ok := e.origins[src[i]]; ok {
+			e.origins[dst[i]] = pos
+		}
+	}
+}
+
+// macroCallSite は展開するマクロ呼び出しと、そのマクロ、エラーを報告する位置
+type macroCallSite struct {
+	call  *ast.CallExpression
+	macro *object.Macro
+	pos   token.Position
+}
+
+// macroCallSites は node を辿ってマクロの定義を登録し、展開するマクロ呼び出しを返す。
+// quote の中は unquote と unquote_splice の引数だけを探す。
+// 展開結果の中の呼び出しの位置は、origins に記録された元の呼び出しの位置にする
+func (e *expander) macroCallSites(node ast.Node) []macroCallSite {
+	type frame struct {
+		scope  object.Environment
+		origin token.Position // 展開結果の外ならゼロ値
 	}
+	var sites []macroCallSite
+	stack := []frame{{scope: e.scopes[e.root]}}
+	top := func() frame { return stack[len(stack)-1] }
 
-	_, ok = letStatement.Value.(*ast.MacroLiteral)
+	var find func(node ast.Node, quoted bool)
+	find = func(node ast.Node, quoted bool) {
+		ast.Inspect(node, func(node ast.Node) bool {
+			if node == nil {
+				stack = stack[:len(stack)-1]
+				return false
+			}
 
+			f := top()
+			if pos, ok := e.origins[node]; ok {
+				f.origin = pos
+			}
+			switch node := node.(type) {
+			case *ast.Program:
+				if scope, ok := e.scopes[node]; ok {
+					f.scope = scope
+				}
+				node.Statements = e.defineMacros(node.Statements, f.scope, true)
+			case *ast.BlockStatement:
+				f.scope = e.blockScope(node, f.scope)
+			case *ast.CallExpression:
+				switch name := node.Function.TokenLiteral(); {
+				case name == "quote" && !quoted:
+					for _, arg := range node.Arguments {
+						find(arg, true)
+					}
+					return false
+				case (name == "unquote" || name == "unquote_splice") && quoted:
+					for _, arg := range node.Arguments {
+						find(arg, false)
+					}
+					return false
+				}
+				if macro, ok := isMacroCall(node, f.scope); ok && !quoted {
+					site := macroCallSite{call: node, macro: macro, pos: f.origin}
+					if !site.pos.IsValid() {
+						site.pos = node.Token.Pos
+					}
+					sites = append(sites, site)
+				}
+			}
+
+			stack = append(stack, f)
+			return true
+		})
+	}
+	find(node, false)
+	return sites
+}
+
+// blockScope はブロックの中で定義されたマクロを登録した環境を返す。マクロを定義していなければ outer をそのまま使う
+func (e *expander) blockScope(block *ast.BlockStatement, outer object.Environment) object.Environment {
+	scope, ok := e.scopes[block]
 	if !ok {
-		return false
+		if !containsMacroDefinition(block.Statements) {
+			return outer
+		}
+		scope = object.NewEnclosedEnvironment(outer)
+		e.scopes[block] = scope
+	}
+	block.Statements = e.defineMacros(block.Statements, scope, false)
+	return scope
+}
+
+// defineMacros は statements の中のマクロの定義を scope に登録し、取り除いた文を返す。
+// topLevel なら export されたマクロと、import されたモジュールのマクロも扱う
+func (e *expander) defineMacros(statements []ast.Statement, scope object.Environment, topLevel bool) []ast.Statement {
+	if !containsMacroDefinition(statements) {
+		if topLevel {
+			e.importMacros(statements, scope)
+		}
+		return statements
 	}
 
-	return true
+	remaining := make([]ast.Statement, 0, len(statements))
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if isMacroDefinition(stmt) {
+				addMacro(stmt, scope)
+				continue
+			}
+		case *ast.ExportStatement:
+			if topLevel && isMacroDefinition(stmt.Let) {
+				e.exports[stmt.Let.Name.Value] = addMacro(stmt.Let, scope)
+				continue
+			}
+		}
+		remaining = append(remaining, stmt)
+	}
+	if topLevel {
+		e.importMacros(remaining, scope)
+	}
+	return remaining
 }
 
-func addMacro(stmt ast.Statement, env object.Environment) {
-	letStatement, _ := stmt.(*ast.LetStatement)
+// importMacros は import するモジュールを読み込み、export されたマクロを scope に登録する。
+// 読み込めないモジュールは、評価のときに import 文がエラーにする
+func (e *expander) importMacros(statements []ast.Statement, scope object.Environment) {
+	for _, stmt := range statements {
+		is, ok := stmt.(*ast.ImportStatement)
+		if !ok || e.imported[is] {
+			continue
+		}
+		e.imported[is] = true
+
+		mod, err := e.ip.loadModule(is.Path.Value, is.Token.Pos)
+		if err != nil {
+			continue
+		}
+		if is.Names == nil {
+			for name, macro := range mod.macros {
+				scope.Set(name, macro)
+			}
+			continue
+		}
+		for _, name := range is.Names {
+			if macro, ok := mod.macros[name.Value]; ok {
+				scope.Set(name.Value, macro)
+			}
+		}
+	}
+}
+
+func containsMacroDefinition(statements []ast.Statement) bool {
+	for _, stmt := range statements {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if isMacroDefinition(stmt) {
+				return true
+			}
+		case *ast.ExportStatement:
+			if isMacroDefinition(stmt.Let) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isMacroDefinition(letStatement *ast.LetStatement) bool {
+	if letStatement == nil {
+		return false
+	}
+	_, ok := letStatement.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+func addMacro(letStatement *ast.LetStatement, env object.Environment) *object.Macro {
 	macroLiteral, _ := letStatement.Value.(*ast.MacroLiteral)
 
 	macro := &object.Macro{
@@ -48,31 +366,44 @@ func addMacro(stmt ast.Statement, env object.Environment) {
 		Env:        env,
 	}
 	env.Set(letStatement.Name.Value, macro)
+	return macro
 }
 
-func ExpandMacros(program ast.Node, env object.Environment) ast.Node {
-	return ast.Modify(program, func(node ast.Node) ast.Node {
-		callExpression, ok := node.(*ast.CallExpression)
-		if !ok {
-			return node
-		}
-		macro, ok := isMacroCall(callExpression, env)
-		if !ok {
-			return node
+func isArgument(node ast.Node, call *ast.CallExpression) bool {
+	for _, arg := range call.Arguments {
+		if node == arg {
+			return true
 		}
+	}
+	return false
+}
 
-		args := quoteArgs(callExpression)
-		evalEnv := extendMacroEnv(macro, args)
+// expandMacroCall は macro の本体を評価し、返された quote を取り出す
+func (ip *Interpreter) expandMacroCall(call *ast.CallExpression, macro *object.Macro) (*object.Quote, *object.Error) {
+	name := call.Function.String()
+	if len(call.Arguments) != len(macro.Parameters) {
+		return nil, newError("wrong number of arguments to macro %s. got=%d, want=%d", name, len(call.Arguments), len(macro.Parameters))
+	}
 
-		evaluated := Eval(macro.Body, evalEnv)
+	args := quoteArgs(call)
+	evalEnv := extendMacroEnv(macro, args)
 
-		quote, ok := evaluated.(*object.Quote)
-		if !ok {
-			panic("we only support returning AST-nodes from macros")
+	evaluated := unwrapReturnValue(ip.Eval(macro.Body, evalEnv))
+	switch evaluated := evaluated.(type) {
+	case *object.Quote:
+		return evaluated, nil
+	case *object.Error:
+		// 本体の中での位置も残す
+		msg := evaluated.Message
+		if evaluated.Pos.IsValid() {
+			msg = fmt.Sprintf("%s: %s", evaluated.Pos, msg)
 		}
-
-		return quote.Node
-	})
+		return nil, newError("error in macro %s: %s", name, msg)
+	case nil:
+		return nil, newError("macro %s must return a quote, got nothing", name)
+	default:
+		return nil, newError("macro %s must return a quote, got %s", name, evaluated.Type())
+	}
 }
 
 func isMacroCall(exp *ast.CallExpression, env object.Environment) (*object.Macro, bool) {
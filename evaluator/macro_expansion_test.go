@@ -173,13 +173,12 @@ let mymacro = macro(x, y) { x + y; };`,
 		},
 	}
 	for _, tt := range tests {
-		program := testParseProgram(tt.input)
-		env := object.NewEnvironment()
-		DefineMacros(program, env)
-		if !cmp.Equal(program, tt.expectedProgram) {
-			t.Errorf("%T diff %s[-got, +expected]", tt.expectedProgram, cmp.Diff(program, tt.expectedProgram))
+		ip := New(nil, nil)
+		program, _ := ip.ExpandMacros(testParseProgram(tt.input))
+		if !cmp.Equal(program, tt.expectedProgram, ignorePos) {
+			t.Errorf("%T diff %s[-got, +expected]", tt.expectedProgram, cmp.Diff(program, tt.expectedProgram, ignorePos))
 		}
-		obj, ok := env.Get(tt.expectedKey)
+		obj, ok := ip.sessionMacros.Get(tt.expectedKey)
 		if !ok {
 			t.Errorf("macro not in env")
 		}
@@ -189,11 +188,11 @@ let mymacro = macro(x, y) { x + y; };`,
 			t.Errorf("object is not Macro. got=%T (%+v)", obj, obj)
 		}
 
-		if !cmp.Equal(macro.Parameters, tt.expectedMacro.Parameters) {
-			t.Errorf("%T diff %s[-got, +expected]", tt.expectedMacro.Parameters, cmp.Diff(macro.Parameters, tt.expectedMacro.Parameters))
+		if !cmp.Equal(macro.Parameters, tt.expectedMacro.Parameters, ignorePos) {
+			t.Errorf("%T diff %s[-got, +expected]", tt.expectedMacro.Parameters, cmp.Diff(macro.Parameters, tt.expectedMacro.Parameters, ignorePos))
 		}
-		if !cmp.Equal(macro.Body, tt.expectedMacro.Body) {
-			t.Errorf("%T diff %s[-got, +expected]", tt.expectedMacro.Body, cmp.Diff(macro.Body, tt.expectedMacro.Body))
+		if !cmp.Equal(macro.Body, tt.expectedMacro.Body, ignorePos) {
+			t.Errorf("%T diff %s[-got, +expected]", tt.expectedMacro.Body, cmp.Diff(macro.Body, tt.expectedMacro.Body, ignorePos))
 		}
 	}
 }
@@ -216,19 +215,187 @@ let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
 reverse(2+2, 10-5)`,
 			expected: &object.Integer{Value: 1},
 		},
+		{
+			input: `
+let double = macro(x) { quote(unquote(x) * 2); };
+double(5) + double(6)`,
+			expected: &object.Integer{Value: 22},
+		},
+		{
+			input: `
+let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }); };
+let add = fn(a, b) { a * 10 + b };
+add(unless(false, 1, 2), unless(true, 3, 4))`,
+			expected: &object.Integer{Value: 14},
+		},
+		{
+			input: `
+let twice = macro(x) { quote(unquote(x) * 2) };
+let quad = macro(x) { quote(twice(twice(unquote(x)))) };
+quad(3)`,
+			expected: &object.Integer{Value: 12},
+		},
+		{
+			// quote の中のマクロ呼び出しは展開しない
+			input: `
+let twice = macro(x) { quote(unquote(x) * 2) };
+quote(twice(unquote(twice(1))))`,
+			expected: &object.Quote{Node: &ast.CallExpression{
+				Token:     token.Token{Type: token.LPAREN, Literal: "("},
+				Function:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "twice"}, Value: "twice"},
+				Arguments: []ast.Expression{&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "2"}, Value: 2}},
+			}},
+		},
+		{
+			input: `
+let constant = macro() { quote(unquote({"a": [1, "x"]})) };
+constant()["a"][1]`,
+			expected: &object.String{Value: "x"},
+		},
+		{
+			input: `
+let nothing = macro() { quote(unquote(if (false) { 1 })) };
+nothing()`,
+			expected: object.NULL,
+		},
+		{
+			input: `
+let add = fn(x, y) { x * 10 + y };
+let flip = macro(a, b) { quote(add(unquote_splice([b, a]))) };
+flip(1, 2)`,
+			expected: &object.Integer{Value: 21},
+		},
+		{
+			input: `
+let seq = macro(a, b) { quote(fn() { unquote_splice([a, b]) }()) };
+seq(1, 2)`,
+			expected: &object.Integer{Value: 2},
+		},
+		{
+			input: `
+let f = fn(x) { let double = macro(e) { quote(unquote(e) * 2) }; double(x) };
+f(3)`,
+			expected: &object.Integer{Value: 6},
+		},
+		{
+			input: `
+let m = macro() { quote(1) };
+let f = fn() { let m = macro() { quote(2) }; m() };
+f() + m()`,
+			expected: &object.Integer{Value: 3},
+		},
+		{
+			input: `
+let m = macro() { quote(1) };
+if (true) { let n = macro() { quote(m() + 1) }; n() }`,
+			expected: &object.Integer{Value: 2},
+		},
+		{
+			input: `
+if (true) { let m = macro() { quote(1) }; };
+m()`,
+			expected: &object.Error{Message: "identifier not found: m"},
+		},
 	}
 
 	for _, tt := range tests {
 
 
-		program := testParseProgram(tt.input)
-		env := object.NewEnvironment()
-		DefineMacros(program, env)
-		expanded := ExpandMacros(program, env)
-		got := Eval(expanded, env)
+		ip := New(nil, nil)
+		expanded, errors := ip.ExpandMacros(testParseProgram(tt.input))
+		if len(errors) != 0 {
+			t.Fatalf("macro errors: %v", errors)
+		}
+		got := ip.Eval(expanded, object.NewEnvironment())
+
+		if !cmp.Equal(got, tt.expected, ignorePos) {
+			t.Errorf("%T diff %s[-got, +expected]", tt.expected, cmp.Diff(expanded, tt.expected, ignorePos))
+		}
+	}
+}
+
+func TestExpandMacrosAcrossPrograms(t *testing.T) {
+	ip := New(nil, nil)
+	inputs := []string{
+		`let double = macro(e) { quote(unquote(e) * 2) };`,
+		`let quadruple = macro(e) { quote(double(double(unquote(e)))) };`,
+		`if (true) { let hidden = macro() { quote(0) }; };`,
+	}
+	for _, input := range inputs {
+		if _, errors := ip.ExpandMacros(testParseProgram(input)); len(errors) != 0 {
+			t.Fatalf("case: %s. macro errors: %v", input, errors)
+		}
+	}
+
+	expanded, errors := ip.ExpandMacros(testParseProgram(`quadruple(3); hidden()`))
+	if len(errors) != 0 {
+		t.Fatalf("macro errors: %v", errors)
+	}
+	expected := "((3 * 2) * 2)hidden()"
+	if expanded.String() != expected {
+		t.Errorf("expanded wrong. expected=%q, got=%q", expected, expanded.String())
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input: `
+let number = macro() { 1 };
+number();`,
+			expected: []string{"3:7: macro number must return a quote, got INTEGER"},
+		},
+		{
+			input: `
+let nothing = macro() { };
+nothing();`,
+			expected: []string{"3:8: macro nothing must return a quote, got nothing"},
+		},
+		{
+			input: `
+let twice = macro(x) { quote(unquote(x) * 2) };
+twice(1, 2) + twice();`,
+			expected: []string{
+				"3:6: wrong number of arguments to macro twice. got=2, want=1",
+				"3:20: wrong number of arguments to macro twice. got=0, want=1",
+			},
+		},
+		{
+			input: `
+let broken = macro(x) { quote(unquote(missing)) };
+broken(1);`,
+			expected: []string{"3:7: error in macro broken: 2:39: identifier not found: missing"},
+		},
+		{
+			input: `
+let loop = macro() { quote(loop()) };
+loop();`,
+			expected: []string{"3:5: macro loop is still not expanded after 100 rounds"},
+		},
+		{
+			// 展開結果の中の呼び出しのエラーは、元の呼び出しの位置で報告する
+			input: `
+let twice = macro(x) { quote(unquote(x) * 2) };
+let bad = macro() { quote(1 + twice(1, 2)) };
+bad();`,
+			expected: []string{"4:4: wrong number of arguments to macro twice. got=2, want=1"},
+		},
+		{
+			input: `
+let early = macro(x) { return quote(unquote(x) + 1); quote(0) };
+early(1);`,
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		_, errors := New(nil, nil).ExpandMacros(testParseProgram(tt.input))
 
-		if !cmp.Equal(got, tt.expected) {
-			t.Errorf("%T diff %s[-got, +expected]", tt.expected, cmp.Diff(expanded, tt.expected))
+		if !cmp.Equal(errors, tt.expected) {
+			t.Errorf("case: %s. diff %s[-got, +expected]", tt.input, cmp.Diff(errors, tt.expected))
 		}
 	}
 }
@@ -0,0 +1,228 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/optimizer"
+	"github.com/care0717/monkey-interpreter/parser"
+	"github.com/care0717/monkey-interpreter/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ModuleExt は import のパスに拡張子がないときに補う拡張子
+const ModuleExt = ".mk"
+
+// SearchPathFromEnv は環境変数 MONKEYPATH を SearchPath に使える形に分ける
+func SearchPathFromEnv() []string {
+	return filepath.SplitList(os.Getenv("MONKEYPATH"))
+}
+
+// module は読み込んだファイル。構文解析とマクロの展開は一度だけ行い、
+// import されたファイルなら評価も最初に import されたときに一度だけ行う
+type module struct {
+	path    string
+	program *ast.Program
+	env     object.Environment
+	// macroScope はファイルのトップレベルのマクロの環境。評価中の macroexpand が使う
+	macroScope object.Environment
+	// macros は export されたマクロ。import した側の展開で使う
+	macros map[string]*object.Macro
+	// exports は export された束縛。評価するまでは nil
+	exports map[string]object.Object
+}
+
+// EvalFile は path のスクリプトを評価する。スクリプト中の import は path からの相対パスで解決する
+func (ip *Interpreter) EvalFile(path string, env object.Environment) object.Object {
+	mod, err := ip.loadFile(path, env, token.Position{})
+	if err != nil {
+		return err
+	}
+	return ip.evalModule(mod)
+}
+
+// evalTopLevelStatement はプログラム直下の文を評価する。import と export はここでだけ評価できる
+func (ip *Interpreter) evalTopLevelStatement(stmt ast.Statement, env object.Environment) object.Object {
+	switch stmt := stmt.(type) {
+	case *ast.ImportStatement:
+		return ip.evalImportStatement(stmt, env)
+	case *ast.ExportStatement:
+		return ip.Eval(stmt.Let, env)
+	default:
+		return ip.Eval(stmt, env)
+	}
+}
+
+func (ip *Interpreter) evalImportStatement(node *ast.ImportStatement, env object.Environment) object.Object {
+	mod, err := ip.importModule(node.Path.Value, node.Token.Pos)
+	if err != nil {
+		return err
+	}
+
+	if node.Names == nil {
+		for name, val := range mod.exports {
+			env.Set(name, val)
+		}
+		return nil
+	}
+
+	for _, name := range node.Names {
+		if _, ok := mod.macros[name.Value]; ok {
+			// マクロは展開のときに取り込み済み
+			continue
+		}
+		val, ok := mod.exports[name.Value]
+		if !ok {
+			return newErrorAt(name.Token.Pos, "%s is not exported by %q", name.Value, node.Path.Value)
+		}
+		env.Set(name.Value, val)
+	}
+	return nil
+}
+
+// importModule はモジュールを一度だけ評価し、結果をキャッシュする
+func (ip *Interpreter) importModule(path string, pos token.Position) (*module, *object.Error) {
+	mod, err := ip.loadModule(path, pos)
+	if err != nil {
+		return nil, err
+	}
+	if mod.exports != nil {
+		return mod, nil
+	}
+
+	for i, loading := range ip.loading {
+		if loading == mod.path {
+			cycle := append(append([]string{}, ip.loading[i:]...), mod.path)
+			return nil, newErrorAt(pos, "import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	ip.loading = append(ip.loading, mod.path)
+	defer func() { ip.loading = ip.loading[:len(ip.loading)-1] }()
+
+	result := ip.evalModule(mod)
+	if err, ok := result.(*object.Error); ok {
+		if err.Pos.IsValid() {
+			return nil, newErrorAt(pos, "%s:%s: %s", path, err.Pos, err.Message)
+		}
+		return nil, newErrorAt(pos, "%s: %s", path, err.Message)
+	}
+
+	exports := make(map[string]object.Object)
+	for _, stmt := range mod.program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			name := export.Let.Name.Value
+			exports[name], _ = mod.env.Get(name)
+		}
+	}
+	mod.exports = exports
+
+	return mod, nil
+}
+
+// loadModule は import のパスのファイルを一度だけ読み込み、評価せずにキャッシュする
+func (ip *Interpreter) loadModule(path string, pos token.Position) (*module, *object.Error) {
+	resolved, ok := ip.resolveModule(path)
+	if !ok {
+		return nil, newErrorAt(pos, "module not found: %q", path)
+	}
+
+	if mod, ok := ip.modules[resolved]; ok {
+		return mod, nil
+	}
+
+	// 読み込み途中のファイルを import していれば循環している。評価のときに改めて報告する
+	for _, parsing := range ip.parsing {
+		if parsing == resolved {
+			return nil, newErrorAt(pos, "import cycle: %s", resolved)
+		}
+	}
+	ip.parsing = append(ip.parsing, resolved)
+	defer func() { ip.parsing = ip.parsing[:len(ip.parsing)-1] }()
+
+	mod, err := ip.loadFile(resolved, ip.NewEnvironment(), pos)
+	if err != nil {
+		return nil, err
+	}
+	ip.modules[resolved] = mod
+	return mod, nil
+}
+
+// resolveModule は import のパスを絶対パスにする。
+// "./" や "../" で始まるパスは評価中のファイルからの相対パスとしてだけ探し、
+// それ以外は評価中のファイルの位置、SearchPath の順に探す
+func (ip *Interpreter) resolveModule(path string) (string, bool) {
+	if filepath.Ext(path) == "" {
+		path += ModuleExt
+	}
+
+	var candidates []string
+	switch {
+	case filepath.IsAbs(path):
+		candidates = []string{path}
+	case strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../"):
+		candidates = []string{filepath.Join(ip.dir, path)}
+	default:
+		candidates = []string{filepath.Join(ip.dir, path)}
+		for _, dir := range ip.SearchPath {
+			candidates = append(candidates, filepath.Join(dir, path))
+		}
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		abs, err := filepath.Abs(candidate)
+		if err != nil {
+			continue
+		}
+		return abs, true
+	}
+	return "", false
+}
+
+// loadFile はファイルを読み込んで構文解析し、マクロを展開して env での評価に向けて識別子を解決する
+func (ip *Interpreter) loadFile(path string, env object.Environment, pos token.Position) (*module, *object.Error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, newErrorAt(pos, "could not read %s: %s", path, err)
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, newErrorAt(pos, "could not parse %s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+
+	// ファイルの中の import は、ファイルからの相対パスで探す
+	saved := ip.dir
+	ip.dir = filepath.Dir(path)
+	defer func() { ip.dir = saved }()
+
+	scope := ip.newMacroScope()
+	expanded, macros, errors := ip.expandMacros(program, scope)
+	if len(errors) != 0 {
+		return nil, newErrorAt(pos, "could not expand macros in %s: %s", path, strings.Join(errors, "; "))
+	}
+	program = expanded.(*ast.Program)
+	if ip.Optimize {
+		program = optimizer.Optimize(program)
+	}
+	if errors := ip.Resolve(program, env); len(errors) != 0 {
+		return nil, newErrorAt(pos, "could not resolve %s: %s", path, strings.Join(errors, "; "))
+	}
+	return &module{path: path, program: program, env: env, macroScope: scope, macros: macros}, nil
+}
+
+// evalModule は mod のファイルの位置とマクロを、評価中のものとして mod を評価する
+func (ip *Interpreter) evalModule(mod *module) object.Object {
+	savedDir, savedMacros := ip.dir, ip.macroEnv
+	ip.dir, ip.macroEnv = filepath.Dir(mod.path), mod.macroScope
+	defer func() { ip.dir, ip.macroEnv = savedDir, savedMacros }()
+
+	return ip.Eval(mod.program, mod.env)
+}
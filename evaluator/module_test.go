@@ -0,0 +1,268 @@
+package evaluator
+
+import (
+	"bytes"
+	"github.com/care0717/monkey-interpreter/object"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-module")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"lib/math.mk": `
+export let square = fn(x) { x * x };
+export let cube = fn(x) { x * square(x) };
+let hidden = 1;
+puts("math loaded");
+`,
+		"lib/geometry.mk": `
+import { square } from "./math";
+export let area = fn(r) { 3 * square(r) };
+`,
+		"vendor/strings.mk": `
+export let shout = fn(s) { upper(s) + "!" };
+`,
+		"cycle/a.mk": `
+import "./b";
+export let a = 1;
+`,
+		"cycle/b.mk": `
+import "./a";
+export let b = 1;
+`,
+		"lib/macros.mk": `
+export let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };
+let hidden_macro = macro() { quote(0) };
+export let twice = fn(x) { x * 2 };
+`,
+		"lib/control.mk": `
+import { unless } from "./macros";
+export let pick = fn(c) { unless(c, "no", "yes") };
+`,
+		"broken.mk": `
+let x = 1;
+x + true;
+`,
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{
+			input:    `import "lib/math"; cube(3)`,
+			expected: &object.Integer{Value: 27},
+		},
+		{
+			input:    `import { square } from "lib/math.mk"; square(3)`,
+			expected: &object.Integer{Value: 9},
+		},
+		{
+			input:    `import { square } from "lib/math"; cube(3)`,
+			expected: &object.Error{Message: "could not resolve " + filepath.Join(dir, "main.mk") + ": 1:36: identifier not found: cube"},
+		},
+		{
+			input:    `import "lib/math"; hidden`,
+			expected: &object.Error{Message: "identifier not found: hidden"},
+		},
+		{
+			input:    `import { hidden } from "lib/math"`,
+			expected: &object.Error{Message: `hidden is not exported by "lib/math"`},
+		},
+		{
+			input:    `import "lib/geometry"; area(2)`,
+			expected: &object.Integer{Value: 12},
+		},
+		{
+			input:    `import { shout } from "strings"; shout("hi")`,
+			expected: &object.String{Value: "HI!"},
+		},
+		{
+			input:    `import { unless } from "lib/macros"; unless(false, 1, 2)`,
+			expected: &object.Integer{Value: 1},
+		},
+		{
+			input:    `import "lib/macros"; unless(true, 1, twice(2))`,
+			expected: &object.Integer{Value: 4},
+		},
+		{
+			input:    `import "lib/macros"; hidden_macro()`,
+			expected: &object.Error{Message: "identifier not found: hidden_macro"},
+		},
+		{
+			input:    `import { pick } from "lib/control"; pick(true)`,
+			expected: &object.String{Value: "yes"},
+		},
+		{
+			input:    `import "./strings"`,
+			expected: &object.Error{Message: `module not found: "./strings"`},
+		},
+		{
+			input:    `import "nothing"`,
+			expected: &object.Error{Message: `module not found: "nothing"`},
+		},
+		{
+			input: `import "cycle/a"`,
+			expected: &object.Error{Message: "cycle/a:2:1: ./b:2:1: import cycle: " +
+				filepath.Join(dir, "cycle/a.mk") + " -> " + filepath.Join(dir, "cycle/b.mk") + " -> " + filepath.Join(dir, "cycle/a.mk")},
+		},
+		{
+			input:    `import "broken"`,
+			expected: &object.Error{Message: "broken: type mismatch: INTEGER + BOOLEAN"},
+		},
+		{
+			input:    `if (true) { import "lib/math" }`,
+			expected: &object.Error{Message: "import is only allowed at the top level"},
+		},
+		{
+			input:    `fn() { export let x = 1; }()`,
+			expected: &object.Error{Message: "export is only allowed at the top level"},
+		},
+	}
+
+	for _, tt := range tests {
+		main := filepath.Join(dir, "main.mk")
+		if err := ioutil.WriteFile(main, []byte(tt.input), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		ip := New(nil, &bytes.Buffer{})
+		ip.SearchPath = []string{filepath.Join(dir, "vendor")}
+		evaluated := ip.EvalFile(main, object.NewEnvironment())
+		if err := testObject(evaluated, tt.expected); err != nil {
+			t.Errorf("case: %s. err: %s", tt.input, err)
+		}
+	}
+}
+
+func TestImportEvaluatesModuleOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-module")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"counter.mk": `puts("loaded"); export let value = 1;`,
+		"a.mk":       `import "counter"; export let a = value;`,
+		"b.mk":       `import "counter"; export let b = value + 1;`,
+		"main.mk":    `import "a"; import "b"; import { value } from "counter"; a + b + value`,
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	evaluated := New(nil, &out).EvalFile(filepath.Join(dir, "main.mk"), object.NewEnvironment())
+	if err := testObject(evaluated, &object.Integer{Value: 4}); err != nil {
+		t.Error(err)
+	}
+	if got := strings.Count(out.String(), "loaded"); got != 1 {
+		t.Errorf("module evaluated %d times, want 1", got)
+	}
+}
+
+func TestEvalFileOptimize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-module")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"units.mk": `export let day = 60 * 60 * 24; let debug = false; if (debug) { puts("units") };`,
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []string{
+		`import { day } from "units"; day * 7`,
+		`let greeting = "hello" + " " + "monkey"; puts(greeting); len(greeting)`,
+		`let f = fn(x) { if (1 < 2) { puts("small"); x * 2 } else { x } }; f(21)`,
+		`let v = if (false) { 1 }; v`,
+		`let n = 10; let sum = fn(i) { if (i == 0) { 0 } else { i + sum(i - 1) } }; sum(n)`,
+		`1 + true`,
+	}
+
+	for _, input := range tests {
+		main := filepath.Join(dir, "main.mk")
+		if err := ioutil.WriteFile(main, []byte(input), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var plainOut, optimizedOut bytes.Buffer
+		plain := New(nil, &plainOut).EvalFile(main, object.NewEnvironment())
+		ip := New(nil, &optimizedOut)
+		ip.Optimize = true
+		optimized := ip.EvalFile(main, object.NewEnvironment())
+
+		if err := testObject(optimized, plain); err != nil {
+			t.Errorf("case: %s. err: %s", input, err)
+		}
+		if optimizedOut.String() != plainOut.String() {
+			t.Errorf("case: %s. output differs. expected=%q, got=%q", input, plainOut.String(), optimizedOut.String())
+		}
+	}
+}
+
+func TestExpandFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey-module")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"unless.mk": `export let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) };`,
+		"main.mk":   `import { unless } from "unless"; unless(false, 1, 2)`,
+		"other.mk":  `unless(false, 1, 2)`,
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		// import は作業ディレクトリではなくファイルの位置から探す
+		{"main.mk", `if (!false) 1 else 2`},
+		// 前に展開したファイルのマクロは見えない
+		{"other.mk", `unless(false, 1, 2)`},
+	}
+
+	ip := New(nil, &bytes.Buffer{})
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		program := testParseProgram(files[tt.file])
+		expanded, _ := ip.ExpandFile(path, program)
+		if got := expanded.Statements[len(expanded.Statements)-1].String(); got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.file, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/parser"
+	"github.com/care0717/monkey-interpreter/prelude"
+	"strings"
+)
+
+// LoadPrelude は組み込みの標準ライブラリを評価する。
+// 以降 NewEnvironment が返す環境と、マクロを展開するときに標準ライブラリが見える。
+// サンドボックスで動かすときなど、標準ライブラリが不要なら呼ばなければよい
+func (ip *Interpreter) LoadPrelude() error {
+	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+
+	for _, file := range prelude.Files() {
+		p := parser.New(lexer.New(file.Source))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return fmt.Errorf("could not parse prelude %s: %s", file.Name, strings.Join(p.Errors(), "; "))
+		}
+
+		expanded, _, errors := ip.expandMacros(program, macroEnv)
+		if len(errors) != 0 {
+			return fmt.Errorf("could not expand macros in prelude %s: %s", file.Name, strings.Join(errors, "; "))
+		}
+		if errors := ip.Resolve(expanded.(*ast.Program), env); len(errors) != 0 {
+			return fmt.Errorf("could not resolve prelude %s: %s", file.Name, strings.Join(errors, "; "))
+		}
+
+		if err, ok := ip.Eval(expanded, env).(*object.Error); ok {
+			return fmt.Errorf("could not evaluate prelude %s: %s", file.Name, err.Inspect())
+		}
+	}
+
+	ip.preludeEnv = env
+	ip.preludeMacroEnv = macroEnv
+	return nil
+}
+
+// NewEnvironment はユーザーのコードを評価するための環境を作る
+func (ip *Interpreter) NewEnvironment() object.Environment {
+	if ip.preludeEnv == nil {
+		return object.NewEnvironment()
+	}
+	return object.NewEnclosedEnvironment(ip.preludeEnv)
+}
@@ -0,0 +1,90 @@
+package evaluator
+
+import (
+	"bytes"
+	"github.com/care0717/monkey-interpreter/object"
+	"testing"
+)
+
+func TestPrelude(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{
+			input:    `sum([1, 2, 3])`,
+			expected: &object.Integer{Value: 6},
+		},
+		{
+			input:    `product([1, 2, 3, 4])`,
+			expected: &object.Integer{Value: 24},
+		},
+		{
+			input:    `max([3, 9, 2])`,
+			expected: &object.Integer{Value: 9},
+		},
+		{
+			input:    `min([3, 9, 2])`,
+			expected: &object.Integer{Value: 2},
+		},
+		{
+			input:    `max([])`,
+			expected: object.NULL,
+		},
+		{
+			input:    `count([1, 2, 3], fn(x) { x > 1 })`,
+			expected: &object.Integer{Value: 2},
+		},
+		{
+			input:    `take([1, 2, 3], 2)`,
+			expected: &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}},
+		},
+		{
+			input:    `drop([1, 2, 3], 2)`,
+			expected: &object.Array{Elements: []object.Object{&object.Integer{Value: 3}}},
+		},
+		{
+			input:    `compose(fn(x) { x + 1 }, fn(x) { x * 2 })(5)`,
+			expected: &object.Integer{Value: 11},
+		},
+		{
+			input:    `flip(fn(a, b) { a - b })(1, 3)`,
+			expected: &object.Integer{Value: 2},
+		},
+		{
+			input:    `unless(1 > 2, "yes", "no")`,
+			expected: &object.String{Value: "yes"},
+		},
+		{
+			input:    `let sum = fn(arr) { 0 }; sum([1, 2])`,
+			expected: &object.Integer{Value: 0},
+		},
+	}
+
+	ip := New(nil, &bytes.Buffer{})
+	if err := ip.LoadPrelude(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		expanded, errors := ip.ExpandMacros(program)
+		if len(errors) != 0 {
+			t.Fatalf("case: %s. macro errors: %v", tt.input, errors)
+		}
+
+		evaluated := ip.Eval(expanded, ip.NewEnvironment())
+		if err := testObject(evaluated, tt.expected); err != nil {
+			t.Errorf("case: %s. err: %s", tt.input, err)
+		}
+	}
+}
+
+func TestWithoutPrelude(t *testing.T) {
+	ip := New(nil, &bytes.Buffer{})
+
+	evaluated := ip.Eval(testParseProgram(`sum([1, 2, 3])`), ip.NewEnvironment())
+	expected := &object.Error{Message: "identifier not found: sum"}
+	if err := testObject(evaluated, expected); err != nil {
+		t.Error(err)
+	}
+}
@@ -5,52 +5,271 @@ import (
 	"github.com/care0717/monkey-interpreter/ast"
 	"github.com/care0717/monkey-interpreter/object"
 	"github.com/care0717/monkey-interpreter/token"
+	"sort"
 )
 
-func quote(node ast.Node, env object.Environment) object.Object {
-	node = evalUnquoteCalls(node, env)
+func (ip *Interpreter) quote(node ast.Node, env object.Environment) object.Object {
+	node, err := ip.evalUnquoteCalls(node, env)
+	if err != nil {
+		return err
+	}
 	return &object.Quote{Node: node}
 }
 
-func evalUnquoteCalls(node ast.Node, env object.Environment) ast.Node {
-	return ast.Modify(node, func(node ast.Node) ast.Node {
-		if !isUnquoteCall(node) {
-			return node
+// evalUnquoteCalls は node の複製の中の unquote と unquote_splice の呼び出しを評価結果で置き換える。
+// quote された node は関数やマクロの本体の一部なので、書き換えると次の呼び出しに前回の結果が残ってしまう
+func (ip *Interpreter) evalUnquoteCalls(node ast.Node, env object.Environment) (ast.Node, *object.Error) {
+	var unquoteErr *object.Error
+	fail := func(err *object.Error) {
+		if unquoteErr == nil {
+			unquoteErr = err
 		}
+	}
 
-		call, ok := node.(*ast.CallExpression)
-		if !ok {
+	modified, err := ast.ModifyCopy(node, func(node ast.Node) ast.Node {
+		if unquoteErr != nil {
 			return node
 		}
-		if len(call.Arguments) != 1 {
-			return node
+
+		var err *object.Error
+		switch node := node.(type) {
+		case *ast.CallExpression:
+			if isUnquoteCall(node) {
+				return ip.evalUnquoteCall(node, env, fail)
+			}
+			node.Arguments, err = ip.spliceExpressions(node.Arguments, env)
+		case *ast.ArrayLiteral:
+			node.Elements, err = ip.spliceExpressions(node.Elements, env)
+		case *ast.BlockStatement:
+			node.Statements, err = ip.spliceStatements(node.Statements, env)
+		}
+		if err != nil {
+			fail(err)
 		}
-		unquoted := Eval(call.Arguments[0], env)
-		return convertObjectToASTNode(unquoted)
+		return node
 	})
+	if unquoteErr != nil {
+		return nil, unquoteErr
+	}
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+
+	// 並びの中にない unquote_splice は展開できない
+	ast.Inspect(modified, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok && isUnquoteSpliceCall(call) {
+			fail(newErrorAt(call.Token.Pos, "unquote_splice is only allowed in call arguments, array elements or block statements"))
+			return false
+		}
+		return unquoteErr == nil
+	})
+	if unquoteErr != nil {
+		return nil, unquoteErr
+	}
+	return modified, nil
+}
+
+func (ip *Interpreter) evalUnquoteCall(call *ast.CallExpression, env object.Environment, fail func(*object.Error)) ast.Node {
+	if len(call.Arguments) != 1 {
+		return call
+	}
+	unquoted := ip.Eval(call.Arguments[0], env)
+	if errObj, ok := unquoted.(*object.Error); ok {
+		fail(errObj)
+		return call
+	}
+	converted, err := convertObjectToASTNode(unquoted, call.Token.Pos)
+	if err != nil {
+		fail(err)
+		return call
+	}
+	return converted
+}
+
+// spliceExpressions は exps の中の unquote_splice の呼び出しを、引数の配列の要素に置き換える
+func (ip *Interpreter) spliceExpressions(exps []ast.Expression, env object.Environment) ([]ast.Expression, *object.Error) {
+	if !containsSplice(exps) {
+		return exps, nil
+	}
+
+	spliced := make([]ast.Expression, 0, len(exps))
+	for _, exp := range exps {
+		call, ok := exp.(*ast.CallExpression)
+		if !ok || !isUnquoteSpliceCall(call) {
+			spliced = append(spliced, exp)
+			continue
+		}
+
+		nodes, err := ip.evalUnquoteSplice(call, env)
+		if err != nil {
+			return exps, err
+		}
+		for _, node := range nodes {
+			e, ok := node.(ast.Expression)
+			if !ok {
+				return exps, newErrorAt(call.Token.Pos, "cannot splice %T into an expression list", node)
+			}
+			spliced = append(spliced, e)
+		}
+	}
+	return spliced, nil
+}
+
+// spliceStatements は unquote_splice の呼び出しだけの文を、引数の配列の要素の文に置き換える。式は式文にする
+func (ip *Interpreter) spliceStatements(statements []ast.Statement, env object.Environment) ([]ast.Statement, *object.Error) {
+	var exps []ast.Expression
+	for _, stmt := range statements {
+		if es, ok := stmt.(*ast.ExpressionStatement); ok {
+			exps = append(exps, es.Expression)
+		}
+	}
+	if !containsSplice(exps) {
+		return statements, nil
+	}
+
+	spliced := make([]ast.Statement, 0, len(statements))
+	for _, stmt := range statements {
+		es, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			spliced = append(spliced, stmt)
+			continue
+		}
+		call, ok := es.Expression.(*ast.CallExpression)
+		if !ok || !isUnquoteSpliceCall(call) {
+			spliced = append(spliced, stmt)
+			continue
+		}
+
+		nodes, err := ip.evalUnquoteSplice(call, env)
+		if err != nil {
+			return statements, err
+		}
+		for _, node := range nodes {
+			switch node := node.(type) {
+			case ast.Statement:
+				spliced = append(spliced, node)
+			case ast.Expression:
+				spliced = append(spliced, &ast.ExpressionStatement{Token: es.Token, Expression: node})
+			}
+		}
+	}
+	return spliced, nil
 }
 
-func convertObjectToASTNode(obj object.Object) ast.Node {
+// evalUnquoteSplice は unquote_splice の引数を評価し、配列の要素をそれぞれ AST に戻す
+func (ip *Interpreter) evalUnquoteSplice(call *ast.CallExpression, env object.Environment) ([]ast.Node, *object.Error) {
+	if len(call.Arguments) != 1 {
+		return nil, newErrorAt(call.Token.Pos, "wrong number of arguments to `unquote_splice`. got=%d, want=1", len(call.Arguments))
+	}
+	evaluated := ip.Eval(call.Arguments[0], env)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		return nil, errObj
+	}
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		return nil, newErrorAt(call.Token.Pos, "argument to `unquote_splice` must be ARRAY, got %s", evaluated.Type())
+	}
+
+	nodes := make([]ast.Node, len(array.Elements))
+	for i, element := range array.Elements {
+		node, err := convertObjectToASTNode(element, call.Token.Pos)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+// convertObjectToASTNode は obj を、評価すると obj と同じ値になる AST に戻す。作るトークンの位置は pos にする
+func convertObjectToASTNode(obj object.Object, pos token.Position) (ast.Node, *object.Error) {
 	switch obj := obj.(type) {
 	case *object.Integer:
 		t := token.Token{
 			Type:    token.INT,
 			Literal: fmt.Sprintf("%d", obj.Value),
+			Pos:     pos,
 		}
-		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, nil
 	case object.Boolean:
 		var t token.Token
 		if obj.Value() {
-			t = token.Token{Type: token.TRUE, Literal: "true"}
+			t = token.Token{Type: token.TRUE, Literal: "true", Pos: pos}
 		} else {
-			t = token.Token{Type: token.FALSE, Literal: "false"}
+			t = token.Token{Type: token.FALSE, Literal: "false", Pos: pos}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value()}, nil
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Pos: pos}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Array:
+		elements := make([]ast.Expression, len(obj.Elements))
+		for i, element := range obj.Elements {
+			exp, err := convertObjectToExpression(element, pos)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = exp
 		}
-		return &ast.Boolean{Token: t, Value: obj.Value()}
+		t := token.Token{Type: token.LBRACKET, Literal: "[", Pos: pos}
+		return &ast.ArrayLiteral{Token: t, Elements: elements}, nil
+	case *object.Hash:
+		pairs := make([]object.HashPair, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, pair)
+		}
+		// ハッシュは順序を持たないので、出力が毎回同じになるようにキーで並べる
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key.Inspect() < pairs[j].Key.Inspect() })
+
+		hashPairs := make([]ast.HashPair, len(pairs))
+		for i, pair := range pairs {
+			key, err := convertObjectToExpression(pair.Key, pos)
+			if err != nil {
+				return nil, err
+			}
+			value, err := convertObjectToExpression(pair.Value, pos)
+			if err != nil {
+				return nil, err
+			}
+			hashPairs[i] = ast.HashPair{Key: key, Value: value}
+		}
+		t := token.Token{Type: token.LBRACE, Literal: "{", Pos: pos}
+		return &ast.HashLiteral{Token: t, Pairs: hashPairs}, nil
 	case *object.Quote:
-		return obj.Node
-	default:
-		return nil
+		return obj.Node, nil
+	}
+
+	if obj == object.NULL {
+		// null のリテラルはないので、評価すると null になる if (false) {} で表す
+		return &ast.IfExpression{
+			Token:       token.Token{Type: token.IF, Literal: "if", Pos: pos},
+			Condition:   &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false", Pos: pos}, Value: false},
+			Consequence: &ast.BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{", Pos: pos}, Statements: []ast.Statement{}},
+		}, nil
+	}
+	return nil, newErrorAt(pos, "cannot unquote %s", obj.Type())
+}
+
+// convertObjectToExpression は配列やハッシュの要素を式に戻す
+func convertObjectToExpression(obj object.Object, pos token.Position) (ast.Expression, *object.Error) {
+	node, err := convertObjectToASTNode(obj, pos)
+	if err != nil {
+		return nil, err
 	}
+	exp, ok := node.(ast.Expression)
+	if !ok {
+		return nil, newErrorAt(pos, "cannot unquote %T as an expression", node)
+	}
+	return exp, nil
+}
+
+func containsSplice(exps []ast.Expression) bool {
+	for _, exp := range exps {
+		if call, ok := exp.(*ast.CallExpression); ok && isUnquoteSpliceCall(call) {
+			return true
+		}
+	}
+	return false
 }
 
 func isUnquoteCall(node ast.Node) bool {
@@ -61,3 +280,7 @@ func isUnquoteCall(node ast.Node) bool {
 
 	return callExpression.Function.TokenLiteral() == "unquote"
 }
+
+func isUnquoteSpliceCall(call *ast.CallExpression) bool {
+	return call.Function.TokenLiteral() == "unquote_splice"
+}
@@ -0,0 +1,339 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/care0717/monkey-interpreter/object"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	for name, builtin := range stringBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// 文字列の位置はすべてバイトではなくルーン単位で扱う
+var stringBuiltins = map[string]*object.Builtin{
+	"split": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("split", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+
+		s := args[0].(*object.String).Value
+		sep := args[1].(*object.String).Value
+		return stringsToArray(strings.Split(s, sep))
+	}},
+	"join": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("join", args, object.ARRAY_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+
+		elements := args[0].(*object.Array).Elements
+		sep := args[1].(*object.String).Value
+		parts := make([]string, len(elements))
+		for i, e := range elements {
+			str, ok := e.(*object.String)
+			if !ok {
+				return newError("argument to `join` must be ARRAY of STRING, got %s at index %d", e.Type(), i)
+			}
+			parts[i] = str.Value
+		}
+		return &object.String{Value: strings.Join(parts, sep)}
+	}},
+	"trim": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		switch len(args) {
+		case 1:
+			if err := checkArgs("trim", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.TrimSpace(args[0].(*object.String).Value)}
+		case 2:
+			if err := checkArgs("trim", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			s := args[0].(*object.String).Value
+			cutset := args[1].(*object.String).Value
+			return &object.String{Value: strings.Trim(s, cutset)}
+		default:
+			return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+		}
+	}},
+	"upper": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("upper", args, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+	}},
+	"lower": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("lower", args, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+	}},
+	"replace": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("replace", args, object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+
+		s := args[0].(*object.String).Value
+		old := args[1].(*object.String).Value
+		new := args[2].(*object.String).Value
+		return &object.String{Value: strings.ReplaceAll(s, old, new)}
+	}},
+	"contains": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("contains", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+
+		s := args[0].(*object.String).Value
+		substr := args[1].(*object.String).Value
+		return nativeBoolToBooleanObject(strings.Contains(s, substr))
+	}},
+	"starts_with": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("starts_with", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+
+		s := args[0].(*object.String).Value
+		prefix := args[1].(*object.String).Value
+		return nativeBoolToBooleanObject(strings.HasPrefix(s, prefix))
+	}},
+	"ends_with": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("ends_with", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+
+		s := args[0].(*object.String).Value
+		suffix := args[1].(*object.String).Value
+		return nativeBoolToBooleanObject(strings.HasSuffix(s, suffix))
+	}},
+	"index_of": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("index_of", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+
+		s := args[0].(*object.String).Value
+		substr := args[1].(*object.String).Value
+		idx := strings.Index(s, substr)
+		if idx < 0 {
+			return &object.Integer{Value: -1}
+		}
+		return &object.Integer{Value: int64(utf8.RuneCountInString(s[:idx]))}
+	}},
+	"substr": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		var runes []rune
+		var start, length int64
+		switch len(args) {
+		case 2:
+			if err := checkArgs("substr", args, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+			runes = []rune(args[0].(*object.String).Value)
+			start = args[1].(*object.Integer).Value
+			length = int64(len(runes)) - start
+		case 3:
+			if err := checkArgs("substr", args, object.STRING_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+			runes = []rune(args[0].(*object.String).Value)
+			start = args[1].(*object.Integer).Value
+			length = args[2].(*object.Integer).Value
+		default:
+			return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+		}
+
+		if start < 0 || start > int64(len(runes)) {
+			return newError("start index out of range for `substr`: %d with length %d", start, len(runes))
+		}
+		if length < 0 || length > int64(len(runes))-start {
+			return newError("length out of range for `substr`: %d from %d with length %d", length, start, len(runes))
+		}
+		return &object.String{Value: string(runes[start : start+length])}
+	}},
+	"repeat": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("repeat", args, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+			return err
+		}
+
+		s := args[0].(*object.String).Value
+		count := args[1].(*object.Integer).Value
+		if count < 0 {
+			return newError("negative count for `repeat`: %d", count)
+		}
+		if count > 0 && int64(len(s)) > maxStringLength/count {
+			return newError("result of `repeat` too long: %d bytes times %d", len(s), count)
+		}
+		return &object.String{Value: strings.Repeat(s, int(count))}
+	}},
+	"chars": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if err := checkArgs("chars", args, object.STRING_OBJ); err != nil {
+			return err
+		}
+
+		var chars []string
+		for _, r := range args[0].(*object.String).Value {
+			chars = append(chars, string(r))
+		}
+		return stringsToArray(chars)
+	}},
+	"format": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if len(args) < 1 {
+			return newError("wrong number of arguments. got=%d, want=1 or more", len(args))
+		}
+		f, ok := args[0].(*object.String)
+		if !ok {
+			return newError("argument 1 to `format` must be STRING, got %s", args[0].Type())
+		}
+
+		if err := checkFormat(f.Value, args[1:]); err != nil {
+			return err
+		}
+		values := make([]interface{}, len(args)-1)
+		for i, arg := range args[1:] {
+			values[i] = objectToNative(arg)
+		}
+		return &object.String{Value: fmt.Sprintf(f.Value, values...)}
+	}},
+	"to_int": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		switch arg := args[0].(type) {
+		case *object.Integer:
+			return arg
+		case *object.String:
+			value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+			if err != nil {
+				return newError("could not parse %q as integer", arg.Value)
+			}
+			return &object.Integer{Value: value}
+		case object.Boolean:
+			if arg.Value() {
+				return &object.Integer{Value: 1}
+			}
+			return &object.Integer{Value: 0}
+		default:
+			return newError("argument to `to_int` not supported, got %s", arg.Type())
+		}
+	}},
+	"to_string": {Fn: func(ctx object.CallContext, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		if str, ok := args[0].(*object.String); ok {
+			return str
+		}
+		return &object.String{Value: args[0].Inspect()}
+	}},
+}
+
+// maxStringLength は repeat が作る文字列の長さの上限
+const maxStringLength = 1 << 30
+
+// formatVerbs は format の動詞ごとに、受け付ける引数の型。v はどの型でも受け付ける
+var formatVerbs = map[rune][]object.Type{
+	'd': {object.INTEGER_OBJ},
+	'b': {object.INTEGER_OBJ},
+	'o': {object.INTEGER_OBJ},
+	'c': {object.INTEGER_OBJ},
+	'U': {object.INTEGER_OBJ},
+	'x': {object.INTEGER_OBJ, object.STRING_OBJ},
+	'X': {object.INTEGER_OBJ, object.STRING_OBJ},
+	'q': {object.INTEGER_OBJ, object.STRING_OBJ},
+	's': {object.STRING_OBJ},
+	't': {object.BOOLEAN_OBJ},
+}
+
+// checkFormat は format の書式の動詞と引数の型が合っているかを検査する。
+// fmt.Sprintf は合わない引数を %!d(string=x) のように出力に埋め込むだけなので、先にエラーにする
+func checkFormat(format string, args []object.Object) *object.Error {
+	n := 0
+	runes := []rune(format)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' {
+			continue
+		}
+		// フラグ、幅、精度を読み飛ばす
+		for i++; i < len(runes) && strings.ContainsRune("+-# 0123456789.", runes[i]); i++ {
+		}
+		if i == len(runes) {
+			return newError("missing verb at end of format for `format`")
+		}
+		verb := runes[i]
+		if verb == '%' {
+			continue
+		}
+		types, ok := formatVerbs[verb]
+		if !ok && verb != 'v' {
+			return newError("unsupported verb for `format`: %%%c", verb)
+		}
+		if n == len(args) {
+			return newError("missing argument for %%%c in `format`", verb)
+		}
+		arg := args[n]
+		n++
+		if verb == 'v' {
+			continue
+		}
+		// 整数、文字列、真偽値以外は Inspect した文字列として渡す
+		t := arg.Type()
+		if t != object.INTEGER_OBJ && t != object.BOOLEAN_OBJ {
+			t = object.STRING_OBJ
+		}
+		if !containsType(types, t) {
+			return newError("argument %d to `format` does not match %%%c, got %s", n+1, verb, arg.Type())
+		}
+	}
+	if n < len(args) {
+		return newError("too many arguments to `format`. got=%d, want=%d", len(args)+1, n+1)
+	}
+	return nil
+}
+
+func containsType(types []object.Type, t object.Type) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+// checkArgs は引数の個数と型を検査し、合わなければエラーを返す
+func checkArgs(name string, args []object.Object, types ...object.Type) *object.Error {
+	if len(args) != len(types) {
+		return newError("wrong number of arguments. got=%d, want=%d", len(args), len(types))
+	}
+
+	for i, t := range types {
+		if args[i].Type() != t {
+			return newError("argument %d to `%s` must be %s, got %s", i+1, name, t, args[i].Type())
+		}
+	}
+	return nil
+}
+
+func stringsToArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func objectToNative(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case object.Boolean:
+		return obj.Value()
+	default:
+		return obj.Inspect()
+	}
+}
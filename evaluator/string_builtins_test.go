@@ -0,0 +1,178 @@
+package evaluator
+
+import (
+	"github.com/care0717/monkey-interpreter/object"
+	"testing"
+)
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{
+			input: `split("a,b,c", ",")`,
+			expected: &object.Array{Elements: []object.Object{
+				&object.String{Value: "a"},
+				&object.String{Value: "b"},
+				&object.String{Value: "c"},
+			}},
+		},
+		{
+			input:    `split(1, ",")`,
+			expected: &object.Error{Message: "argument 1 to `split` must be STRING, got INTEGER"},
+		},
+		{
+			input:    `join(["a", "b", "c"], "-")`,
+			expected: &object.String{Value: "a-b-c"},
+		},
+		{
+			input:    `join(["a", 1], "-")`,
+			expected: &object.Error{Message: "argument to `join` must be ARRAY of STRING, got INTEGER at index 1"},
+		},
+		{
+			input:    `trim("  monkey ")`,
+			expected: &object.String{Value: "monkey"},
+		},
+		{
+			input:    `trim("xxmonkeyx", "x")`,
+			expected: &object.String{Value: "monkey"},
+		},
+		{
+			input:    `trim()`,
+			expected: &object.Error{Message: "wrong number of arguments. got=0, want=1 or 2"},
+		},
+		{
+			input:    `upper("Monkey")`,
+			expected: &object.String{Value: "MONKEY"},
+		},
+		{
+			input:    `lower("Monkey")`,
+			expected: &object.String{Value: "monkey"},
+		},
+		{
+			input:    `replace("banana", "a", "o")`,
+			expected: &object.String{Value: "bonono"},
+		},
+		{
+			input:    `contains("monkey", "key")`,
+			expected: object.TRUE,
+		},
+		{
+			input:    `contains("monkey", "ape")`,
+			expected: object.FALSE,
+		},
+		{
+			input:    `starts_with("monkey", "mon")`,
+			expected: object.TRUE,
+		},
+		{
+			input:    `ends_with("monkey", "mon")`,
+			expected: object.FALSE,
+		},
+		{
+			input:    `index_of("おさるmonkey", "key")`,
+			expected: &object.Integer{Value: 6},
+		},
+		{
+			input:    `index_of("monkey", "ape")`,
+			expected: &object.Integer{Value: -1},
+		},
+		{
+			input:    `substr("おさるmonkey", 3)`,
+			expected: &object.String{Value: "monkey"},
+		},
+		{
+			input:    `substr("monkey", 1, 3)`,
+			expected: &object.String{Value: "onk"},
+		},
+		{
+			input:    `substr("monkey", 4, 3)`,
+			expected: &object.Error{Message: "length out of range for `substr`: 3 from 4 with length 6"},
+		},
+		{
+			input:    `substr("monkey", -1)`,
+			expected: &object.Error{Message: "start index out of range for `substr`: -1 with length 6"},
+		},
+		{
+			input:    `repeat("ab", 3)`,
+			expected: &object.String{Value: "ababab"},
+		},
+		{
+			input:    `repeat("ab", -1)`,
+			expected: &object.Error{Message: "negative count for `repeat`: -1"},
+		},
+		{
+			input:    `repeat("ab", 9223372036854775807)`,
+			expected: &object.Error{Message: "result of `repeat` too long: 2 bytes times 9223372036854775807"},
+		},
+		{
+			input:    `substr("abc", 1, 9223372036854775807)`,
+			expected: &object.Error{Message: "length out of range for `substr`: 9223372036854775807 from 1 with length 3"},
+		},
+		{
+			input: `chars("aあ")`,
+			expected: &object.Array{Elements: []object.Object{
+				&object.String{Value: "a"},
+				&object.String{Value: "あ"},
+			}},
+		},
+		{
+			input:    `format("%s is %d years old: %t", "monkey", 3, true)`,
+			expected: &object.String{Value: "monkey is 3 years old: true"},
+		},
+		{
+			input:    `format("%v", [1, 2])`,
+			expected: &object.String{Value: "[1, 2]"},
+		},
+		{
+			input:    `format("%5d%% of %s", 42, [1])`,
+			expected: &object.String{Value: "   42% of [1]"},
+		},
+		{
+			input:    `format("%d", "x")`,
+			expected: &object.Error{Message: "argument 2 to `format` does not match %d, got STRING"},
+		},
+		{
+			input:    `format("%d and %d", 1)`,
+			expected: &object.Error{Message: "missing argument for %d in `format`"},
+		},
+		{
+			input:    `format("%d", 1, 2)`,
+			expected: &object.Error{Message: "too many arguments to `format`. got=3, want=2"},
+		},
+		{
+			input:    `format("%f", 1)`,
+			expected: &object.Error{Message: "unsupported verb for `format`: %f"},
+		},
+		{
+			input:    `format(1)`,
+			expected: &object.Error{Message: "argument 1 to `format` must be STRING, got INTEGER"},
+		},
+		{
+			input:    `to_int("42")`,
+			expected: &object.Integer{Value: 42},
+		},
+		{
+			input:    `to_int("forty two")`,
+			expected: &object.Error{Message: `could not parse "forty two" as integer`},
+		},
+		{
+			input:    `to_int([])`,
+			expected: &object.Error{Message: "argument to `to_int` not supported, got ARRAY"},
+		},
+		{
+			input:    `to_string(42)`,
+			expected: &object.String{Value: "42"},
+		},
+		{
+			input:    `to_string([1, "a"])`,
+			expected: &object.String{Value: "[1, a]"},
+		},
+	}
+	if errors := testEval(tests); errors != nil {
+		for _, err := range errors {
+			t.Error(err)
+		}
+	}
+}
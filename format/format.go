@@ -0,0 +1,498 @@
+package format
+
+import (
+	"errors"
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/parser"
+	"github.com/care0717/monkey-interpreter/token"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	indentUnit = "  "
+	// maxWidth を超える呼び出し・配列・ハッシュは要素ごとに改行する
+	maxWidth = 80
+)
+
+// 括弧が必要かを決めるための優先順位。parser と同じ順に並べる
+const (
+	_ int = iota
+	lowest
+	equals
+	lessGreater
+	sum
+	product
+	prefix
+	call
+	index
+)
+
+var precedences = map[string]int{
+	"==": equals,
+	"!=": equals,
+	"<":  lessGreater,
+	">":  lessGreater,
+	"+":  sum,
+	"-":  sum,
+	"*":  product,
+	"/":  product,
+}
+
+// Source は src を標準の形に整形する。
+// コメントは直後の文の前か、同じ行の文の後ろに残す。式の途中にあるコメントは文の後ろに移る。
+// 1行で書かれたブロックは1行のまま、複数行のブロックは1文ずつ改行して出力する
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	pr := newPrinter(string(src))
+	pr.program(program)
+	return pr.out, nil
+}
+
+// Program は元のソースのない構文木を標準の形で出力する。マクロの展開や最適化の後の木を読める形で示すのに使う。
+// コメントと空行は残らず、ブロックは1文ずつ改行して出力する
+func Program(program *ast.Program) []byte {
+	pr := newPrinter("")
+	pr.program(program)
+	return pr.out
+}
+
+// printer は出力中の状態を持つ。長い式を1行に収められるか試すときは fork した printer に書く
+type printer struct {
+	src      []string // 元のソースの各行
+	tokens   []token.Token
+	index    map[token.Position]int            // トークンの位置から tokens の添字を引く
+	closing  map[token.Position]token.Position // { の位置から対応する } の位置を引く
+	comments []token.Comment
+	next     int // 次に出力するコメント
+
+	out    []byte
+	indent int
+	col    int  // 現在の行にすでに書いた文字数
+	flat   bool // true なら呼び出し・配列・ハッシュを改行しない
+}
+
+func newPrinter(src string) *printer {
+	p := &printer{
+		src:     strings.Split(src, "\n"),
+		index:   make(map[token.Position]int),
+		closing: make(map[token.Position]token.Position),
+	}
+
+	l := lexer.New(src)
+	var open []token.Position
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		p.index[tok.Pos] = len(p.tokens)
+		p.tokens = append(p.tokens, tok)
+
+		switch tok.Type {
+		case token.LBRACE:
+			open = append(open, tok.Pos)
+		case token.RBRACE:
+			if len(open) > 0 {
+				p.closing[open[len(open)-1]] = tok.Pos
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	p.comments = l.Comments()
+	return p
+}
+
+func (p *printer) fork() *printer {
+	forked := *p
+	forked.out = nil
+	return &forked
+}
+
+func (p *printer) adopt(forked *printer) {
+	p.out = append(p.out, forked.out...)
+	p.col = forked.col
+	p.next = forked.next
+}
+
+// fits は fork した printer の出力の最初と最後の行が幅に収まるかを返す。
+// 間の行は入れ子のブロックで、それぞれ整形済みなので見ない
+func (p *printer) fits(forked *printer) bool {
+	lines := strings.Split(string(forked.out), "\n")
+	if p.col+utf8.RuneCountInString(lines[0]) > maxWidth {
+		return false
+	}
+	return utf8.RuneCountInString(lines[len(lines)-1]) <= maxWidth
+}
+
+func (p *printer) write(s string) {
+	p.out = append(p.out, s...)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.col = utf8.RuneCountInString(s[i+1:])
+	} else {
+		p.col += utf8.RuneCountInString(s)
+	}
+}
+
+func (p *printer) newline() {
+	p.out = append(p.out, '\n')
+	p.col = 0
+	p.write(strings.Repeat(indentUnit, p.indent))
+}
+
+func (p *printer) blankLine() {
+	p.out = append(p.out, '\n')
+}
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements, token.Position{})
+	// 先頭の改行を取り除き、末尾に改行をひとつつける
+	p.out = append([]byte(strings.TrimLeft(string(p.out), "\n")), '\n')
+	if len(p.out) == 1 {
+		p.out = nil
+	}
+}
+
+// statements は文の並びを1文ずつ改行して出力する。closing は並びを閉じる } の位置で、プログラム全体なら位置を持たない
+func (p *printer) statements(stmts []ast.Statement, closing token.Position) {
+	last := -1 // 直前に出力した文やコメントの元の行。空行を保つのに使う
+	for i, stmt := range stmts {
+		start := statementPos(stmt)
+		last = p.ownLineComments(start.Line, last)
+
+		if last >= 0 && start.Line > last+1 {
+			p.blankLine()
+		}
+		p.newline()
+		p.statement(stmt)
+
+		end := p.statementEnd(stmts, i, closing)
+		last = p.trailingComments(end)
+	}
+	if closing.IsValid() {
+		p.ownLineComments(closing.Line, last)
+	} else {
+		p.ownLineComments(-1, last)
+	}
+}
+
+// ownLineComments は before の行より前にあるコメントをそれぞれ1行に出力する。before が負ならすべて出力する
+func (p *printer) ownLineComments(before, last int) int {
+	for ; p.next < len(p.comments); p.next++ {
+		c := p.comments[p.next]
+		if before >= 0 && c.Pos.Line >= before {
+			break
+		}
+		if last >= 0 && c.Pos.Line > last+1 {
+			p.blankLine()
+		}
+		p.newline()
+		p.write(c.Text)
+		last = c.Pos.Line
+	}
+	return last
+}
+
+// trailingComments は end の行までに残っているコメントを文の後ろに出力し、最後に出力した元の行を返す。
+// 最初のコメントが行末のコメントなら文と同じ行に置く
+func (p *printer) trailingComments(end int) int {
+	last := end
+	for first := true; p.next < len(p.comments); first = false {
+		c := p.comments[p.next]
+		if c.Pos.Line > end {
+			break
+		}
+		if first && !p.ownLine(c) {
+			p.write(" " + c.Text)
+		} else {
+			p.newline()
+			p.write(c.Text)
+		}
+		if c.Pos.Line > last {
+			last = c.Pos.Line
+		}
+		p.next++
+	}
+	return last
+}
+
+// ownLine はコメントの前に同じ行のコードがないかを返す
+func (p *printer) ownLine(c token.Comment) bool {
+	line := p.src[c.Pos.Line-1]
+	return strings.TrimSpace(line[:c.Pos.Column-1]) == ""
+}
+
+// statementEnd は stmts[i] の最後のトークンの行を返す。closing は並びを閉じる } の位置
+func (p *printer) statementEnd(stmts []ast.Statement, i int, closing token.Position) int {
+	if len(p.tokens) == 0 {
+		// 元のソースがなければ、空行を保つための行を数えない
+		return -1
+	}
+	next := len(p.tokens)
+	switch {
+	case i+1 < len(stmts):
+		next = p.index[statementPos(stmts[i+1])]
+	case closing.IsValid():
+		next = p.index[closing]
+	}
+	return p.tokens[next-1].Pos.Line
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.let(stmt)
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(stmt.ReturnValue, lowest)
+		p.write(";")
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, lowest)
+		// if は } で終わるので ; をつけない
+		if _, ok := stmt.Expression.(*ast.IfExpression); !ok {
+			p.write(";")
+		}
+	case *ast.ImportStatement:
+		p.write("import ")
+		if stmt.Names != nil {
+			p.write("{ " + identifiers(stmt.Names) + " } from ")
+		}
+		p.write(`"` + stmt.Path.Token.Literal + `";`)
+	case *ast.ExportStatement:
+		p.write("export ")
+		p.let(stmt.Let)
+	}
+}
+
+func (p *printer) let(stmt *ast.LetStatement) {
+	p.write("let " + stmt.Name.Value)
+	if stmt.Type != nil {
+		p.write(": " + stmt.Type.String())
+	}
+	p.write(" = ")
+	p.expression(stmt.Value, lowest)
+	p.write(";")
+}
+
+// block は元のソースで1行だったブロックを1行のまま、それ以外を1文ずつ改行して出力する
+func (p *printer) block(block *ast.BlockStatement) {
+	closing := p.closing[block.Token.Pos]
+
+	if len(block.Statements) == 0 && !p.hasCommentBefore(closing.Line) {
+		p.write("{}")
+		return
+	}
+	if closing.IsValid() && block.Token.Pos.Line == closing.Line && len(block.Statements) == 1 {
+		if es, ok := block.Statements[0].(*ast.ExpressionStatement); ok {
+			forked := p.fork()
+			forked.flat = true
+			forked.write("{ ")
+			forked.expression(es.Expression, lowest)
+			forked.write(" }")
+			if !strings.Contains(string(forked.out), "\n") && p.fits(forked) {
+				p.adopt(forked)
+				return
+			}
+		}
+	}
+
+	flat := p.flat
+	p.flat = false
+	p.write("{")
+	p.indent++
+	p.statements(block.Statements, closing)
+	p.indent--
+	p.newline()
+	p.write("}")
+	p.flat = flat
+}
+
+func (p *printer) hasCommentBefore(line int) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Pos.Line < line
+}
+
+// expression は exp を出力する。outer は exp を囲む式の優先順位で、exp の方が弱ければ括弧をつける
+func (p *printer) expression(exp ast.Expression, outer int) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.write(exp.Value)
+	case *ast.IntegerLiteral:
+		p.write(exp.Token.Literal)
+	case *ast.Boolean:
+		p.write(exp.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(`"` + exp.Token.Literal + `"`)
+	case *ast.PrefixExpression:
+		p.write(exp.Operator)
+		p.expression(exp.Right, prefix)
+	case *ast.InfixExpression:
+		p.infix(exp, outer)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(exp.Condition, lowest)
+		p.write(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.write(" else ")
+			p.block(exp.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.write("fn(" + ast.Parameters(exp.Parameters, exp.ParameterTypes) + ") ")
+		if exp.ReturnType != nil {
+			p.write("-> " + exp.ReturnType.String() + " ")
+		}
+		p.block(exp.Body)
+	case *ast.MacroLiteral:
+		p.write("macro(" + identifiers(exp.Parameters) + ") ")
+		p.block(exp.Body)
+	case *ast.CallExpression:
+		p.operand(exp.Function)
+		p.list("(", ")", len(exp.Arguments), func(q *printer, i int) {
+			q.expression(exp.Arguments[i], lowest)
+		})
+	case *ast.ArrayLiteral:
+		p.list("[", "]", len(exp.Elements), func(q *printer, i int) {
+			q.expression(exp.Elements[i], lowest)
+		})
+	case *ast.HashLiteral:
+		p.list("{", "}", len(exp.Pairs), func(q *printer, i int) {
+			q.expression(exp.Pairs[i].Key, lowest)
+			q.write(": ")
+			q.expression(exp.Pairs[i].Value, lowest)
+		})
+	case *ast.IndexExpression:
+		p.operand(exp.Left)
+		p.write("[")
+		p.expression(exp.Index, lowest)
+		p.write("]")
+	case *ast.SliceExpression:
+		p.operand(exp.Left)
+		p.write("[")
+		if exp.Low != nil {
+			p.expression(exp.Low, lowest)
+		}
+		p.write(":")
+		if exp.High != nil {
+			p.expression(exp.High, lowest)
+		}
+		p.write("]")
+	}
+}
+
+// infix は + だけが右結合であることを考えて、必要なところにだけ括弧をつける
+func (p *printer) infix(exp *ast.InfixExpression, outer int) {
+	prec := precedences[exp.Operator]
+	if prec < outer {
+		p.write("(")
+		defer p.write(")")
+	}
+
+	// 左の項は、その演算子の右側がこの演算子で止まるときだけ括弧なしで書ける
+	if left, ok := exp.Left.(*ast.InfixExpression); ok && rightPrecedence(left.Operator) < prec {
+		p.write("(")
+		p.expression(left, lowest)
+		p.write(")")
+	} else {
+		p.expression(exp.Left, prec)
+	}
+
+	p.write(" " + exp.Operator + " ")
+
+	// 右の項は、この演算子の右側を読むときに取り込まれる演算子なら括弧なしで書ける
+	if right, ok := exp.Right.(*ast.InfixExpression); ok && precedences[right.Operator] <= rightPrecedence(exp.Operator) {
+		p.write("(")
+		p.expression(right, lowest)
+		p.write(")")
+	} else {
+		p.expression(exp.Right, prec)
+	}
+}
+
+// rightPrecedence は演算子の右側を読むときに parser が使う優先順位
+func rightPrecedence(operator string) int {
+	if operator == "+" {
+		return precedences[operator] - 1
+	}
+	return precedences[operator]
+}
+
+// operand は呼び出しや添字の対象を出力する。前置・中置の式には括弧が必要
+func (p *printer) operand(exp ast.Expression) {
+	switch exp.(type) {
+	case *ast.PrefixExpression, *ast.InfixExpression:
+		p.write("(")
+		p.expression(exp, lowest)
+		p.write(")")
+	default:
+		p.expression(exp, call)
+	}
+}
+
+// list は要素を1行に並べ、収まらなければ1要素ずつ改行して出力する
+func (p *printer) list(open, close string, n int, item func(q *printer, i int)) {
+	if n == 0 {
+		p.write(open + close)
+		return
+	}
+
+	// 入れ子の要素は改行せずに並べてみて、収まらなければ外側から改行する
+	forked := p.fork()
+	forked.flat = true
+	forked.write(open)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			forked.write(", ")
+		}
+		item(forked, i)
+	}
+	forked.write(close)
+	if p.flat || p.fits(forked) {
+		p.adopt(forked)
+		return
+	}
+
+	p.write(open)
+	p.indent++
+	for i := 0; i < n; i++ {
+		p.newline()
+		item(p, i)
+		if i < n-1 {
+			p.write(",")
+		}
+	}
+	p.indent--
+	p.newline()
+	p.write(close)
+}
+
+func identifiers(idents []*ast.Identifier) string {
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+func statementPos(stmt ast.Statement) token.Position {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Pos
+	case *ast.ReturnStatement:
+		return stmt.Token.Pos
+	case *ast.ExpressionStatement:
+		return stmt.Token.Pos
+	case *ast.BlockStatement:
+		return stmt.Token.Pos
+	case *ast.ImportStatement:
+		return stmt.Token.Pos
+	case *ast.ExportStatement:
+		return stmt.Token.Pos
+	}
+	return token.Position{}
+}
@@ -0,0 +1,169 @@
+package format
+
+import (
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/parser"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `let add=fn(a,b){a+b}`,
+			expected: "let add = fn(a, b) { a + b };\n",
+		},
+		{
+			input:    "let f = fn(x) {\nlet y = x * 2; y\n}",
+			expected: "let f = fn(x) {\n  let y = x * 2;\n  y;\n};\n",
+		},
+		{
+			input:    `fn() {}; [ ]; { }`,
+			expected: "fn() {};\n[];\n{};\n",
+		},
+		{
+			input:    `if (x) { 1 } else { 2 }; if (!(a)) { b }`,
+			expected: "if (x) { 1 } else { 2 }\nif (!a) { b }\n",
+		},
+		{
+			input:    `(1 + 2) * 3; 1 + (2 + 3); (1 + 2) + 3; 1 - (2 - 3); (1 - 2) - 3; 1 + (2 - 3); -(a + b); (-f)(x); (a + b)[0]`,
+			expected: "(1 + 2) * 3;\n1 + 2 + 3;\n(1 + 2) + 3;\n1 - (2 - 3);\n1 - 2 - 3;\n1 + 2 - 3;\n-(a + b);\n(-f)(x);\n(a + b)[0];\n",
+		},
+		{
+			input:    `a == (b < c); (a == b) == c; a[1:]; a[:-1]; a[:]`,
+			expected: "a == b < c;\na == b == c;\na[1:];\na[:-1];\na[:];\n",
+		},
+		{
+			input:    `import {a,b} from "lib"; import "other"; export let x = 1`,
+			expected: "import { a, b } from \"lib\";\nimport \"other\";\nexport let x = 1;\n",
+		},
+		{
+			input:    "let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;",
+			expected: "let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			input: `let numbers = ["one hundred", "two hundred", "three hundred", "four hundred", "five hundred"];`,
+			expected: `let numbers = [
+  "one hundred",
+  "two hundred",
+  "three hundred",
+  "four hundred",
+  "five hundred"
+];
+`,
+		},
+		{
+			input: `puts(format("%s and %s", "a long argument here", "another long argument"), {"key": "value"});`,
+			expected: `puts(
+  format("%s and %s", "a long argument here", "another long argument"),
+  {"key": "value"}
+);
+`,
+		},
+		{
+			input: `let f = fn(x) { map([1, 2, 3], fn(element) { element * x + element * x + element * x }) };`,
+			expected: `let f = fn(x) {
+  map([1, 2, 3], fn(element) { element * x + element * x + element * x });
+};
+`,
+		},
+		{
+			input:    "let  n :int=1;let f=fn(a:int,b)->{string: [int]}{ {} };",
+			expected: "let n: int = 1;\nlet f = fn(a: int, b) -> {string: [int]} { {} };\n",
+		},
+		{
+			input:    "reduce(arr, 0, fn(acc, x) {\nacc + x\n})",
+			expected: "reduce(arr, 0, fn(acc, x) {\n  acc + x;\n});\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("case: %s. err: %s", tt.input, err)
+		}
+		if string(got) != tt.expected {
+			t.Errorf("case: %s. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+		checkIdempotent(t, got)
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "// header\n\nlet a = 1; // one\n// before b\nlet b = 2;\n// end",
+			expected: "// header\n\nlet a = 1; // one\n// before b\nlet b = 2;\n// end\n",
+		},
+		{
+			input:    "let f = fn(x) { // start\n  // inside\n  x // value\n  // last\n};",
+			expected: "let f = fn(x) {\n  // start\n  // inside\n  x; // value\n  // last\n};\n",
+		},
+		{
+			input:    "let a = [\n  1, // one\n  2\n];",
+			expected: "let a = [1, 2]; // one\n",
+		},
+		{
+			input:    "if (x) {\n  // nothing\n}",
+			expected: "if (x) {\n  // nothing\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Fatalf("case: %s. err: %s", tt.input, err)
+		}
+		if string(got) != tt.expected {
+			t.Errorf("case: %s. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+		checkIdempotent(t, got)
+	}
+}
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "let x = 1; puts(f(x)) // comment\n\n\nputs(format(\"%d\", x))",
+			expected: "let x = 1;\nputs(f(x));\nputs(format(\"%d\", x));\n",
+		},
+		{
+			input:    `let f = fn(a) { if (a) { 1 } else { 2 } };`,
+			expected: "let f = fn(a) {\n  if (a) {\n    1;\n  } else {\n    2;\n  }\n};\n",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		got := Program(program)
+		if string(got) != tt.expected {
+			t.Errorf("case: %s. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+		checkIdempotent(t, got)
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	if _, err := Source([]byte(`let = 1`)); err == nil {
+		t.Errorf("expected parser error")
+	}
+}
+
+func checkIdempotent(t *testing.T, formatted []byte) {
+	t.Helper()
+	again, err := Source(formatted)
+	if err != nil {
+		t.Fatalf("formatted source does not parse: %s\n%s", err, formatted)
+	}
+	if string(again) != string(formatted) {
+		t.Errorf("not idempotent. first=%q, second=%q", formatted, again)
+	}
+}
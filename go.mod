@@ -1,6 +1,6 @@
 module github.com/care0717/monkey-interpreter
 
-go 1.13
+go 1.16
 
 require (
 	github.com/google/go-cmp v0.5.4
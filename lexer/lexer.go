@@ -1,9 +1,14 @@
 package lexer
 
-import "github.com/care0717/monkey-interpreter/token"
+import (
+	"github.com/care0717/monkey-interpreter/token"
+	"strings"
+)
 
 type Lexer interface {
 	NextToken() token.Token
+	// Comments はこれまでに読み飛ばしたコメントを出現順に返す
+	Comments() []token.Comment
 }
 
 type lexer struct {
@@ -11,15 +16,22 @@ type lexer struct {
 	position     int  // 現在の位置
 	readPosition int  // これから読み込む位置
 	ch           byte // 現在検査中の文字
+	line         int  // 現在の行
+	column       int  // 現在の列
+	comments     []token.Comment
 }
 
 func New(input string) Lexer {
-	l := &lexer{input: input}
+	l := &lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -27,6 +39,7 @@ func (l *lexer) readChar() {
 	}
 	l.position = l.readPosition
 	l.readPosition += 1
+	l.column += 1
 }
 
 func (l *lexer) peekChar() byte {
@@ -37,9 +50,10 @@ func (l *lexer) peekChar() byte {
 	}
 }
 
+// readIdentifier は英字か _ で始まり、2文字目以降に数字も含む識別子を読む
 func (l *lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || isDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
@@ -61,10 +75,33 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// skipWhitespace は空白とコメントを読み飛ばす。コメントは整形のために記録しておく
 func (l *lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
+
+// readComment は // から行末までを読む
+func (l *lexer) readComment() {
+	pos := token.Position{Line: l.line, Column: l.column}
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	text := strings.TrimRight(l.input[position:l.position], " \t\r")
+	l.comments = append(l.comments, token.Comment{Pos: pos, Text: text})
+}
+
+func (l *lexer) Comments() []token.Comment {
+	return l.comments
 }
 
 func (l *lexer) readString() string {
@@ -86,6 +123,7 @@ func (l *lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	pos := token.Position{Line: l.line, Column: l.column}
 
 	switch l.ch {
 	case '=':
@@ -110,7 +148,14 @@ func (l *lexer) NextToken() token.Token {
 	case '+':
 		tok = token.NewToken(token.PLUS, l.ch)
 	case '-':
-		tok = token.NewToken(token.MINUS, l.ch)
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.ARROW, Literal: literal}
+		} else {
+			tok = token.NewToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -146,15 +191,18 @@ func (l *lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Literal = l.readNumber()
 			tok.Type = token.INT
+			tok.Pos = pos
 			return tok
 		} else {
 			tok = token.NewToken(token.ILLEGAL, l.ch)
 		}
 	}
+	tok.Pos = pos
 	l.readChar()
 	return tok
 }
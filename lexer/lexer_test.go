@@ -3,6 +3,7 @@ package lexer
 import (
 	"github.com/care0717/monkey-interpreter/token"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"testing"
 )
 
@@ -14,39 +15,66 @@ func TestNextToken(t *testing.T) {
 		{
 			input: `=+(){},;`,
 			expected: []token.Token{
-				{token.ASSIGN, "="},
-				{token.PLUS, "+"},
-				{token.LPAREN, "("},
-				{token.RPAREN, ")"},
-				{token.LBRACE, "{"},
-				{token.RBRACE, "}"},
-				{token.COMMA, ","},
-				{token.SEMICOLON, ";"},
-				{token.EOF, ""},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.PLUS, Literal: "+"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.RBRACE, Literal: "}"},
+				{Type: token.COMMA, Literal: ","},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			input: `fn(a: int) -> bool a-1`,
+			expected: []token.Token{
+				{Type: token.FUNCTION, Literal: "fn"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.IDENT, Literal: "a"},
+				{Type: token.COLON, Literal: ":"},
+				{Type: token.IDENT, Literal: "int"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.ARROW, Literal: "->"},
+				{Type: token.IDENT, Literal: "bool"},
+				{Type: token.IDENT, Literal: "a"},
+				{Type: token.MINUS, Literal: "-"},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
+		{
+			input: `macroexpand_1 x2y 3z`,
+			expected: []token.Token{
+				{Type: token.IDENT, Literal: "macroexpand_1"},
+				{Type: token.IDENT, Literal: "x2y"},
+				{Type: token.INT, Literal: "3"},
+				{Type: token.IDENT, Literal: "z"},
+				{Type: token.EOF, Literal: ""},
 			},
 		},
 		{
 			input: `!-/*5;`,
 			expected: []token.Token{
-				{token.BANG, "!"},
-				{token.MINUS, "-"},
-				{token.SLASH, "/"},
-				{token.ASTERISK, "*"},
-				{token.INT, "5"},
-				{token.SEMICOLON, ";"},
-				{token.EOF, ""},
+				{Type: token.BANG, Literal: "!"},
+				{Type: token.MINUS, Literal: "-"},
+				{Type: token.SLASH, Literal: "/"},
+				{Type: token.ASTERISK, Literal: "*"},
+				{Type: token.INT, Literal: "5"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.EOF, Literal: ""},
 			},
 		},
 		{
 			input: `5 < 10 > 5;`,
 			expected: []token.Token{
-				{token.INT, "5"},
-				{token.LT, "<"},
-				{token.INT, "10"},
-				{token.GT, ">"},
-				{token.INT, "5"},
-				{token.SEMICOLON, ";"},
-				{token.EOF, ""},
+				{Type: token.INT, Literal: "5"},
+				{Type: token.LT, Literal: "<"},
+				{Type: token.INT, Literal: "10"},
+				{Type: token.GT, Literal: ">"},
+				{Type: token.INT, Literal: "5"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.EOF, Literal: ""},
 			},
 		},
 		{
@@ -57,24 +85,24 @@ if (5 < 10) {
   return false;
 }`,
 			expected: []token.Token{
-				{token.IF, "if"},
-				{token.LPAREN, "("},
-				{token.INT, "5"},
-				{token.LT, "<"},
-				{token.INT, "10"},
-				{token.RPAREN, ")"},
-				{token.LBRACE, "{"},
-				{token.RETURN, "return"},
-				{token.TRUE, "true"},
-				{token.SEMICOLON, ";"},
-				{token.RBRACE, "}"},
-				{token.ELSE, "else"},
-				{token.LBRACE, "{"},
-				{token.RETURN, "return"},
-				{token.FALSE, "false"},
-				{token.SEMICOLON, ";"},
-				{token.RBRACE, "}"},
-				{token.EOF, ""},
+				{Type: token.IF, Literal: "if"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.INT, Literal: "5"},
+				{Type: token.LT, Literal: "<"},
+				{Type: token.INT, Literal: "10"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.RETURN, Literal: "return"},
+				{Type: token.TRUE, Literal: "true"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.RBRACE, Literal: "}"},
+				{Type: token.ELSE, Literal: "else"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.RETURN, Literal: "return"},
+				{Type: token.FALSE, Literal: "false"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.RBRACE, Literal: "}"},
+				{Type: token.EOF, Literal: ""},
 			},
 		},
 		{
@@ -82,15 +110,15 @@ if (5 < 10) {
 10 == 10; 
 10 != 9;`,
 			expected: []token.Token{
-				{token.INT, "10"},
-				{token.EQ, "=="},
-				{token.INT, "10"},
-				{token.SEMICOLON, ";"},
-				{token.INT, "10"},
-				{token.NOT_EQ, "!="},
-				{token.INT, "9"},
-				{token.SEMICOLON, ";"},
-				{token.EOF, ""},
+				{Type: token.INT, Literal: "10"},
+				{Type: token.EQ, Literal: "=="},
+				{Type: token.INT, Literal: "10"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.INT, Literal: "10"},
+				{Type: token.NOT_EQ, Literal: "!="},
+				{Type: token.INT, Literal: "9"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.EOF, Literal: ""},
 			},
 		},
 		{
@@ -104,43 +132,43 @@ let add = fn(x, y) {
 let result = add(five, ten);
 `,
 			expected: []token.Token{
-				{token.LET, "let"},
-				{token.IDENT, "five"},
-				{token.ASSIGN, "="},
-				{token.INT, "5"},
-				{token.SEMICOLON, ";"},
-				{token.LET, "let"},
-				{token.IDENT, "ten"},
-				{token.ASSIGN, "="},
-				{token.INT, "10"},
-				{token.SEMICOLON, ";"},
-				{token.LET, "let"},
-				{token.IDENT, "add"},
-				{token.ASSIGN, "="},
-				{token.FUNCTION, "fn"},
-				{token.LPAREN, "("},
-				{token.IDENT, "x"},
-				{token.COMMA, ","},
-				{token.IDENT, "y"},
-				{token.RPAREN, ")"},
-				{token.LBRACE, "{"},
-				{token.IDENT, "x"},
-				{token.PLUS, "+"},
-				{token.IDENT, "y"},
-				{token.SEMICOLON, ";"},
-				{token.RBRACE, "}"},
-				{token.SEMICOLON, ";"},
-				{token.LET, "let"},
-				{token.IDENT, "result"},
-				{token.ASSIGN, "="},
-				{token.IDENT, "add"},
-				{token.LPAREN, "("},
-				{token.IDENT, "five"},
-				{token.COMMA, ","},
-				{token.IDENT, "ten"},
-				{token.RPAREN, ")"},
-				{token.SEMICOLON, ";"},
-				{token.EOF, ""},
+				{Type: token.LET, Literal: "let"},
+				{Type: token.IDENT, Literal: "five"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.INT, Literal: "5"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.LET, Literal: "let"},
+				{Type: token.IDENT, Literal: "ten"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.INT, Literal: "10"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.LET, Literal: "let"},
+				{Type: token.IDENT, Literal: "add"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.FUNCTION, Literal: "fn"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.COMMA, Literal: ","},
+				{Type: token.IDENT, Literal: "y"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.PLUS, Literal: "+"},
+				{Type: token.IDENT, Literal: "y"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.RBRACE, Literal: "}"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.LET, Literal: "let"},
+				{Type: token.IDENT, Literal: "result"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.IDENT, Literal: "add"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.IDENT, Literal: "five"},
+				{Type: token.COMMA, Literal: ","},
+				{Type: token.IDENT, Literal: "ten"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.EOF, Literal: ""},
 			},
 		},
 		{
@@ -203,6 +231,25 @@ let result = add(five, ten);
 				{Type: token.EOF, Literal: ""},
 			},
 		},
+		{
+			input: `import { a } from "lib"; export let b = a;`,
+			expected: []token.Token{
+				{Type: token.IMPORT, Literal: "import"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.IDENT, Literal: "a"},
+				{Type: token.RBRACE, Literal: "}"},
+				{Type: token.IDENT, Literal: "from"},
+				{Type: token.STRING, Literal: "lib"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.EXPORT, Literal: "export"},
+				{Type: token.LET, Literal: "let"},
+				{Type: token.IDENT, Literal: "b"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.IDENT, Literal: "a"},
+				{Type: token.SEMICOLON, Literal: ";"},
+				{Type: token.EOF, Literal: ""},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -210,9 +257,65 @@ let result = add(five, ten);
 		for i, e := range tt.expected {
 			tok := l.NextToken()
 
-			if !cmp.Equal(tok, e) {
+			if !cmp.Equal(tok, e, cmpopts.IgnoreFields(token.Token{}, "Pos")) {
 				t.Errorf("test[%d] = tokentype wrong. expected=%v, got=%v", i, e, tok)
 			}
 		}
 	}
 }
+
+func TestTokenPosition(t *testing.T) {
+	input := `let five = 5;
+  "ten"
+`
+	expected := []token.Position{
+		{Line: 1, Column: 1},
+		{Line: 1, Column: 5},
+		{Line: 1, Column: 10},
+		{Line: 1, Column: 12},
+		{Line: 1, Column: 13},
+		{Line: 2, Column: 3},
+		{Line: 3, Column: 1},
+	}
+
+	l := New(input)
+	for i, e := range expected {
+		tok := l.NextToken()
+
+		if tok.Pos != e {
+			t.Errorf("test[%d] = position wrong. expected=%v, got=%v", i, e, tok.Pos)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// header
+let x = 10 / 2; // half
+// end`
+	expectedTokens := []token.Token{
+		{Type: token.LET, Literal: "let"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.INT, Literal: "10"},
+		{Type: token.SLASH, Literal: "/"},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.EOF, Literal: ""},
+	}
+	expectedComments := []token.Comment{
+		{Pos: token.Position{Line: 1, Column: 1}, Text: "// header"},
+		{Pos: token.Position{Line: 2, Column: 17}, Text: "// half"},
+		{Pos: token.Position{Line: 3, Column: 1}, Text: "// end"},
+	}
+
+	l := New(input)
+	for i, e := range expectedTokens {
+		tok := l.NextToken()
+		if !cmp.Equal(tok, e, cmpopts.IgnoreFields(token.Token{}, "Pos")) {
+			t.Errorf("test[%d] = token wrong. expected=%v, got=%v", i, e, tok)
+		}
+	}
+	if !cmp.Equal(l.Comments(), expectedComments) {
+		t.Errorf("comments wrong. diff %s[-got, +expected]", cmp.Diff(l.Comments(), expectedComments))
+	}
+}
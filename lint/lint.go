@@ -0,0 +1,277 @@
+package lint
+
+import (
+	"fmt"
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/evaluator"
+	"github.com/care0717/monkey-interpreter/resolver"
+	"github.com/care0717/monkey-interpreter/token"
+	"sort"
+	"strings"
+)
+
+// 検査の種類。JSON 出力の rule にそのまま使う
+const (
+	UnusedVariable    = "unused-variable"
+	UnusedParameter   = "unused-parameter"
+	UnusedMacro       = "unused-macro"
+	Shadow            = "shadow"
+	Unreachable       = "unreachable"
+	ConstantCondition = "constant-condition"
+	BuiltinArity      = "builtin-arity"
+)
+
+// Diagnostic は linter が見つけたひとつの問題
+type Diagnostic struct {
+	File string `json:"file,omitempty"`
+	token.Position
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.File == "" {
+		return fmt.Sprintf("%s: %s (%s)", d.Position, d.Message, d.Rule)
+	}
+	return fmt.Sprintf("%s:%s: %s (%s)", d.File, d.Position, d.Message, d.Rule)
+}
+
+type linter struct {
+	analysis    *resolver.Analysis
+	used        map[*resolver.Binding]bool
+	diagnostics []Diagnostic
+}
+
+// Lint は macro の展開前の program を検査する。結果は位置の順に並ぶ
+func Lint(program *ast.Program) []Diagnostic {
+	a := resolver.Analyze(program)
+	l := &linter{analysis: a, used: make(map[*resolver.Binding]bool), diagnostics: []Diagnostic{}}
+
+	for _, ident := range a.Identifiers {
+		if _, ok := a.Definitions[ident]; ok {
+			l.used[a.References[ident].Binding] = true
+		}
+	}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			// export されたものは他のファイルから使われる
+			l.used[a.References[export.Let.Name].Binding] = true
+		}
+	}
+	l.lintStatements(program.Statements, a.Global)
+	l.closeScope(a.Global)
+
+	sort.SliceStable(l.diagnostics, func(i, j int) bool {
+		a, b := l.diagnostics[i].Position, l.diagnostics[j].Position
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return l.diagnostics
+}
+
+func (l *linter) report(pos token.Position, rule string, format string, a ...interface{}) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{Position: pos, Rule: rule, Message: fmt.Sprintf(format, a...)})
+}
+
+// isMacro は b がマクロリテラルを束縛する let で宣言されたかどうかを返す
+func (l *linter) isMacro(b *resolver.Binding) bool {
+	let, ok := l.analysis.Lets[b.Declarations[0]]
+	if !ok {
+		return false
+	}
+	_, ok = let.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+// closeScope は s の束縛が外側の束縛や builtin を隠していれば報告し、使われなかった束縛も報告する。
+// 名前が _ で始まるものは意図的に使わないものとみなす
+func (l *linter) closeScope(s *resolver.Scope) {
+	for _, b := range s.Bindings {
+		ident := b.Declarations[0]
+		if s.Outer != nil && s.Outer.Lookup(b.Name) != nil {
+			l.report(ident.Token.Pos, Shadow, "%s shadows a binding in an outer scope", b.Name)
+		} else if _, ok := evaluator.BuiltinArity(b.Name); ok {
+			l.report(ident.Token.Pos, Shadow, "%s shadows a builtin", b.Name)
+		}
+
+		if l.used[b] || strings.HasPrefix(b.Name, "_") {
+			continue
+		}
+		if _, ok := l.analysis.Params[ident]; ok {
+			l.report(ident.Token.Pos, UnusedParameter, "parameter %s is never used", b.Name)
+		} else if l.isMacro(b) {
+			l.report(ident.Token.Pos, UnusedMacro, "macro %s is never used", b.Name)
+		} else if _, ok := l.analysis.Lets[ident]; ok {
+			l.report(ident.Token.Pos, UnusedVariable, "%s is never used", b.Name)
+		}
+	}
+}
+
+func (l *linter) lintStatements(statements []ast.Statement, s *resolver.Scope) {
+	returned := false
+	for _, stmt := range statements {
+		if returned {
+			l.report(statementPos(stmt), Unreachable, "unreachable code after return")
+			returned = false
+		}
+
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			l.lintExpression(stmt.Value, s)
+		case *ast.ExportStatement:
+			l.lintExpression(stmt.Let.Value, s)
+		case *ast.ReturnStatement:
+			l.lintExpression(stmt.ReturnValue, s)
+			returned = true
+		case *ast.ExpressionStatement:
+			l.lintExpression(stmt.Expression, s)
+		case *ast.BlockStatement:
+			l.lintStatements(stmt.Statements, s)
+		}
+	}
+}
+
+func (l *linter) lintExpression(exp ast.Expression, s *resolver.Scope) {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		l.lintExpression(exp.Right, s)
+	case *ast.InfixExpression:
+		l.lintExpression(exp.Left, s)
+		l.lintExpression(exp.Right, s)
+	case *ast.IfExpression:
+		if truthy, ok := constantTruthiness(exp.Condition); ok {
+			l.report(exp.Token.Pos, ConstantCondition, "condition is always %t", truthy)
+		}
+		l.lintExpression(exp.Condition, s)
+		l.lintStatements(exp.Consequence.Statements, s)
+		if exp.Alternative != nil {
+			l.lintStatements(exp.Alternative.Statements, s)
+		}
+	case *ast.FunctionLiteral:
+		l.lintFunction(exp, exp.Body)
+	case *ast.MacroLiteral:
+		l.lintFunction(exp, exp.Body)
+	case *ast.CallExpression:
+		if exp.Function.TokenLiteral() == "quote" {
+			// quote の中はデータなので、評価される unquote の引数だけを検査する
+			for _, arg := range exp.Arguments {
+				l.lintUnquoteCalls(arg, s)
+			}
+			return
+		}
+		l.checkBuiltinArity(exp)
+		l.lintExpression(exp.Function, s)
+		for _, arg := range exp.Arguments {
+			l.lintExpression(arg, s)
+		}
+	case *ast.ArrayLiteral:
+		for _, e := range exp.Elements {
+			l.lintExpression(e, s)
+		}
+	case *ast.IndexExpression:
+		l.lintExpression(exp.Left, s)
+		l.lintExpression(exp.Index, s)
+	case *ast.SliceExpression:
+		l.lintExpression(exp.Left, s)
+		if exp.Low != nil {
+			l.lintExpression(exp.Low, s)
+		}
+		if exp.High != nil {
+			l.lintExpression(exp.High, s)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			l.lintExpression(pair.Key, s)
+			l.lintExpression(pair.Value, s)
+		}
+	}
+}
+
+func (l *linter) lintFunction(fn ast.Expression, body *ast.BlockStatement) {
+	s := l.analysis.Functions[fn]
+	l.lintStatements(body.Statements, s)
+	l.closeScope(s)
+}
+
+func (l *linter) checkBuiltinArity(call *ast.CallExpression) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok || l.analysis.References[ident].Binding != nil {
+		return
+	}
+	arity, ok := evaluator.BuiltinArity(ident.Value)
+	if !ok || arity.Accepts(len(call.Arguments)) {
+		return
+	}
+
+	var want string
+	switch {
+	case arity.Max < 0:
+		want = fmt.Sprintf("%d or more", arity.Min)
+	case arity.Min == arity.Max:
+		want = fmt.Sprintf("%d", arity.Min)
+	default:
+		want = fmt.Sprintf("%d to %d", arity.Min, arity.Max)
+	}
+	l.report(call.Token.Pos, BuiltinArity, "wrong number of arguments to `%s`. got=%d, want=%s", ident.Value, len(call.Arguments), want)
+}
+
+// lintUnquoteCalls は quote された node の中から unquote と unquote_splice の呼び出しを探して検査する。
+// マクロが quote で組み立てた別のマクロの呼び出しは展開されるので、そのマクロを使ったものとみなす
+func (l *linter) lintUnquoteCalls(node ast.Node, s *resolver.Scope) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		if name := call.Function.TokenLiteral(); name != "unquote" && name != "unquote_splice" {
+			if ident, ok := call.Function.(*ast.Identifier); ok {
+				if b := s.Lookup(ident.Value); b != nil && l.isMacro(b) {
+					l.used[b] = true
+				}
+			}
+			return true
+		}
+		for _, arg := range call.Arguments {
+			l.lintExpression(arg, s)
+		}
+		return false
+	})
+}
+
+// constantTruthiness は exp がリテラルだけでできていれば、その真偽を返す
+func constantTruthiness(exp ast.Expression) (bool, bool) {
+	switch exp := exp.(type) {
+	case *ast.Boolean:
+		return exp.Value, true
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		// 評価器では false と null 以外はすべて真
+		return true, true
+	case *ast.PrefixExpression:
+		if exp.Operator != "!" {
+			return false, false
+		}
+		truthy, ok := constantTruthiness(exp.Right)
+		return !truthy, ok
+	}
+	return false, false
+}
+
+func statementPos(stmt ast.Statement) token.Position {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Pos
+	case *ast.ReturnStatement:
+		return stmt.Token.Pos
+	case *ast.ExpressionStatement:
+		return stmt.Token.Pos
+	case *ast.BlockStatement:
+		return stmt.Token.Pos
+	case *ast.ImportStatement:
+		return stmt.Token.Pos
+	case *ast.ExportStatement:
+		return stmt.Token.Pos
+	}
+	return token.Position{}
+}
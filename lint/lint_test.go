@@ -0,0 +1,126 @@
+package lint
+
+import (
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/parser"
+	"github.com/care0717/monkey-interpreter/token"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	at := func(line, column int, rule, message string) Diagnostic {
+		return Diagnostic{Position: token.Position{Line: line, Column: column}, Rule: rule, Message: message}
+	}
+
+	tests := []struct {
+		input    string
+		expected []Diagnostic
+	}{
+		{
+			input:    `let add = fn(a, b) { a + b }; add(1, 2)`,
+			expected: []Diagnostic{},
+		},
+		{
+			input: `let x = 1;`,
+			expected: []Diagnostic{
+				at(1, 5, UnusedVariable, "x is never used"),
+			},
+		},
+		{
+			input:    `export let x = 1; let _y = 2;`,
+			expected: []Diagnostic{},
+		},
+		{
+			input: `let f = fn(a, b) { a }; f(1, 2)`,
+			expected: []Diagnostic{
+				at(1, 15, UnusedParameter, "parameter b is never used"),
+			},
+		},
+		{
+			input: `let x = 1; let f = fn(x) { x }; f(x)`,
+			expected: []Diagnostic{
+				at(1, 23, Shadow, "x shadows a binding in an outer scope"),
+			},
+		},
+		{
+			input: `let len = fn(x) { x }; len(1)`,
+			expected: []Diagnostic{
+				at(1, 5, Shadow, "len shadows a builtin"),
+			},
+		},
+		{
+			input: `let f = fn() { return 1; 2 }; f()`,
+			expected: []Diagnostic{
+				at(1, 26, Unreachable, "unreachable code after return"),
+			},
+		},
+		{
+			input: `if (!true) { 1 }`,
+			expected: []Diagnostic{
+				at(1, 1, ConstantCondition, "condition is always false"),
+			},
+		},
+		{
+			input: `if (1) { 1 }`,
+			expected: []Diagnostic{
+				at(1, 1, ConstantCondition, "condition is always true"),
+			},
+		},
+		{
+			input: `len(1, 2); sort(); puts()`,
+			expected: []Diagnostic{
+				at(1, 4, BuiltinArity, "wrong number of arguments to `len`. got=2, want=1"),
+				at(1, 16, BuiltinArity, "wrong number of arguments to `sort`. got=0, want=1 to 2"),
+			},
+		},
+		{
+			input: `let first = fn(a, b) { a + b }; first(1, 2)`,
+			expected: []Diagnostic{
+				at(1, 5, Shadow, "first shadows a builtin"),
+			},
+		},
+		{
+			input: `let m = macro(x) { quote(unquote(x)) }; let n = macro(x) { quote(x) }; m(1)`,
+			expected: []Diagnostic{
+				at(1, 45, UnusedMacro, "macro n is never used"),
+				at(1, 55, UnusedParameter, "parameter x is never used"),
+			},
+		},
+		{
+			input:    `let m = macro(xs) { quote(f(unquote_splice(xs))) }; m(1)`,
+			expected: []Diagnostic{},
+		},
+		{
+			input:    `let inc = macro(e) { quote(unquote(e) + 1) }; let twice = macro(e) { quote(inc(inc(unquote(e)))) }; twice(1);`,
+			expected: []Diagnostic{},
+		},
+		{
+			input: `let x = 1; let f = fn() { let x = x + 1; x }; puts(f());`,
+			expected: []Diagnostic{
+				at(1, 31, Shadow, "x shadows a binding in an outer scope"),
+			},
+		},
+		{
+			input:    `let x = 1; let f = fn() { let y = x; let _x = 2; y }; puts(f());`,
+			expected: []Diagnostic{},
+		},
+		{
+			input:    `let f = fn() { let g = fn() { x }; let x = 1; g() }; puts(f());`,
+			expected: []Diagnostic{},
+		},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("case: %s. parser errors: %v", tt.input, p.Errors())
+		}
+
+		got := Lint(program)
+		if !cmp.Equal(got, tt.expected) {
+			t.Errorf("case: %s. diff %s[-got, +expected]", tt.input, cmp.Diff(got, tt.expected))
+		}
+	}
+}
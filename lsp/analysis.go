@@ -0,0 +1,106 @@
+package lsp
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/resolver"
+	"github.com/care0717/monkey-interpreter/token"
+	"github.com/care0717/monkey-interpreter/typecheck"
+)
+
+// analysis はドキュメントの識別子がどの宣言を指すかを調べた結果
+type analysis struct {
+	*resolver.Analysis
+	// types はトップレベルの let で束縛した名前の、推論した型
+	types map[*ast.Identifier]string
+}
+
+func analyze(program *ast.Program) *analysis {
+	return &analysis{Analysis: resolver.Analyze(program), types: make(map[*ast.Identifier]string)}
+}
+
+// inferTypes はトップレベルの let で束縛した名前の型を推論する。構文解析に成功したプログラムにだけ使う
+func (a *analysis) inferTypes(program *ast.Program) {
+	bindings, _ := typecheck.Check(program)
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Let
+		}
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+		for _, b := range bindings {
+			if b.Position == let.Name.Token.Pos {
+				a.types[let.Name] = b.Type
+			}
+		}
+	}
+}
+
+// identifierAt は pos にある識別子を返す
+func (a *analysis) identifierAt(pos token.Position) *ast.Identifier {
+	for _, ident := range a.Identifiers {
+		start := ident.Token.Pos
+		if start.Line == pos.Line && start.Column <= pos.Column && pos.Column < start.Column+len(ident.Value) {
+			return ident
+		}
+	}
+	return nil
+}
+
+// declaration は識別子が宣言ならそれ自身を、参照なら指している宣言を返す
+func (a *analysis) declaration(ident *ast.Identifier) *ast.Identifier {
+	if decl, ok := a.Definitions[ident]; ok {
+		return decl
+	}
+	if _, ok := a.Lets[ident]; ok {
+		return ident
+	}
+	if _, ok := a.Params[ident]; ok {
+		return ident
+	}
+	return nil
+}
+
+// visible は pos から見える宣言を、内側のスコープから順に返す。内側の名前に隠された外側の名前は含めない
+func (a *analysis) visible(pos token.Position, closing map[token.Position]token.Position) []*ast.Identifier {
+	// pos を囲む関数のうち、本体がいちばん後ろで始まるものがいちばん内側にある
+	s := a.Global
+	var start token.Position
+	for fn, scope := range a.Functions {
+		body := functionBody(fn)
+		if body == nil {
+			continue
+		}
+		end, ok := closing[body.Token.Pos]
+		if before(body.Token.Pos, pos) && (!ok || !before(end, pos)) && (s == a.Global || before(start, body.Token.Pos)) {
+			s, start = scope, body.Token.Pos
+		}
+	}
+
+	seen := make(map[string]bool)
+	var decls []*ast.Identifier
+	for ; s != nil; s = s.Outer {
+		for _, b := range s.Bindings {
+			if !seen[b.Name] {
+				seen[b.Name] = true
+				decls = append(decls, b.Declarations[0])
+			}
+		}
+	}
+	return decls
+}
+
+func functionBody(fn ast.Expression) *ast.BlockStatement {
+	switch fn := fn.(type) {
+	case *ast.FunctionLiteral:
+		return fn.Body
+	case *ast.MacroLiteral:
+		return fn.Body
+	}
+	return nil
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
@@ -0,0 +1,125 @@
+package lsp
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/parser"
+	"github.com/care0717/monkey-interpreter/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// document はエディタで開かれているひとつのファイルと、その構文解析の結果
+type document struct {
+	text    string
+	lines   []string
+	program *ast.Program
+	errors  []parser.Error
+	// closing は { の位置から対応する } の位置を引く。関数の本体の範囲を知るのに使う
+	closing map[token.Position]token.Position
+
+	analysis *analysis
+}
+
+func newDocument(text string) *document {
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	d := &document{
+		text:    text,
+		lines:   strings.Split(text, "\n"),
+		program: program,
+		errors:  p.ErrorList(),
+		closing: matchBraces(text),
+	}
+	d.analysis = analyze(program)
+	if len(d.errors) == 0 {
+		d.analysis.inferTypes(program)
+	}
+	return d
+}
+
+func matchBraces(text string) map[token.Position]token.Position {
+	closing := make(map[token.Position]token.Position)
+	var open []token.Position
+	l := lexer.New(text)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LBRACE:
+			open = append(open, tok.Pos)
+		case token.RBRACE:
+			if len(open) > 0 {
+				closing[open[len(open)-1]] = tok.Pos
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return closing
+}
+
+// position は字句解析器の 1 始まりの行とバイトで数えた列を、LSP の位置にする
+func (d *document) position(pos token.Position) Position {
+	if !pos.IsValid() {
+		return Position{}
+	}
+	line := pos.Line - 1
+	if line >= len(d.lines) {
+		return Position{Line: line}
+	}
+	return Position{Line: line, Character: utf16Len(prefix(d.lines[line], pos.Column-1))}
+}
+
+// tokenPosition は LSP の位置を、字句解析器の位置にする
+func (d *document) tokenPosition(pos Position) token.Position {
+	if pos.Line >= len(d.lines) {
+		return token.Position{Line: pos.Line + 1, Column: pos.Character + 1}
+	}
+	text := d.lines[pos.Line]
+	column, units := 0, 0
+	for _, r := range text {
+		if units >= pos.Character {
+			break
+		}
+		units += utf16RuneLen(r)
+		column += utf8.RuneLen(r)
+	}
+	return token.Position{Line: pos.Line + 1, Column: column + 1}
+}
+
+// identifierRange は識別子が占める範囲
+func (d *document) identifierRange(ident *ast.Identifier) Range {
+	start := ident.Token.Pos
+	end := token.Position{Line: start.Line, Column: start.Column + len(ident.Value)}
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+// wholeRange はドキュメント全体の範囲
+func (d *document) wholeRange() Range {
+	last := len(d.lines) - 1
+	return Range{End: Position{Line: last, Character: utf16Len(d.lines[last])}}
+}
+
+func prefix(s string, n int) string {
+	if n < 0 {
+		return ""
+	}
+	if n > len(s) {
+		return s
+	}
+	return s[:n]
+}
+
+// utf16Len は LSP が位置を数える UTF-16 の符号単位での s の長さ
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
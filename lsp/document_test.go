@@ -0,0 +1,31 @@
+package lsp
+
+import (
+	"github.com/care0717/monkey-interpreter/token"
+	"testing"
+)
+
+func TestPositionConversion(t *testing.T) {
+	d := newDocument("let s = \"é😀\"; s\nlet x = 1;")
+	tests := []struct {
+		pos      token.Position
+		expected Position
+	}{
+		{token.Position{Line: 1, Column: 1}, Position{Line: 0, Character: 0}},
+		{token.Position{Line: 1, Column: 9}, Position{Line: 0, Character: 8}},
+		// é は 2 バイトで 1 符号単位、😀 は 4 バイトで 2 符号単位
+		{token.Position{Line: 1, Column: 18}, Position{Line: 0, Character: 14}},
+		{token.Position{Line: 2, Column: 5}, Position{Line: 1, Character: 4}},
+	}
+
+	for _, tt := range tests {
+		got := d.position(tt.pos)
+		if got != tt.expected {
+			t.Errorf("position(%v): expected=%v, got=%v", tt.pos, tt.expected, got)
+		}
+		back := d.tokenPosition(got)
+		if back != tt.pos {
+			t.Errorf("tokenPosition(%v): expected=%v, got=%v", got, tt.pos, back)
+		}
+	}
+}
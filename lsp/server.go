@@ -0,0 +1,321 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/format"
+	"github.com/care0717/monkey-interpreter/token"
+	"github.com/care0717/monkey-interpreter/typecheck"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Server は Language Server Protocol でエディタと話す。
+// 構文解析のエラーの報告、補完、定義への移動、関数のシグネチャのホバー表示、整形に対応する
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	documents map[string]*document
+	shutdown  bool
+}
+
+// NewServer は in からリクエストを読み、out に応答を書くサーバーを作る
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: make(map[string]*document),
+	}
+}
+
+// errNoShutdown は shutdown を受け取る前に exit や入力の終わりが来たことを表す
+var errNoShutdown = errors.New("exit before shutdown")
+
+// Serve は exit 通知を受け取るまでメッセージを処理する。
+// shutdown を受け取らずに終わったときはエラーを返すので、呼び出し側は終了コードを 1 にする
+func (s *Server) Serve() error {
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			if s.shutdown {
+				return nil
+			}
+			return errNoShutdown
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return nil
+			}
+			return errNoShutdown
+		}
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+// read は Content-Length のヘッダーのついたメッセージをひとつ読む
+func (s *Server) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (s *Server) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *Server) reply(id json.RawMessage, result interface{}, rerr *responseError) error {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	if rerr != nil {
+		return s.write(&errorResponse{JSONRPC: "2.0", ID: id, Error: rerr})
+	}
+	return s.write(&response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// handle はひとつのメッセージを処理する。返すエラーは書き込みの失敗だけで、
+// リクエストの誤りはエラーの応答として返す
+func (s *Server) handle(req *request) error {
+	var result interface{}
+	var rerr *responseError
+	switch req.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				// ドキュメントは変更のたびに全体を受け取る
+				"textDocumentSync":           1,
+				"completionProvider":         map[string]interface{}{},
+				"definitionProvider":         true,
+				"hoverProvider":              true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "monkey"},
+		}
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil
+		}
+		return s.open(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params.ContentChanges) == 0 {
+			return nil
+		}
+		return s.open(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/completion":
+		result, rerr = s.positionRequest(req.Params, s.completion)
+	case "textDocument/definition":
+		result, rerr = s.positionRequest(req.Params, s.definition)
+	case "textDocument/hover":
+		result, rerr = s.positionRequest(req.Params, s.hover)
+	case "textDocument/formatting":
+		result, rerr = s.formatting(req.Params)
+	default:
+		rerr = &responseError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+
+	// 通知には応答しない
+	if req.isNotification() {
+		return nil
+	}
+	return s.reply(req.ID, result, rerr)
+}
+
+// open はドキュメントを構文解析し直し、エラーを報告する
+func (s *Server) open(uri, text string) error {
+	doc := newDocument(text)
+	s.documents[uri] = doc
+
+	diagnostics := []Diagnostic{}
+	for _, e := range doc.errors {
+		start := doc.position(e.Pos)
+		end := Position{Line: start.Line, Character: start.Character + 1}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{Start: start, End: end},
+			Severity: severityError,
+			Source:   "monkey",
+			Message:  e.Message,
+		})
+	}
+	return s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+func (s *Server) positionRequest(raw json.RawMessage, f func(string, *document, token.Position) interface{}) (interface{}, *responseError) {
+	var params textDocumentPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "unknown document: " + params.TextDocument.URI}
+	}
+	return f(params.TextDocument.URI, doc, doc.tokenPosition(params.Position)), nil
+}
+
+var keywords = []string{"fn", "let", "true", "false", "if", "else", "return", "macro", "import", "export"}
+
+// completion はカーソルから見える束縛、キーワード、組み込み関数を候補にする。絞り込みはエディタに任せる
+func (s *Server) completion(uri string, doc *document, pos token.Position) interface{} {
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	for _, decl := range doc.analysis.visible(pos, doc.closing) {
+		seen[decl.Value] = true
+		item := CompletionItem{Label: decl.Value, Kind: completionVariable, Detail: doc.analysis.types[decl]}
+		if let, ok := doc.analysis.Lets[decl]; ok {
+			if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+				item.Kind = completionFunction
+			}
+		}
+		items = append(items, item)
+	}
+	for _, keyword := range keywords {
+		items = append(items, CompletionItem{Label: keyword, Kind: completionKeyword})
+	}
+	for _, name := range typecheck.BuiltinNames() {
+		if seen[name] {
+			continue
+		}
+		detail, _ := typecheck.BuiltinType(name)
+		items = append(items, CompletionItem{Label: name, Kind: completionFunction, Detail: detail})
+	}
+	return items
+}
+
+// definition は let で束縛した名前や引数の、宣言の位置を返す
+func (s *Server) definition(uri string, doc *document, pos token.Position) interface{} {
+	ident := doc.analysis.identifierAt(pos)
+	if ident == nil {
+		return nil
+	}
+	decl := doc.analysis.declaration(ident)
+	if decl == nil {
+		return nil
+	}
+	return &Location{URI: uri, Range: doc.identifierRange(decl)}
+}
+
+// hover は関数ならシグネチャを、そうでなければ名前の宣言を示す。推論した型が分かればそれも示す
+func (s *Server) hover(uri string, doc *document, pos token.Position) interface{} {
+	ident := doc.analysis.identifierAt(pos)
+	if ident == nil {
+		return nil
+	}
+
+	var lines []string
+	if decl := doc.analysis.declaration(ident); decl != nil {
+		lines = append(lines, doc.analysis.describe(decl))
+		if t, ok := doc.analysis.types[decl]; ok {
+			lines = append(lines, decl.Value+": "+t)
+		}
+	} else if t, ok := typecheck.BuiltinType(ident.Value); ok {
+		lines = append(lines, "builtin "+ident.Value+": "+t)
+	} else {
+		return nil
+	}
+
+	r := doc.identifierRange(ident)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + strings.Join(lines, "\n") + "\n```"},
+		Range:    &r,
+	}
+}
+
+// describe は宣言を、関数ならシグネチャの形で、そうでなければ注釈つきの宣言の形で表す
+func (a *analysis) describe(decl *ast.Identifier) string {
+	if let, ok := a.Lets[decl]; ok {
+		if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+			return signature(decl.Value, fn)
+		}
+		if let.Type != nil {
+			return "let " + decl.Value + ": " + let.Type.String()
+		}
+		return "let " + decl.Value
+	}
+
+	param := a.Params[decl]
+	desc := "(parameter) " + decl.Value
+	if fn, ok := param.Function.(*ast.FunctionLiteral); ok && param.Index < len(fn.ParameterTypes) && fn.ParameterTypes[param.Index] != nil {
+		desc += ": " + fn.ParameterTypes[param.Index].String()
+	}
+	return desc
+}
+
+func signature(name string, fn *ast.FunctionLiteral) string {
+	sig := name + "(" + ast.Parameters(fn.Parameters, fn.ParameterTypes) + ")"
+	if fn.ReturnType != nil {
+		sig += " -> " + fn.ReturnType.String()
+	}
+	return sig
+}
+
+// formatting はドキュメント全体を整形した結果で置き換える編集を返す
+func (s *Server) formatting(raw json.RawMessage) (interface{}, *responseError) {
+	var params documentFormattingParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "unknown document: " + params.TextDocument.URI}
+	}
+
+	formatted, err := format.Source([]byte(doc.text))
+	if err != nil {
+		return nil, &responseError{Code: codeRequestFailed, Message: err.Error()}
+	}
+	if string(formatted) == doc.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: doc.wholeRange(), NewText: string(formatted)}}, nil
+}
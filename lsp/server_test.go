@@ -0,0 +1,378 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"io"
+	"net/textproto"
+	"strconv"
+	"testing"
+)
+
+const testURI = "file:///test.mk"
+
+const testSource = `let add = fn(a: int, b) -> int {
+  a + b
+};
+let n: int = add(1, 2);
+let twice = fn(f) { fn(x) { f(f(x)) } };
+len(n)
+`
+
+// session は開いたドキュメントに requests を送り、id ごとの応答の本文と result と、届いた通知を返す
+type session struct {
+	bodies        map[int]string
+	results       map[int]json.RawMessage
+	errors        map[int]*responseError
+	notifications []notification
+}
+
+func runSession(t *testing.T, messages ...interface{}) *session {
+	t.Helper()
+	var in bytes.Buffer
+	for _, msg := range messages {
+		body, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Serve(); err != nil {
+		t.Fatalf("serve: %s", err)
+	}
+
+	s := &session{bodies: make(map[int]string), results: make(map[int]json.RawMessage), errors: make(map[int]*responseError)}
+	r := bufio.NewReader(&out)
+	for {
+		header, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err == io.EOF {
+			return s
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			t.Fatal(err)
+		}
+
+		var msg struct {
+			ID     *int            `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+			Error  *responseError  `json:"error"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.ID == nil {
+			s.notifications = append(s.notifications, notification{Method: msg.Method, Params: msg.Params})
+			continue
+		}
+		s.bodies[*msg.ID] = string(body)
+		s.results[*msg.ID] = msg.Result
+		s.errors[*msg.ID] = msg.Error
+	}
+}
+
+func call(id int, method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notify(method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func didOpen(text string) map[string]interface{} {
+	return notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": testURI, "languageId": "monkey", "version": 1, "text": text},
+	})
+}
+
+func at(id int, method string, line, character int) map[string]interface{} {
+	return call(id, method, map[string]interface{}{
+		"textDocument": map[string]string{"uri": testURI},
+		"position":     Position{Line: line, Character: character},
+	})
+}
+
+var shutdownAndExit = []interface{}{call(999, "shutdown", nil), notify("exit", nil)}
+
+func withShutdown(messages ...interface{}) []interface{} {
+	return append(messages, shutdownAndExit...)
+}
+
+func TestServeLifecycle(t *testing.T) {
+	s := runSession(t, withShutdown(call(1, "initialize", map[string]interface{}{}), notify("initialized", nil), call(2, "unknown/method", nil))...)
+
+	var init struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	if err := json.Unmarshal(s.results[1], &init); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"textDocumentSync", "completionProvider", "definitionProvider", "hoverProvider", "documentFormattingProvider"} {
+		if _, ok := init.Capabilities[name]; !ok {
+			t.Errorf("missing capability %s", name)
+		}
+	}
+	if s.errors[2] == nil || s.errors[2].Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got %v", s.errors[2])
+	}
+	if string(s.results[999]) != "null" {
+		t.Errorf("shutdown result wrong: %s", s.results[999])
+	}
+}
+
+func TestServeReplies(t *testing.T) {
+	s := runSession(t, withShutdown(call(1, "unknown/method", nil))...)
+
+	tests := []struct {
+		id       int
+		expected string
+	}{
+		// 失敗した呼び出しには result を送らない
+		{1, `{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found: unknown/method"}}`},
+		// 結果のない呼び出しにも result は送る
+		{999, `{"jsonrpc":"2.0","id":999,"result":null}`},
+	}
+	for _, tt := range tests {
+		if s.bodies[tt.id] != tt.expected {
+			t.Errorf("reply %d: expected=%s, got=%s", tt.id, tt.expected, s.bodies[tt.id])
+		}
+	}
+}
+
+func TestServeExitWithoutShutdown(t *testing.T) {
+	body := `{"jsonrpc": "2.0", "method": "exit"}`
+	in := bytes.NewBufferString(fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body))
+	if err := NewServer(in, io.Discard).Serve(); err != errNoShutdown {
+		t.Errorf("expected %v, got %v", errNoShutdown, err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	s := runSession(t, withShutdown(
+		didOpen("let a = 1;\nlet x = ;"),
+		notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": testURI, "version": 2},
+			"contentChanges": []map[string]string{{"text": "let x = 1;"}},
+		}),
+	)...)
+
+	if len(s.notifications) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(s.notifications))
+	}
+	var tests = []struct {
+		expected []Diagnostic
+	}{
+		{[]Diagnostic{{
+			Range:    Range{Start: Position{Line: 1, Character: 8}, End: Position{Line: 1, Character: 9}},
+			Severity: severityError,
+			Source:   "monkey",
+			Message:  "no prefix parse function for ; found",
+		}}},
+		{[]Diagnostic{}},
+	}
+	for i, tt := range tests {
+		n := s.notifications[i]
+		if n.Method != "textDocument/publishDiagnostics" {
+			t.Errorf("notification %d: wrong method %s", i, n.Method)
+		}
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(n.Params.(json.RawMessage), &params); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.expected, params.Diagnostics); diff != "" {
+			t.Errorf("notification %d: diagnostics mismatch (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	rangeOf := func(line, start, end int) *Location {
+		return &Location{URI: testURI, Range: Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}}
+	}
+	tests := []struct {
+		line, character int
+		expected        *Location
+	}{
+		// 引数の参照
+		{1, 2, rangeOf(0, 13, 14)},
+		{1, 6, rangeOf(0, 21, 22)},
+		// let の参照と、let の名前そのもの
+		{3, 13, rangeOf(0, 4, 7)},
+		{5, 4, rangeOf(3, 4, 5)},
+		{0, 5, rangeOf(0, 4, 7)},
+		// 外側の関数の引数
+		{4, 30, rangeOf(4, 15, 16)},
+		// 組み込み関数と識別子でない位置
+		{5, 1, nil},
+		{1, 4, nil},
+	}
+
+	var messages []interface{}
+	messages = append(messages, didOpen(testSource))
+	for i, tt := range tests {
+		messages = append(messages, at(i, "textDocument/definition", tt.line, tt.character))
+	}
+	s := runSession(t, withShutdown(messages...)...)
+
+	for i, tt := range tests {
+		var got *Location
+		if err := json.Unmarshal(s.results[i], &got); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.expected, got); diff != "" {
+			t.Errorf("%d:%d: definition mismatch (-want +got):\n%s", tt.line, tt.character, diff)
+		}
+	}
+}
+
+func TestDefinitionBeforeLet(t *testing.T) {
+	s := runSession(t, withShutdown(
+		didOpen("let x = 1;\nlet f = fn() { let x = x + 1; x };"),
+		at(1, "textDocument/definition", 1, 23),
+		at(2, "textDocument/definition", 1, 30),
+	)...)
+
+	tests := []struct {
+		id       int
+		expected Range
+	}{
+		// let の右辺の x は外側の x を指す
+		{1, Range{Start: Position{Line: 0, Character: 4}, End: Position{Line: 0, Character: 5}}},
+		{2, Range{Start: Position{Line: 1, Character: 19}, End: Position{Line: 1, Character: 20}}},
+	}
+	for _, tt := range tests {
+		var got *Location
+		if err := json.Unmarshal(s.results[tt.id], &got); err != nil {
+			t.Fatal(err)
+		}
+		if got == nil || got.Range != tt.expected {
+			t.Errorf("request %d: expected=%v, got=%v", tt.id, tt.expected, got)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{3, 14, "add(a: int, b) -> int\nadd: fn(int, int) -> int"},
+		{0, 5, "add(a: int, b) -> int\nadd: fn(int, int) -> int"},
+		{5, 4, "let n: int\nn: int"},
+		{1, 2, "(parameter) a: int"},
+		{1, 6, "(parameter) b"},
+		{4, 6, "twice(f)\ntwice: fn(fn(a) -> a) -> fn(a) -> a"},
+		{5, 1, "builtin len: fn([a] | string | {b: c}) -> int"},
+		{1, 4, ""},
+	}
+
+	var messages []interface{}
+	messages = append(messages, didOpen(testSource))
+	for i, tt := range tests {
+		messages = append(messages, at(i, "textDocument/hover", tt.line, tt.character))
+	}
+	s := runSession(t, withShutdown(messages...)...)
+
+	for i, tt := range tests {
+		var got *Hover
+		if err := json.Unmarshal(s.results[i], &got); err != nil {
+			t.Fatal(err)
+		}
+		if tt.expected == "" {
+			if got != nil {
+				t.Errorf("%d:%d: expected no hover, got %v", tt.line, tt.character, got)
+			}
+			continue
+		}
+		expected := "```monkey\n" + tt.expected + "\n```"
+		if got == nil || got.Contents.Value != expected {
+			t.Errorf("%d:%d: expected=%q, got=%v", tt.line, tt.character, expected, got)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	tests := []struct {
+		line, character int
+		included        []string
+		excluded        []string
+	}{
+		// 関数の中では引数も見える
+		{1, 2, []string{"a", "b", "add", "n", "twice", "let", "fn", "map", "len"}, []string{"f", "x"}},
+		{4, 30, []string{"x", "f", "twice"}, []string{"a", "b"}},
+		{5, 0, []string{"add", "n", "twice", "if"}, []string{"a", "f", "x"}},
+	}
+
+	var messages []interface{}
+	messages = append(messages, didOpen(testSource))
+	for i, tt := range tests {
+		messages = append(messages, at(i, "textDocument/completion", tt.line, tt.character))
+	}
+	s := runSession(t, withShutdown(messages...)...)
+
+	for i, tt := range tests {
+		var items []CompletionItem
+		if err := json.Unmarshal(s.results[i], &items); err != nil {
+			t.Fatal(err)
+		}
+		labels := make(map[string]CompletionItem)
+		for _, item := range items {
+			labels[item.Label] = item
+		}
+		for _, name := range tt.included {
+			if _, ok := labels[name]; !ok {
+				t.Errorf("%d:%d: expected %s in completion", tt.line, tt.character, name)
+			}
+		}
+		for _, name := range tt.excluded {
+			if _, ok := labels[name]; ok {
+				t.Errorf("%d:%d: unexpected %s in completion", tt.line, tt.character, name)
+			}
+		}
+	}
+
+	var items []CompletionItem
+	json.Unmarshal(s.results[2], &items)
+	for _, item := range items {
+		if item.Label == "add" && (item.Kind != completionFunction || item.Detail != "fn(int, int) -> int") {
+			t.Errorf("wrong completion for add: %+v", item)
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	formatting := call(1, "textDocument/formatting", map[string]interface{}{
+		"textDocument": map[string]string{"uri": testURI},
+		"options":      map[string]interface{}{"tabSize": 2, "insertSpaces": true},
+	})
+
+	s := runSession(t, withShutdown(didOpen("let  x=1\nx"), formatting)...)
+	var edits []TextEdit
+	if err := json.Unmarshal(s.results[1], &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{{
+		Range:   Range{End: Position{Line: 1, Character: 1}},
+		NewText: "let x = 1;\nx;\n",
+	}}
+	if diff := cmp.Diff(expected, edits); diff != "" {
+		t.Errorf("edits mismatch (-want +got):\n%s", diff)
+	}
+
+	// 構文解析に失敗したら整形しない
+	s = runSession(t, withShutdown(didOpen("let = 1"), formatting)...)
+	if s.errors[1] == nil || s.errors[1].Code != codeRequestFailed {
+		t.Errorf("expected request failed, got %v", s.errors[1])
+	}
+}
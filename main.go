@@ -1,13 +1,49 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/evaluator"
+	"github.com/care0717/monkey-interpreter/format"
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/lint"
+	"github.com/care0717/monkey-interpreter/lsp"
+	"github.com/care0717/monkey-interpreter/object"
+	"github.com/care0717/monkey-interpreter/optimizer"
+	"github.com/care0717/monkey-interpreter/parser"
 	"github.com/care0717/monkey-interpreter/repl"
+	"github.com/care0717/monkey-interpreter/typecheck"
+	"io/ioutil"
 	"os"
 	user2 "os/user"
 )
 
+const usage = `usage:
+	monkey [-no-prelude]              start the REPL
+	monkey run [-no-prelude] [-optimize] [-strict] <file>
+	                                  run a script
+	monkey lint [-json] <file>...     report suspicious code
+	monkey fmt [-w] <file>...         format source code
+	monkey check [-types] [-no-prelude] <file>...
+	                                  report type errors without running
+	monkey lsp                        start a language server on stdio
+	monkey parse [-json] [-expand] [-optimize] [-no-prelude] <file>
+	                                  print the syntax tree
+`
+
 func main() {
+	flags := flag.NewFlagSet("monkey", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	noPrelude := flags.Bool("no-prelude", false, "do not load the standard library")
+	flags.Parse(os.Args[1:])
+
+	if flags.NArg() > 0 {
+		os.Exit(runCommand(flags.Arg(0), flags.Args()[1:]))
+	}
+
 	user, err := user2.Current()
 	if err != nil {
 		panic(err)
@@ -15,5 +51,298 @@ func main() {
 
 	fmt.Printf("Hello %s! This is the Monkey programing language!\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.Start(os.Stdin, os.Stdout, repl.Options{NoPrelude: *noPrelude})
+}
+
+func runCommand(name string, args []string) int {
+	switch name {
+	case "run":
+		return run(args)
+	case "lint":
+		return lintFiles(args)
+	case "fmt":
+		return formatFiles(args)
+	case "parse":
+		return parse(args)
+	case "check":
+		return check(args)
+	case "lsp":
+		return serveLSP(args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+}
+
+func run(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	noPrelude := flags.Bool("no-prelude", false, "do not load the standard library")
+	optimize := flags.Bool("optimize", false, "optimize the script and its modules before running")
+	strict := flags.Bool("strict", false, "check values against type annotations at run time")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	path := flags.Arg(0)
+
+	interpreter := evaluator.New(os.Stdin, os.Stdout)
+	interpreter.SearchPath = evaluator.SearchPathFromEnv()
+	interpreter.Optimize = *optimize
+	interpreter.Strict = *strict
+	if !*noPrelude {
+		if err := interpreter.LoadPrelude(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	evaluated := interpreter.EvalFile(path, interpreter.NewEnvironment())
+	if err, ok := evaluated.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Inspect())
+		return 1
+	}
+	return 0
+}
+
+// lintFiles は問題が見つかれば 1 を返す。-json を指定すると CI で読みやすいよう JSON の配列を出力する
+func lintFiles(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	asJSON := flags.Bool("json", false, "print diagnostics as JSON")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	diagnostics := []lint.Diagnostic{}
+	for _, path := range flags.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			for _, msg := range p.Errors() {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+			}
+			status = 1
+			continue
+		}
+
+		for _, d := range lint.Lint(program) {
+			d.File = path
+			diagnostics = append(diagnostics, d)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diagnostics); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	} else {
+		for _, d := range diagnostics {
+			fmt.Println(d)
+		}
+	}
+
+	if len(diagnostics) != 0 {
+		status = 1
+	}
+	return status
+}
+
+// formatFiles は整形結果を標準出力に書く。-w を指定するとファイルを書き換える
+func formatFiles(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	write := flags.Bool("w", false, "write result to the source file instead of stdout")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		formatted, err := format.Source(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			status = 1
+			continue
+		}
+
+		if !*write {
+			os.Stdout.Write(formatted)
+			continue
+		}
+		if err := ioutil.WriteFile(path, formatted, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// parse は構文木を出力する。-expand を指定するとマクロを展開した後の構文木を、
+// -optimize を指定するとさらに最適化した後の構文木を出力する
+func parse(args []string) int {
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	asJSON := flags.Bool("json", false, "print the syntax tree as JSON")
+	expand := flags.Bool("expand", false, "expand macros before printing")
+	optimize := flags.Bool("optimize", false, "expand macros and optimize before printing")
+	noPrelude := flags.Bool("no-prelude", false, "do not load the macros of the standard library")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	path := flags.Arg(0)
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	p := parser.New(lexer.New(string(src)))
+	var program ast.Node = p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+		}
+		return 1
+	}
+
+	if *expand || *optimize {
+		interpreter := evaluator.New(os.Stdin, os.Stdout)
+		interpreter.SearchPath = evaluator.SearchPathFromEnv()
+		if !*noPrelude {
+			if err := interpreter.LoadPrelude(); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+		expanded, errors := interpreter.ExpandFile(path, program.(*ast.Program))
+		if len(errors) != 0 {
+			for _, msg := range errors {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+			}
+			return 1
+		}
+		program = expanded
+	}
+	if *optimize {
+		program = optimizer.Optimize(program.(*ast.Program))
+	}
+
+	if !*asJSON {
+		// 展開や最適化の後の木には元のソースがないので、整形して読める形にする
+		os.Stdout.Write(format.Program(program.(*ast.Program)))
+		return 0
+	}
+	data, err := ast.MarshalNode(program)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var out bytes.Buffer
+	json.Indent(&out, data, "", "  ")
+	fmt.Println(out.String())
+	return 0
+}
+
+// check はマクロを展開した後のプログラムの型を検査し、誤りが見つかれば 1 を返す。
+// -types を指定するとトップレベルの let で束縛した名前の型も出力する
+func check(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	types := flags.Bool("types", false, "print the inferred types of top-level bindings")
+	noPrelude := flags.Bool("no-prelude", false, "do not load the macros of the standard library")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	interpreter := evaluator.New(os.Stdin, os.Stdout)
+	interpreter.SearchPath = evaluator.SearchPathFromEnv()
+	if !*noPrelude {
+		if err := interpreter.LoadPrelude(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			for _, msg := range p.Errors() {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+			}
+			status = 1
+			continue
+		}
+		// マクロはファイルごとに展開し、他のファイルで定義したマクロは使わない
+		expanded, errors := interpreter.ExpandFile(path, program)
+		if len(errors) != 0 {
+			for _, msg := range errors {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", path, msg)
+			}
+			status = 1
+			continue
+		}
+
+		bindings, typeErrors := typecheck.Check(expanded)
+		if *types {
+			for _, b := range bindings {
+				fmt.Println(b)
+			}
+		}
+		for _, e := range typeErrors {
+			e.File = path
+			fmt.Println(e)
+			status = 1
+		}
+	}
+	return status
+}
+
+// serveLSP は標準入出力でエディタと Language Server Protocol で話す。ログは標準エラー出力に書く
+func serveLSP(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
 }
@@ -3,6 +3,9 @@ package object
 type Environment interface {
 	Get(name string) (Object, bool)
 	Set(name string, val Object) Object
+	// GetAt は depth 個外側の環境の slot 番目の値を返す
+	GetAt(depth, slot int) (Object, bool)
+	SetAt(slot int, val Object) Object
 }
 
 func NewEnclosedEnvironment(outer Environment) Environment {
@@ -22,6 +25,7 @@ func newEnvironment() *environment {
 
 type environment struct {
 	Store map[string]Object
+	Slots []Object
 	Outer Environment
 }
 
@@ -37,3 +41,26 @@ func (e *environment) Set(name string, val Object) Object {
 	e.Store[name] = val
 	return val
 }
+
+func (e *environment) GetAt(depth, slot int) (Object, bool) {
+	if depth > 0 {
+		if e.Outer == nil {
+			return nil, false
+		}
+		return e.Outer.GetAt(depth-1, slot)
+	}
+	if slot >= len(e.Slots) || e.Slots[slot] == nil {
+		return nil, false
+	}
+	return e.Slots[slot], true
+}
+
+func (e *environment) SetAt(slot int, val Object) Object {
+	if slot >= len(e.Slots) {
+		slots := make([]Object, slot+1)
+		copy(slots, e.Slots)
+		e.Slots = slots
+	}
+	e.Slots[slot] = val
+	return val
+}
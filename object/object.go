@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/token"
 	"hash/fnv"
+	"io"
 	"strings"
 )
 
@@ -79,15 +81,25 @@ func (r ReturnValue) Inspect() string { return r.Value.Inspect() }
 
 type Error struct {
 	Message string
+	// Pos はエラーが起きた位置。わからない場合はゼロ値
+	Pos token.Position
 }
 
-func (e Error) Type() Type      { return ERROR_OBJ }
-func (e Error) Inspect() string { return "ERROR: " + e.Message }
+func (e Error) Type() Type { return ERROR_OBJ }
+func (e Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
 
 type Function struct {
 	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        Environment
+	// ParameterTypes と ReturnType は関数リテラルの型の注釈。strict モードで呼び出しのときに確かめる
+	ParameterTypes []ast.TypeExpression
+	ReturnType     ast.TypeExpression
+	Body           *ast.BlockStatement
+	Env            Environment
 }
 
 func (f Function) Type() Type { return FUNCTION_OBJ }
@@ -95,21 +107,37 @@ func (f Function) Type() Type { return FUNCTION_OBJ }
 func (f Function) Inspect() string {
 	var out bytes.Buffer
 
-	var params []string
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n")
+	out.WriteString(ast.Parameters(f.Parameters, f.ParameterTypes))
+	out.WriteString(") ")
+	if f.ReturnType != nil {
+		out.WriteString("-> " + f.ReturnType.String() + " ")
+	}
+	out.WriteString("{\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
 
-type BuiltinFunction func(args ...Object) Object
+// CallContext は builtin 関数から呼び出し元のインタプリタを扱うためのもの
+type CallContext interface {
+	// Apply は fn を args で呼び出す。fn には Function と Builtin のどちらも渡せる
+	Apply(fn Object, args ...Object) Object
+	// Errorf は呼び出し位置のついたエラーを作る
+	Errorf(format string, a ...interface{}) *Error
+	// Gensym は prefix で始まり、ユーザーのコードの識別子とは衝突しない新しい名前を返す
+	Gensym(prefix string) string
+	// MacroExpand は node の複製の中のマクロ呼び出しを展開する。once なら一段だけ展開する
+	MacroExpand(node ast.Node, once bool) (ast.Node, []string)
+	// Pos は builtin を呼び出した式の位置
+	Pos() token.Position
+	In() io.Reader
+	Out() io.Writer
+}
+
+type BuiltinFunction func(ctx CallContext, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
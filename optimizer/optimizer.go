@@ -0,0 +1,371 @@
+package optimizer
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/token"
+	"strconv"
+)
+
+// constants は関数ひとつ分、またはプログラム全体で、参照を値に置き換えられる let の値。
+// 置き換えるのはプログラムの中で一度だけ束縛される名前なので、名前だけで引いてよい
+type constants struct {
+	values map[string]ast.Expression
+	outer  *constants
+}
+
+func (c *constants) lookup(name string) (ast.Expression, bool) {
+	for ; c != nil; c = c.outer {
+		if value, ok := c.values[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// optimizer はひとつのプログラムを最適化する間の状態を持つ
+type optimizer struct {
+	// bindings は名前ごとの、プログラムの中で束縛された回数。一度だけ束縛された名前の参照だけを置き換える
+	bindings map[string]int
+	// inline は let の値を参照に埋め込んでよいかどうか。名前を列挙しない import があると、どの名前が上書きされるか分からない
+	inline bool
+}
+
+// Optimize はマクロを展開した後の program の複製を最適化して返す。program は書き換えない。
+// リテラルだけの算術・文字列の連結・真偽値の演算を畳み込み、条件が定数の if の使われない分岐を取り除き、
+// リテラルで初期化された let の後の参照をその値に置き換える。評価の結果は変わらない。
+// 識別子の解決より前に使う
+func Optimize(program *ast.Program) *ast.Program {
+	program = ast.Copy(program).(*ast.Program)
+
+	o := &optimizer{bindings: make(map[string]int), inline: true}
+	o.countBindings(program)
+	s := &constants{values: make(map[string]ast.Expression)}
+	program.Statements = o.statements(program.Statements, nil, s, true)
+	return program
+}
+
+// countBindings は let と関数の引数と import で束縛された名前を数える
+func (o *optimizer) countBindings(program *ast.Program) {
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			o.bindings[node.Name.Value]++
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				o.bindings[param.Value]++
+			}
+		case *ast.MacroLiteral:
+			for _, param := range node.Parameters {
+				o.bindings[param.Value]++
+			}
+		case *ast.ImportStatement:
+			if node.Names == nil {
+				o.inline = false
+			}
+			for _, name := range node.Names {
+				o.bindings[name.Value]++
+			}
+		}
+		return true
+	})
+}
+
+// statements は文の並びを最適化する。unconditional なら並びの文は必ず先頭から順に評価されるので、
+// let の値を後の参照に埋め込める。条件が定数の if の文は、選ばれる分岐の文に置き換える。
+// params は並びが関数の本体のときの引数
+func (o *optimizer) statements(statements []ast.Statement, params []*ast.Identifier, s *constants, unconditional bool) []ast.Statement {
+	// bound は並びで束縛される名前。同じ名前を let する分岐を並びに入れると、let の重複になってしまう
+	bound := make(map[string]bool)
+	for _, param := range params {
+		bound[param.Value] = true
+	}
+	for _, stmt := range statements {
+		if let := letStatement(stmt); let != nil {
+			bound[let.Name.Value] = true
+		}
+	}
+
+	optimized := make([]ast.Statement, 0, len(statements))
+	for i, stmt := range statements {
+		last := i == len(statements)-1
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			o.let(stmt, s, unconditional)
+		case *ast.ExportStatement:
+			o.let(stmt.Let, s, unconditional)
+		case *ast.ReturnStatement:
+			stmt.ReturnValue = o.expression(stmt.ReturnValue, s)
+		case *ast.ExpressionStatement:
+			stmt.Expression = o.expression(stmt.Expression, s)
+			if ie, ok := stmt.Expression.(*ast.IfExpression); ok {
+				if branch, ok := constantBranch(ie); ok && canSplice(branch, last, bound) {
+					if branch != nil {
+						for _, stmt := range branch.Statements {
+							if let := letStatement(stmt); let != nil {
+								bound[let.Name.Value] = true
+							}
+						}
+						optimized = append(optimized, branch.Statements...)
+					}
+					continue
+				}
+			}
+		}
+		optimized = append(optimized, stmt)
+	}
+	return optimized
+}
+
+// let はリテラルで初期化され、プログラムの中で一度だけ束縛される名前なら、後の参照に値を埋め込めるようにする
+func (o *optimizer) let(stmt *ast.LetStatement, s *constants, unconditional bool) {
+	stmt.Value = o.expression(stmt.Value, s)
+	if unconditional && o.inline && o.bindings[stmt.Name.Value] == 1 && isLiteral(stmt.Value) {
+		s.values[stmt.Name.Value] = stmt.Value
+	}
+}
+
+// canSplice は if の文を選ばれる分岐 branch の文に置き換えてよいかを返す。
+// 最後の文の値はブロックやプログラムの値になるので、空の分岐では置き換えない。
+// import と export はトップレベルでだけ評価できるので、分岐の中にあれば置き換えない。
+// 分岐が bound の名前を let していても、置き換えると let の重複になるので置き換えない
+func canSplice(branch *ast.BlockStatement, last bool, bound map[string]bool) bool {
+	if branch == nil || len(branch.Statements) == 0 {
+		return !last
+	}
+	for _, stmt := range branch.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.ImportStatement, *ast.ExportStatement:
+			return false
+		case *ast.LetStatement:
+			if bound[stmt.Name.Value] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// letStatement は stmt が let か export する let ならそれを返す
+func letStatement(stmt ast.Statement) *ast.LetStatement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt
+	case *ast.ExportStatement:
+		return stmt.Let
+	}
+	return nil
+}
+
+func (o *optimizer) block(block *ast.BlockStatement, params []*ast.Identifier, s *constants, unconditional bool) {
+	if block != nil {
+		block.Statements = o.statements(block.Statements, params, s, unconditional)
+	}
+}
+
+func (o *optimizer) expression(exp ast.Expression, s *constants) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if value, ok := s.lookup(exp.Value); ok {
+			return copyLiteral(value, exp.Token.Pos)
+		}
+	case *ast.PrefixExpression:
+		exp.Right = o.expression(exp.Right, s)
+		if folded := foldPrefix(exp); folded != nil {
+			return folded
+		}
+	case *ast.InfixExpression:
+		exp.Left = o.expression(exp.Left, s)
+		exp.Right = o.expression(exp.Right, s)
+		if folded := foldInfix(exp); folded != nil {
+			return folded
+		}
+	case *ast.IfExpression:
+		return o.ifExpression(exp, s)
+	case *ast.FunctionLiteral:
+		o.block(exp.Body, exp.Parameters, &constants{values: make(map[string]ast.Expression), outer: s}, true)
+	case *ast.CallExpression:
+		// quote の中は AST のまま値になるので手を付けない
+		if exp.Function.TokenLiteral() == "quote" {
+			return exp
+		}
+		exp.Function = o.expression(exp.Function, s)
+		o.expressions(exp.Arguments, s)
+	case *ast.ArrayLiteral:
+		o.expressions(exp.Elements, s)
+	case *ast.IndexExpression:
+		exp.Left = o.expression(exp.Left, s)
+		exp.Index = o.expression(exp.Index, s)
+	case *ast.SliceExpression:
+		exp.Left = o.expression(exp.Left, s)
+		if exp.Low != nil {
+			exp.Low = o.expression(exp.Low, s)
+		}
+		if exp.High != nil {
+			exp.High = o.expression(exp.High, s)
+		}
+	case *ast.HashLiteral:
+		for i := range exp.Pairs {
+			exp.Pairs[i].Key = o.expression(exp.Pairs[i].Key, s)
+			exp.Pairs[i].Value = o.expression(exp.Pairs[i].Value, s)
+		}
+	}
+	return exp
+}
+
+func (o *optimizer) expressions(exps []ast.Expression, s *constants) {
+	for i, exp := range exps {
+		exps[i] = o.expression(exp, s)
+	}
+}
+
+// ifExpression は条件が定数なら使われない分岐を取り除く。選ばれる分岐が式ひとつだけなら、その式にする
+func (o *optimizer) ifExpression(ie *ast.IfExpression, s *constants) ast.Expression {
+	ie.Condition = o.expression(ie.Condition, s)
+	o.block(ie.Consequence, nil, s, false)
+	o.block(ie.Alternative, nil, s, false)
+
+	branch, ok := constantBranch(ie)
+	if !ok {
+		return ie
+	}
+	if branch != nil && len(branch.Statements) == 1 {
+		if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			return es.Expression
+		}
+	}
+
+	switch {
+	case branch == nil:
+		// 評価すると null になる if (false) {} だけを残す
+		ie.Consequence = &ast.BlockStatement{Token: ie.Consequence.Token, Statements: []ast.Statement{}}
+	case branch == ie.Alternative:
+		ie.Condition = newBoolean(true, ie.Token.Pos)
+		ie.Consequence = ie.Alternative
+		ie.Alternative = nil
+	default:
+		ie.Alternative = nil
+	}
+	return ie
+}
+
+// constantBranch は条件が定数の if で評価される分岐を返す。偽で else がなければ nil を返す
+func constantBranch(ie *ast.IfExpression) (*ast.BlockStatement, bool) {
+	if !isLiteral(ie.Condition) {
+		return nil, false
+	}
+	if isTruthy(ie.Condition) {
+		return ie.Consequence, true
+	}
+	return ie.Alternative, true
+}
+
+func isLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.StringLiteral:
+		return true
+	}
+	return false
+}
+
+// isTruthy は評価器と同じく false だけを偽とみなす。リテラルは null にならない
+func isTruthy(exp ast.Expression) bool {
+	b, ok := exp.(*ast.Boolean)
+	return !ok || b.Value
+}
+
+// foldPrefix は右辺がリテラルの前置演算を評価したリテラルを返す。畳み込めなければ nil を返す
+func foldPrefix(pe *ast.PrefixExpression) ast.Expression {
+	if !isLiteral(pe.Right) {
+		return nil
+	}
+	switch pe.Operator {
+	case "!":
+		return newBoolean(!isTruthy(pe.Right), pe.Token.Pos)
+	case "-":
+		if i, ok := pe.Right.(*ast.IntegerLiteral); ok {
+			return newInteger(-i.Value, pe.Token.Pos)
+		}
+	}
+	return nil
+}
+
+// foldInfix は両辺がリテラルの中置演算を評価したリテラルを返す。
+// 実行時にエラーになる組み合わせや 0 での割り算は、評価のときに報告されるよう畳み込まない
+func foldInfix(ie *ast.InfixExpression) ast.Expression {
+	switch left := ie.Left.(type) {
+	case *ast.IntegerLiteral:
+		right, ok := ie.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return nil
+		}
+		l, r := left.Value, right.Value
+		switch ie.Operator {
+		case "+":
+			return newInteger(l+r, ie.Token.Pos)
+		case "-":
+			return newInteger(l-r, ie.Token.Pos)
+		case "*":
+			return newInteger(l*r, ie.Token.Pos)
+		case "/":
+			if r == 0 {
+				return nil
+			}
+			return newInteger(l/r, ie.Token.Pos)
+		case "<":
+			return newBoolean(l < r, ie.Token.Pos)
+		case ">":
+			return newBoolean(l > r, ie.Token.Pos)
+		case "==":
+			return newBoolean(l == r, ie.Token.Pos)
+		case "!=":
+			return newBoolean(l != r, ie.Token.Pos)
+		}
+	case *ast.StringLiteral:
+		right, ok := ie.Right.(*ast.StringLiteral)
+		if ok && ie.Operator == "+" {
+			return newString(left.Value+right.Value, ie.Token.Pos)
+		}
+	case *ast.Boolean:
+		right, ok := ie.Right.(*ast.Boolean)
+		if !ok {
+			return nil
+		}
+		switch ie.Operator {
+		case "==":
+			return newBoolean(left.Value == right.Value, ie.Token.Pos)
+		case "!=":
+			return newBoolean(left.Value != right.Value, ie.Token.Pos)
+		}
+	}
+	return nil
+}
+
+// 畳み込んだリテラルのトークンの位置は、元の式や参照のトークンの位置にする
+func newInteger(value int64, pos token.Position) *ast.IntegerLiteral {
+	literal := strconv.FormatInt(value, 10)
+	return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal, Pos: pos}, Value: value}
+}
+
+func newBoolean(value bool, pos token.Position) *ast.Boolean {
+	if value {
+		return &ast.Boolean{Token: token.Token{Type: token.TRUE, Literal: "true", Pos: pos}, Value: true}
+	}
+	return &ast.Boolean{Token: token.Token{Type: token.FALSE, Literal: "false", Pos: pos}, Value: false}
+}
+
+func newString(value string, pos token.Position) *ast.StringLiteral {
+	return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: value, Pos: pos}, Value: value}
+}
+
+// copyLiteral は埋め込む let の値を、参照の位置のトークンを持つ複製にする
+func copyLiteral(literal ast.Expression, pos token.Position) ast.Expression {
+	switch literal := literal.(type) {
+	case *ast.IntegerLiteral:
+		return newInteger(literal.Value, pos)
+	case *ast.Boolean:
+		return newBoolean(literal.Value, pos)
+	case *ast.StringLiteral:
+		return newString(literal.Value, pos)
+	}
+	return literal
+}
@@ -0,0 +1,138 @@
+package optimizer
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/parser"
+	"github.com/care0717/monkey-interpreter/resolver"
+	"github.com/care0717/monkey-interpreter/token"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// 畳み込み
+		{`60 * 60 * 24`, `86400`},
+		{`1 + 2 * 3 - 4 / 2`, `5`},
+		{`-(2 + 3)`, `-5`},
+		{`1 < 2`, `true`},
+		{`3 == 4`, `false`},
+		{`"foo" + "bar" + "baz"`, `foobarbaz`},
+		{`!true`, `false`},
+		{`!!5`, `true`},
+		{`!""`, `false`},
+		{`true == !false`, `true`},
+		{`true != false`, `true`},
+		{`x + 2 * 3`, `(x + 6)`},
+		{`1 + 2 + x`, `(1 + (2 + x))`},
+		// 実行時のエラーは残す
+		{`1 / 0`, `(1 / 0)`},
+		{`1 + true`, `(1 + true)`},
+		{`"a" == "a"`, `(a == a)`},
+		{`-true`, `(-true)`},
+		// 分岐の除去
+		{`if (1 < 2) { x } else { y }`, `x`},
+		{`if (false) { x } else { y }`, `y`},
+		{`if (0) { x }`, `x`},
+		{`if (false) { x }`, `if false `},
+		{`if (true) { let a = 1; a } else { y }`, `let a = 1;a`},
+		{`let f = fn() { if (true) { puts(1); 2 } };`, `let f = fn() puts(1)2;`},
+		{`if (false) { puts(1) }; 2`, `2`},
+		{`let v = if (false) { puts(1); 1 } else { puts(2); 2 };`, `let v = if true puts(2)2;`},
+		{`let v = if (true) { puts(1); 1 } else { 2 };`, `let v = if true puts(1)1;`},
+		// 並びで束縛済みの名前を let する分岐は、let の重複にならないよう展開しない
+		{`let x = 1; if (true) { let x = 2; }; puts(x);`, `let x = 1;if true let x = 2;puts(x)`},
+		{`if (true) { let x = 2; }; let x = 1; x`, `if true let x = 2;let x = 1;x`},
+		{`let f = fn(x) { if (true) { let x = 2; }; x };`, `let f = fn(x) if true let x = 2;x;`},
+		{`let f = fn() { let x = 1; if (true) { let x = 2; }; x };`, `let f = fn() let x = 1;if true let x = 2;x;`},
+		{`if (true) { let x = 1; }; if (true) { let x = 2; }; x`, `let x = 1;if true let x = 2;x`},
+		// let の値の埋め込み
+		{`let day = 60 * 60 * 24; day * 7`, `let day = 86400;604800`},
+		{`let name = "monkey"; "hello " + name`, `let name = monkey;hello monkey`},
+		{`let debug = false; if (debug) { puts("debug") }; 1`, `let debug = false;1`},
+		{`let f = fn() { let n = 2; n * n }; f()`, `let f = fn() let n = 2;4;f()`},
+		{`let n = 2; let f = fn(x) { x * n };`, `let n = 2;let f = fn(x) (x * 2);`},
+		// 一度だけ束縛される名前でなければ埋め込まない
+		{`let n = 1; let n = 2; n`, `let n = 1;let n = 2;n`},
+		{`let n = 1; let f = fn(n) { n }; n`, `let n = 1;let f = fn(n) n;n`},
+		// let より前に書かれた参照は埋め込まない
+		{`let f = fn() { n }; let n = 1; f()`, `let f = fn() n;let n = 1;f()`},
+		// 条件によっては評価されない let は埋め込まない
+		{`if (c) { let n = 1 }; n`, `if c let n = 1;n`},
+		// 別の関数の let は見えない
+		{`let f = fn() { let n = 1; n }; let g = fn() { n };`, `let f = fn() let n = 1;1;let g = fn() n;`},
+		// 名前を列挙しない import があれば埋め込まない
+		{`let n = 1; import "m"; n`, `let n = 1;import "m";n`},
+		{`import { m } from "m"; let n = 1; n + m`, `import { m } from "m";let n = 1;(1 + m)`},
+		// quote の中は変えない
+		{`let n = 1; quote(n + 1 + 2)`, `let n = 1;quote((n + (1 + 2)))`},
+		{`export let n = 2 * 3; n`, `export let n = 6;6`},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(t, tt.input)
+		optimized := Optimize(program)
+		if optimized.String() != tt.expected {
+			t.Errorf("case: %s. expected=%q, got=%q", tt.input, tt.expected, optimized.String())
+		}
+	}
+}
+
+func TestOptimizeKeepsLetsUnique(t *testing.T) {
+	tests := []string{
+		`let x = 1; if (true) { let x = 2; }; puts(x);`,
+		`let f = fn(x) { if (true) { let x = 2; }; x }; f(1)`,
+		`let f = fn() { let x = 1; if (true) { let x = 2; }; x }; f()`,
+		`if (true) { let x = 1; }; if (true) { let x = 2; }; x`,
+	}
+
+	for _, input := range tests {
+		r := resolver.New(func(name string) bool { return name == "puts" })
+		r.Resolve(Optimize(testParseProgram(t, input)))
+		if len(r.Errors()) != 0 {
+			t.Errorf("case: %s. resolve errors: %v", input, r.Errors())
+		}
+	}
+}
+
+func TestOptimizeDoesNotModifyInput(t *testing.T) {
+	input := `let n = 2; if (true) { n * 3 }`
+	program := testParseProgram(t, input)
+	before := program.String()
+	Optimize(program)
+	if program.String() != before {
+		t.Errorf("input modified. expected=%q, got=%q", before, program.String())
+	}
+}
+
+func TestOptimizePositions(t *testing.T) {
+	program := Optimize(testParseProgram(t, "let n = 1;\n[n, 2 * 3]"))
+	stmt := program.Statements[1].(*ast.ExpressionStatement)
+	array := stmt.Expression.(*ast.ArrayLiteral)
+	tests := []struct {
+		exp      ast.Expression
+		expected token.Position
+	}{
+		// 埋め込んだ値は参照の位置、畳み込んだ値は演算子の位置を持つ
+		{array.Elements[0], token.Position{Line: 2, Column: 2}},
+		{array.Elements[1], token.Position{Line: 2, Column: 7}},
+	}
+	for _, tt := range tests {
+		if got := tt.exp.(*ast.IntegerLiteral).Token.Pos; got != tt.expected {
+			t.Errorf("%s: expected=%s, got=%s", tt.exp, tt.expected, got)
+		}
+	}
+}
+
+func testParseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("case: %s. parser errors: %v", input, p.Errors())
+	}
+	return program
+}
@@ -46,6 +46,7 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 	errors    []string
+	errorList []Error
 
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
@@ -113,6 +114,10 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -130,6 +135,14 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		Value: p.curToken.Literal,
 	}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if stmt.Type = p.parseType(); stmt.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.mustExpectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -159,6 +172,73 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Names = p.parseImportNames()
+		if stmt.Names == nil {
+			return nil
+		}
+
+		// from はキーワードではないので識別子として読む
+		if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "from" {
+			p.errorAt(p.peekToken.Pos, "expected next token to be from, got %s", p.peekToken.Type)
+			return nil
+		}
+		p.nextToken()
+	}
+
+	if !p.mustExpectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseImportNames() []*ast.Identifier {
+	var names []*ast.Identifier
+
+	for {
+		if !p.mustExpectPeek(token.IDENT) {
+			return nil
+		}
+		names = append(names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.mustExpectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return names
+}
+
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	if !p.mustExpectPeek(token.LET) {
+		return nil
+	}
+
+	stmt.Let = p.parseLetStatement()
+	if stmt.Let == nil {
+		return nil
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	//defer untrace(trace("parseExpressionStatement"))
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
@@ -173,8 +253,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.Type) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken.Pos, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parseExpression(precedence precedence) ast.Expression {
@@ -239,7 +318,15 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.mustExpectPeek(token.LPAREN) {
 		return nil
 	}
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.ParameterTypes = p.parseFunctionParameters()
+
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		p.nextToken()
+		if lit.ReturnType = p.parseType(); lit.ReturnType == nil {
+			return nil
+		}
+	}
 
 	if !p.mustExpectPeek(token.LBRACE) {
 		return nil
@@ -257,7 +344,12 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	var types []ast.TypeExpression
+	lit.Parameters, types = p.parseFunctionParameters()
+	if types != nil {
+		p.errorAt(lit.Token.Pos, "macro parameters cannot have type annotations")
+		return nil
+	}
 
 	if !p.mustExpectPeek(token.LBRACE) {
 		return nil
@@ -268,38 +360,109 @@ func (p *Parser) parseMacroLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters は引数と、name: type の型の注釈を読む。
+// 注釈は引数と同じ長さで返し、どの引数にも注釈がなければ nil を返す
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.TypeExpression) {
 	var identifiers []*ast.Identifier
+	var types []ast.TypeExpression
+	annotated := false
 
 	// 引数なしの場合空のIdentifier
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
-	}
-
-	p.nextToken()
-
-	ident := &ast.Identifier{
-		Token: p.curToken,
-		Value: p.curToken.Literal,
+		return identifiers, nil
 	}
-	identifiers = append(identifiers, ident)
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
+	for {
 		p.nextToken()
 		ident := &ast.Identifier{
 			Token: p.curToken,
 			Value: p.curToken.Literal,
 		}
 		identifiers = append(identifiers, ident)
+
+		var t ast.TypeExpression
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if t = p.parseType(); t == nil {
+				return nil, nil
+			}
+			annotated = true
+		}
+		types = append(types, t)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.mustExpectPeek(token.RPAREN) {
-		return nil
+		return nil, nil
 	}
 
-	return identifiers
+	if !annotated {
+		return identifiers, nil
+	}
+	return identifiers, types
+}
+
+// parseType は int、[int]、{string: int}、fn(int) -> bool のような型の注釈を読む
+func (p *Parser) parseType() ast.TypeExpression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		if !ast.BasicTypes[p.curToken.Literal] {
+			p.errorAt(p.curToken.Pos, "unknown type %s", p.curToken.Literal)
+			return nil
+		}
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.LBRACKET:
+		t := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		if t.Elem = p.parseType(); t.Elem == nil || !p.mustExpectPeek(token.RBRACKET) {
+			return nil
+		}
+		return t
+	case token.LBRACE:
+		t := &ast.HashType{Token: p.curToken}
+		p.nextToken()
+		if t.Key = p.parseType(); t.Key == nil || !p.mustExpectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		if t.Value = p.parseType(); t.Value == nil || !p.mustExpectPeek(token.RBRACE) {
+			return nil
+		}
+		return t
+	case token.FUNCTION:
+		t := &ast.FunctionType{Token: p.curToken, Params: []ast.TypeExpression{}}
+		if !p.mustExpectPeek(token.LPAREN) {
+			return nil
+		}
+		for !p.peekTokenIs(token.RPAREN) {
+			if len(t.Params) > 0 && !p.mustExpectPeek(token.COMMA) {
+				return nil
+			}
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			t.Params = append(t.Params, param)
+		}
+		p.nextToken()
+		if !p.mustExpectPeek(token.ARROW) {
+			return nil
+		}
+		p.nextToken()
+		if t.Return = p.parseType(); t.Return == nil {
+			return nil
+		}
+		return t
+	}
+	p.errorAt(p.curToken.Pos, "expected a type, got %s", p.curToken.Type)
+	return nil
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
@@ -351,8 +514,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.curToken.Pos, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -451,12 +613,42 @@ func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{
-		Token: p.curToken,
+	tok := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	if !p.mustExpectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return &ast.IndexExpression{
+		Token: tok,
 		Left:  left,
+		Index: index,
+	}
+}
+
+// parseSliceExpression は ":" の位置から left[low:high] の残りを読む
+func (p *Parser) parseSliceExpression(tok token.Token, left, low ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{
+		Token: tok,
+		Left:  left,
+		Low:   low,
+	}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.High = p.parseExpression(LOWEST)
 	}
-	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
 
 	if !p.mustExpectPeek(token.RBRACKET) {
 		return nil
@@ -507,9 +699,25 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
-func (p *Parser) peekError(expectedType token.Type) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s", expectedType, p.peekToken.Type)
+// Error は構文解析のエラーと、エラーの原因になったトークンの位置
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+// ErrorList は Errors と同じエラーを、位置をつけて返す。エディタに位置を伝えるのに使う
+func (p *Parser) ErrorList() []Error {
+	return p.errorList
+}
+
+func (p *Parser) errorAt(pos token.Position, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
 	p.errors = append(p.errors, msg)
+	p.errorList = append(p.errorList, Error{Pos: pos, Message: msg})
+}
+
+func (p *Parser) peekError(expectedType token.Type) {
+	p.errorAt(p.peekToken.Pos, "expected next token to be %s, got %s", expectedType, p.peekToken.Type)
 }
 
 func (p *Parser) registerPrefix(tokenType token.Type, fn prefixParseFn) {
@@ -6,10 +6,14 @@ import (
 	"github.com/care0717/monkey-interpreter/lexer"
 	"github.com/care0717/monkey-interpreter/token"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"go.uber.org/multierr"
 	"testing"
 )
 
+// ignorePos はトークンの位置を比較から外す。位置は lexer のテストで検査する
+var ignorePos = cmpopts.IgnoreFields(token.Token{}, "Pos")
+
 func checkParserErrors(p *Parser) error {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -131,8 +135,8 @@ func testLetStatement(s ast.Statement, expect ast.LetStatement) error {
 		return fmt.Errorf("s not *%T, got=%T", expect, s)
 	}
 
-	if !cmp.Equal(letStmt, &expect) {
-		return fmt.Errorf("%T diff %s", expect, cmp.Diff(&expect, letStmt))
+	if !cmp.Equal(letStmt, &expect, ignorePos) {
+		return fmt.Errorf("%T diff %s", expect, cmp.Diff(&expect, letStmt, ignorePos))
 	}
 	return nil
 }
@@ -223,8 +227,8 @@ func testReturnStatement(s ast.Statement, expect ast.ReturnStatement) error {
 		return fmt.Errorf("s not *%T, got=%T", expect, s)
 	}
 
-	if !cmp.Equal(returnStmt, &expect) {
-		return fmt.Errorf("%T diff %s", expect, cmp.Diff(&expect, returnStmt))
+	if !cmp.Equal(returnStmt, &expect, ignorePos) {
+		return fmt.Errorf("%T diff %s", expect, cmp.Diff(&expect, returnStmt, ignorePos))
 	}
 	return nil
 }
@@ -254,8 +258,8 @@ func testExpression(statement ast.Statement, expect ast.Expression) error {
 		return fmt.Errorf("s not *ast.ExpressionStatement, got=%T", statement)
 	}
 
-	if !cmp.Equal(stmt.Expression, expect) {
-		return fmt.Errorf("%T diff %s [-got, +expected]", expect, cmp.Diff(stmt.Expression, expect))
+	if !cmp.Equal(stmt.Expression, expect, ignorePos) {
+		return fmt.Errorf("%T diff %s [-got, +expected]", expect, cmp.Diff(stmt.Expression, expect, ignorePos))
 	}
 	return nil
 }
@@ -757,6 +761,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], 2 * [1, 2][1])",
 			"add((a * (b[2])), (2 * ([1, 2][1])))",
 		},
+		{
+			"a[1:b + 1]",
+			"(a[1:(b + 1)])",
+		},
+		{
+			"a[:-1][0]",
+			"((a[:(-1)])[0])",
+		},
+		{
+			"a[f(x):] + b[:]",
+			"((a[f(x):]) + (b[:]))",
+		},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -1268,6 +1284,71 @@ func TestParsingIndexExpression(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []ast.Expression
+	}{
+		{
+			input: "myArray[1:]",
+			expected: []ast.Expression{
+				&ast.SliceExpression{
+					Token: token.Token{
+						Type:    token.LBRACKET,
+						Literal: "[",
+					},
+					Left: &ast.Identifier{
+						Token: token.Token{
+							Type:    token.IDENT,
+							Literal: "myArray",
+						},
+						Value: "myArray",
+					},
+					Low: &ast.IntegerLiteral{
+						Token: token.Token{
+							Type:    token.INT,
+							Literal: "1",
+						},
+						Value: 1,
+					},
+				},
+			},
+		},
+		{
+			input: "myArray[:2]",
+			expected: []ast.Expression{
+				&ast.SliceExpression{
+					Token: token.Token{
+						Type:    token.LBRACKET,
+						Literal: "[",
+					},
+					Left: &ast.Identifier{
+						Token: token.Token{
+							Type:    token.IDENT,
+							Literal: "myArray",
+						},
+						Value: "myArray",
+					},
+					High: &ast.IntegerLiteral{
+						Token: token.Token{
+							Type:    token.INT,
+							Literal: "2",
+						},
+						Value: 2,
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		if err := testExpressionProgram(p, tt.expected); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func TestParsingHashLiterals(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -1287,14 +1368,14 @@ func TestParsingHashLiterals(t *testing.T) {
 					},
 					Pairs: []ast.HashPair{
 						{
-							&ast.StringLiteral{
+							Key: &ast.StringLiteral{
 								Token: token.Token{
 									Type:    token.STRING,
 									Literal: "one",
 								},
 								Value: "one",
 							},
-							&ast.IntegerLiteral{
+							Value: &ast.IntegerLiteral{
 								Token: token.Token{
 									Type:    token.INT,
 									Literal: "1",
@@ -1303,14 +1384,14 @@ func TestParsingHashLiterals(t *testing.T) {
 							},
 						},
 						{
-							&ast.StringLiteral{
+							Key: &ast.StringLiteral{
 								Token: token.Token{
 									Type:    token.STRING,
 									Literal: "two",
 								},
 								Value: "two",
 							},
-							&ast.IntegerLiteral{
+							Value: &ast.IntegerLiteral{
 								Token: token.Token{
 									Type:    token.INT,
 									Literal: "2",
@@ -1334,14 +1415,14 @@ func TestParsingHashLiterals(t *testing.T) {
 					},
 					Pairs: []ast.HashPair{
 						{
-							&ast.Boolean{
+							Key: &ast.Boolean{
 								Token: token.Token{
 									Type:    token.TRUE,
 									Literal: "true",
 								},
 								Value: true,
 							},
-							&ast.InfixExpression{
+							Value: &ast.InfixExpression{
 								Token: token.Token{
 									Type:    token.PLUS,
 									Literal: "+",
@@ -1364,7 +1445,7 @@ func TestParsingHashLiterals(t *testing.T) {
 							},
 						},
 						{
-							&ast.InfixExpression{
+							Key: &ast.InfixExpression{
 								Token: token.Token{
 									Type:    token.PLUS,
 									Literal: "+",
@@ -1385,7 +1466,7 @@ func TestParsingHashLiterals(t *testing.T) {
 									Value: 2,
 								},
 							},
-							&ast.IntegerLiteral{
+							Value: &ast.IntegerLiteral{
 								Token: token.Token{
 									Type:    token.INT,
 									Literal: "10",
@@ -1483,3 +1564,185 @@ func TestMacroLiteralParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestImportExportStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `import "lib/math";`,
+			expected: `import "lib/math";`,
+		},
+		{
+			input:    `import { square } from "lib/math"`,
+			expected: `import { square } from "lib/math";`,
+		},
+		{
+			input:    `import { a, b, c } from "lib"; a`,
+			expected: `import { a, b, c } from "lib";a`,
+		},
+		{
+			input:    `export let square = fn(x) { x * x };`,
+			expected: `export let square = fn(x) (x * x);`,
+		},
+		{
+			input:    `let from = 1; from`,
+			expected: `let from = 1;from`,
+		},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		if err := checkParserErrors(p); err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, but got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestImportExportStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    `import lib;`,
+			expected: []string{"expected next token to be STRING, got IDENT"},
+		},
+		{
+			input:    `import {} from "lib";`,
+			expected: []string{"expected next token to be IDENT, got }"},
+		},
+		{
+			input:    `import { a } "lib";`,
+			expected: []string{"expected next token to be from, got STRING"},
+		},
+		{
+			input:    `export fn(x) { x };`,
+			expected: []string{"expected next token to be LET, got FUNCTION"},
+		},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) < len(tt.expected) {
+			t.Errorf("case: %s. expected errors %q, got %q", tt.input, tt.expected, errors)
+			continue
+		}
+		if !cmp.Equal(errors[:len(tt.expected)], tt.expected) {
+			t.Errorf("case: %s. %s", tt.input, cmp.Diff(errors[:len(tt.expected)], tt.expected))
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x: int = 5;`, `let x: int = 5;`},
+		{`let xs: [string] = [];`, `let xs: [string] = [];`},
+		{`let h: {string: [int]} = {};`, `let h: {string: [int]} = {};`},
+		{`let f: fn(int, string) -> bool = g;`, `let f: fn(int, string) -> bool = g;`},
+		{`let f: fn() -> null = g;`, `let f: fn() -> null = g;`},
+		{`fn(a: int, b: string) -> bool { true }`, `fn(a: int, b: string) -> bool true`},
+		{`fn(a, b: any) { a }`, `fn(a, b: any) a`},
+		{`fn() -> quote { quote(1) }`, `fn() -> quote quote(1)`},
+		{`fn(f: fn(int) -> int) -> [int] { [f(1)] }`, `fn(f: fn(int) -> int) -> [int] [f(1)]`},
+		{`export let n: int = 1;`, `export let n: int = 1;`},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if err := checkParserErrors(p); err != nil {
+			t.Error(err)
+			continue
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, but got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestTypeAnnotationFields(t *testing.T) {
+	p := New(lexer.New(`fn(a, b: int) { a }; fn(a, b) { a }`))
+	program := p.ParseProgram()
+	if err := checkParserErrors(p); err != nil {
+		t.Fatal(err)
+	}
+
+	annotated := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(annotated.ParameterTypes) != 2 || annotated.ParameterTypes[0] != nil || annotated.ParameterTypes[1].String() != "int" {
+		t.Errorf("wrong parameter types: %v", annotated.ParameterTypes)
+	}
+	if annotated.ReturnType != nil {
+		t.Errorf("expected no return type, got %s", annotated.ReturnType)
+	}
+	// どの引数にも注釈がなければ nil
+	plain := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if plain.ParameterTypes != nil {
+		t.Errorf("expected nil parameter types, got %v", plain.ParameterTypes)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x: integer = 5;`, []string{"unknown type integer"}},
+		{`let x: = 5;`, []string{"expected a type, got ="}},
+		{`let x: [int = 5;`, []string{"expected next token to be ], got ="}},
+		{`let h: {string} = {};`, []string{"expected next token to be :, got }"}},
+		{`let f: fn(int) = g;`, []string{"expected next token to be ->, got ="}},
+		{`fn(a: int) -> 1 { a }`, []string{"expected a type, got INT"}},
+		{`macro(a: int) { a }`, []string{"macro parameters cannot have type annotations"}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) < len(tt.expected) {
+			t.Errorf("case: %s. expected errors %q, got %q", tt.input, tt.expected, errors)
+			continue
+		}
+		if !cmp.Equal(errors[:len(tt.expected)], tt.expected) {
+			t.Errorf("case: %s. %s", tt.input, cmp.Diff(errors[:len(tt.expected)], tt.expected))
+		}
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Error
+	}{
+		{"let x = ;", []Error{{Pos: token.Position{Line: 1, Column: 9}, Message: "no prefix parse function for ; found"}}},
+		{"let a = 1;\nlet 5 = a;", []Error{{Pos: token.Position{Line: 2, Column: 5}, Message: "expected next token to be IDENT, got INT"}}},
+		{"let x: integer = 5;", []Error{{Pos: token.Position{Line: 1, Column: 8}, Message: "unknown type integer"}}},
+	}
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.ErrorList()
+		if len(errors) < len(tt.expected) {
+			t.Errorf("case: %q. expected errors %v, got %v", tt.input, tt.expected, errors)
+			continue
+		}
+		if diff := cmp.Diff(tt.expected, errors[:len(tt.expected)]); diff != "" {
+			t.Errorf("case: %q. %s", tt.input, diff)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+let identity = fn(x) { x };
+
+let compose = fn(f, g) {
+  fn(x) { f(g(x)) };
+};
+
+let flip = fn(f) {
+  fn(a, b) { f(b, a) };
+};
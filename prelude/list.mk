@@ -0,0 +1,31 @@
+let sum = fn(arr) {
+  reduce(arr, 0, fn(acc, x) { acc + x });
+};
+
+let product = fn(arr) {
+  reduce(arr, 1, fn(acc, x) { acc * x });
+};
+
+let max = fn(arr) {
+  if (len(arr) > 0) {
+    reduce(rest(arr), first(arr), fn(m, x) { if (x > m) { x } else { m } });
+  }
+};
+
+let min = fn(arr) {
+  if (len(arr) > 0) {
+    reduce(rest(arr), first(arr), fn(m, x) { if (x < m) { x } else { m } });
+  }
+};
+
+let count = fn(arr, f) {
+  len(filter(arr, f));
+};
+
+let take = fn(arr, n) {
+  arr[:n];
+};
+
+let drop = fn(arr, n) {
+  arr[n:];
+};
@@ -0,0 +1,7 @@
+let unless = macro(condition, consequence, alternative) {
+  quote(if (!(unquote(condition))) {
+    unquote(consequence);
+  } else {
+    unquote(alternative);
+  });
+};
@@ -0,0 +1,33 @@
+// Package prelude はインタプリタに組み込まれる、Monkey で書かれた標準ライブラリ
+package prelude
+
+import (
+	"embed"
+	"path"
+)
+
+//go:embed *.mk
+var files embed.FS
+
+type File struct {
+	Name   string
+	Source string
+}
+
+// Files は組み込みのソースをファイル名の順に返す
+func Files() []File {
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		panic(err)
+	}
+
+	var result []File
+	for _, entry := range entries {
+		src, err := files.ReadFile(path.Join(".", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, File{Name: entry.Name(), Source: string(src)})
+	}
+	return result
+}
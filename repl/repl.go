@@ -5,17 +5,29 @@ import (
 	"fmt"
 	"github.com/care0717/monkey-interpreter/evaluator"
 	"github.com/care0717/monkey-interpreter/lexer"
-	"github.com/care0717/monkey-interpreter/object"
 	"github.com/care0717/monkey-interpreter/parser"
 	"io"
+	"strings"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
+type Options struct {
+	// NoPrelude が true なら標準ライブラリを読み込まない
+	NoPrelude bool
+}
+
+func Start(in io.Reader, out io.Writer, opts Options) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
-	macroEnv := object.NewEnvironment()
+	interpreter := evaluator.New(in, out)
+	interpreter.SearchPath = evaluator.SearchPathFromEnv()
+	if !opts.NoPrelude {
+		if err := interpreter.LoadPrelude(); err != nil {
+			io.WriteString(out, err.Error()+"\n")
+			return
+		}
+	}
+	env := interpreter.NewEnvironment()
 	for {
 		fmt.Printf(PROMPT)
 		scanned := scanner.Scan()
@@ -24,19 +36,39 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
+		// :expand <code> はマクロを展開した結果を表示するだけで、評価しない
+		expandOnly := false
+		if rest, ok := cutCommand(line, ":expand"); ok {
+			line = rest
+			expandOnly = true
+		}
 		l := lexer.New(line)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printErrors(out, "parser", p.Errors())
 			continue
 		}
 
-		evaluator.DefineMacros(program, macroEnv)
-		expanded := evaluator.ExpandMacros(program, macroEnv)
+		// トップレベルのマクロはインタプリタに登録され、次の入力からも使える
+		expanded, errors := interpreter.ExpandMacros(program)
+		if len(errors) != 0 {
+			printErrors(out, "macro", errors)
+			continue
+		}
+		if expandOnly {
+			io.WriteString(out, expanded.String())
+			io.WriteString(out, "\n")
+			continue
+		}
+		// 関数の中からは、後の入力で定義する名前も参照できる
+		if errors := interpreter.ResolveInput(expanded, env); len(errors) != 0 {
+			printErrors(out, "resolve", errors)
+			continue
+		}
 
-		evaluated := evaluator.Eval(expanded, env)
+		evaluated := interpreter.Eval(expanded, env)
 
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
@@ -45,8 +77,17 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, "parser errors:\n")
+// cutCommand は line が command で始まれば、残りを返す
+func cutCommand(line string, command string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed != command && !strings.HasPrefix(trimmed, command+" ") {
+		return "", false
+	}
+	return strings.TrimPrefix(trimmed, command), true
+}
+
+func printErrors(out io.Writer, kind string, errors []string) {
+	io.WriteString(out, kind+" errors:\n")
 	for _, msg := range errors {
 		io.WriteString(out, "\t"+msg+"\n")
 	}
@@ -0,0 +1,36 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			// 関数の中からは後の入力で定義する名前を参照できる
+			input:    "let f = fn() { g() + 1 };\nlet g = fn() { 41 };\nf()\n",
+			expected: "42\n",
+		},
+		{
+			input:    "g()\n",
+			expected: "resolve errors:\n\t1:1: identifier not found: g\n",
+		},
+		{
+			input:    "let f = fn() { g() };\nf()\n",
+			expected: "ERROR: 1:16: identifier not found: g\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out, Options{NoPrelude: true})
+		if out.String() != tt.expected {
+			t.Errorf("case: %q. expected=%q, got=%q", tt.input, tt.expected, out.String())
+		}
+	}
+}
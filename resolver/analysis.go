@@ -0,0 +1,335 @@
+package resolver
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/token"
+)
+
+// Scope は関数ひとつ分、またはプログラム全体で宣言された名前。
+// ブロックは評価器と同じく新しいスコープを作らない
+type Scope struct {
+	// Function はスコープを作った関数リテラルかマクロリテラル。プログラム全体のスコープでは nil
+	Function ast.Expression
+	Outer    *Scope
+	// Bindings は最初に宣言された順に並んだ束縛。添字が評価器のスロットになる
+	Bindings []*Binding
+	names    map[string]*Binding
+}
+
+func newScope(fn ast.Expression, outer *Scope) *Scope {
+	return &Scope{Function: fn, Outer: outer, names: make(map[string]*Binding)}
+}
+
+func (s *Scope) IsGlobal() bool { return s.Outer == nil }
+
+// Lookup は s から外側に向かって name の束縛を探す。後ろの let で定義される名前も見つける
+func (s *Scope) Lookup(name string) *Binding {
+	for ; s != nil; s = s.Outer {
+		if b, ok := s.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// declare は ident の名前の束縛を返す。まだなければ作る
+func (s *Scope) declare(ident *ast.Identifier) *Binding {
+	b, ok := s.names[ident.Value]
+	if !ok {
+		b = &Binding{Name: ident.Value, Scope: s, Slot: len(s.Bindings)}
+		s.names[ident.Value] = b
+		s.Bindings = append(s.Bindings, b)
+	}
+	b.Declarations = append(b.Declarations, ident)
+	return b
+}
+
+// Binding はスコープの中のひとつの名前。同じスコープで何度 let しても束縛はひとつで、評価器では同じスロットを使う
+type Binding struct {
+	Name  string
+	Scope *Scope
+	Slot  int
+	// Declarations は名前を束縛する let の名前、引数、import の名前。宣言した順に並ぶ
+	Declarations []*ast.Identifier
+	// latest は解析し終えた最後の宣言。nil なら同じスコープからはまだ参照できない
+	latest *ast.Identifier
+}
+
+// Reference は識別子がどのスコープに現れ、どの束縛を指すか
+type Reference struct {
+	Scope *Scope
+	// Binding は識別子の指す束縛。見つからなければ nil
+	Binding *Binding
+	// Pending は束縛が見つからず、同じ名前がどこかのスコープの後ろの let でだけ定義されるかどうか
+	Pending bool
+}
+
+// Depth は識別子のスコープから束縛のスコープまで、いくつ外側にたどるかを返す
+func (r Reference) Depth() int {
+	depth := 0
+	for s := r.Scope; s != r.Binding.Scope; s = s.Outer {
+		depth++
+	}
+	return depth
+}
+
+// Parameter は関数リテラルかマクロリテラルの Index 番目の引数
+type Parameter struct {
+	Function ast.Expression
+	Index    int
+}
+
+// Analysis は program の識別子がどの宣言を指すかを調べた結果。
+// 評価器の Resolver も linter も型検査器も language server も、同じ規則で名前を引くためにこれを使う
+type Analysis struct {
+	Global *Scope
+	// Functions は関数リテラルとマクロリテラルから、その中のスコープを引く
+	Functions map[ast.Expression]*Scope
+	// Identifiers は解析した識別子。宣言も参照も現れた順に並ぶ。quote の中は unquote の引数だけを含む
+	Identifiers []*ast.Identifier
+	// References は Identifiers の識別子から、そのスコープと指す束縛を引く
+	References map[*ast.Identifier]Reference
+	// Definitions は参照から、その値を束縛した宣言を引く
+	Definitions map[*ast.Identifier]*ast.Identifier
+	// Lets と Params は宣言の識別子から、宣言した let や関数を引く
+	Lets   map[*ast.Identifier]*ast.LetStatement
+	Params map[*ast.Identifier]Parameter
+	// Duplicates は同じ文の並びで二度目に let した名前と、同じ関数で二度目に現れた引数
+	Duplicates map[*ast.Identifier]bool
+}
+
+// Analyze は program の識別子を解決する。構文解析に失敗した program にも使える
+func Analyze(program *ast.Program) *Analysis {
+	a := &Analysis{
+		Global:      newScope(nil, nil),
+		Functions:   make(map[ast.Expression]*Scope),
+		References:  make(map[*ast.Identifier]Reference),
+		Definitions: make(map[*ast.Identifier]*ast.Identifier),
+		Lets:        make(map[*ast.Identifier]*ast.LetStatement),
+		Params:      make(map[*ast.Identifier]Parameter),
+		Duplicates:  make(map[*ast.Identifier]bool),
+	}
+	a.declareLets(a.Global, program.Statements)
+	a.statements(program.Statements, a.Global, make(map[string]bool))
+	return a
+}
+
+// broken は構文解析に失敗して nil のまま残った文かどうかを返す
+func broken(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node == nil || node.Name == nil
+	case *ast.ReturnStatement:
+		return node == nil
+	case *ast.ExpressionStatement:
+		return node == nil
+	case *ast.ImportStatement:
+		return node == nil
+	case *ast.ExportStatement:
+		return node == nil || broken(node.Let)
+	case *ast.BlockStatement:
+		return node == nil
+	}
+	return false
+}
+
+// declareLets は関数の内側と quote の中を除いて、statements 中の let をすべて宣言する。
+// 後ろで定義される変数を内側の関数から参照できるようにするため、先にまとめて宣言しておく。
+// 同じスコープから let より前で参照した名前は、外側の変数を指す。import した名前ははじめから参照できる
+func (a *Analysis) declareLets(s *Scope, statements []ast.Statement) {
+	ast.Inspect(&ast.BlockStatement{Statements: statements}, func(node ast.Node) bool {
+		if broken(node) {
+			return false
+		}
+		switch node := node.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.CallExpression:
+			return node.Function.TokenLiteral() != "quote"
+		case *ast.LetStatement:
+			s.declare(node.Name)
+			a.Lets[node.Name] = node
+		case *ast.ImportStatement:
+			for _, name := range node.Names {
+				s.declare(name).latest = name
+				a.References[name] = Reference{Scope: s, Binding: s.names[name.Value]}
+			}
+		}
+		return true
+	})
+}
+
+// statements は文の並びを解決する。seen は同じ並びの中で let された名前で、重複の検出に使う
+func (a *Analysis) statements(statements []ast.Statement, s *Scope, seen map[string]bool) {
+	for _, stmt := range statements {
+		if broken(stmt) {
+			continue
+		}
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			a.let(stmt, s, seen)
+		case *ast.ExportStatement:
+			a.let(stmt.Let, s, seen)
+		case *ast.ImportStatement:
+			a.Identifiers = append(a.Identifiers, stmt.Names...)
+		case *ast.ReturnStatement:
+			a.expression(stmt.ReturnValue, s)
+		case *ast.ExpressionStatement:
+			a.expression(stmt.Expression, s)
+		case *ast.BlockStatement:
+			a.statements(stmt.Statements, s, make(map[string]bool))
+		}
+	}
+}
+
+func (a *Analysis) let(stmt *ast.LetStatement, s *Scope, seen map[string]bool) {
+	// 値の中の参照はまだこの let の名前を指さない
+	a.expression(stmt.Value, s)
+
+	name := stmt.Name
+	if seen[name.Value] {
+		a.Duplicates[name] = true
+	}
+	seen[name.Value] = true
+
+	b := s.names[name.Value]
+	b.latest = name
+	a.Identifiers = append(a.Identifiers, name)
+	a.References[name] = Reference{Scope: s, Binding: b}
+}
+
+func (a *Analysis) expression(exp ast.Expression, s *Scope) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		a.identifier(exp, s)
+	case *ast.PrefixExpression:
+		a.expression(exp.Right, s)
+	case *ast.InfixExpression:
+		a.expression(exp.Left, s)
+		a.expression(exp.Right, s)
+	case *ast.IfExpression:
+		a.expression(exp.Condition, s)
+		if exp.Consequence != nil {
+			a.statements(exp.Consequence.Statements, s, make(map[string]bool))
+		}
+		if exp.Alternative != nil {
+			a.statements(exp.Alternative.Statements, s, make(map[string]bool))
+		}
+	case *ast.FunctionLiteral:
+		a.function(exp, exp.Parameters, exp.Body, s)
+	case *ast.MacroLiteral:
+		a.function(exp, exp.Parameters, exp.Body, s)
+	case *ast.CallExpression:
+		if exp.Function.TokenLiteral() == "quote" {
+			// quote の中はデータなので、評価される unquote の引数だけを解決する
+			for _, arg := range exp.Arguments {
+				a.unquoteCalls(arg, s)
+			}
+			return
+		}
+		a.expression(exp.Function, s)
+		for _, arg := range exp.Arguments {
+			a.expression(arg, s)
+		}
+	case *ast.ArrayLiteral:
+		for _, e := range exp.Elements {
+			a.expression(e, s)
+		}
+	case *ast.IndexExpression:
+		a.expression(exp.Left, s)
+		a.expression(exp.Index, s)
+	case *ast.SliceExpression:
+		a.expression(exp.Left, s)
+		if exp.Low != nil {
+			a.expression(exp.Low, s)
+		}
+		if exp.High != nil {
+			a.expression(exp.High, s)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			a.expression(pair.Key, s)
+			a.expression(pair.Value, s)
+		}
+	}
+}
+
+func (a *Analysis) function(fn ast.Expression, params []*ast.Identifier, body *ast.BlockStatement, outer *Scope) {
+	s := newScope(fn, outer)
+	a.Functions[fn] = s
+	seen := make(map[string]bool)
+	for i, param := range params {
+		if seen[param.Value] {
+			a.Duplicates[param] = true
+		}
+		seen[param.Value] = true
+		s.declare(param).latest = param
+		a.Params[param] = Parameter{Function: fn, Index: i}
+		a.Identifiers = append(a.Identifiers, param)
+		a.References[param] = Reference{Scope: s, Binding: s.names[param.Value]}
+	}
+	if body == nil {
+		return
+	}
+	a.declareLets(s, body.Statements)
+	a.statements(body.Statements, s, seen)
+}
+
+// identifier は ident を内側のスコープから探す。参照と同じスコープでは let が済んだ名前だけを、
+// 外側のスコープでは後ろで定義される名前も見る。外側の関数はふつう、定義が済んでから呼ばれるため
+func (a *Analysis) identifier(ident *ast.Identifier, s *Scope) {
+	a.Identifiers = append(a.Identifiers, ident)
+	ref := Reference{Scope: s}
+	for scope := s; scope != nil; scope = scope.Outer {
+		b, ok := scope.names[ident.Value]
+		if !ok {
+			continue
+		}
+		if scope == s && b.latest == nil {
+			ref.Pending = true
+			continue
+		}
+		ref.Binding = b
+		ref.Pending = false
+		a.Definitions[ident] = definition(b, ident, scope == s)
+		break
+	}
+	a.References[ident] = ref
+}
+
+// definition は ref が指す b の宣言を選ぶ。ref と同じスコープでは解析し終えた最後の宣言を選ぶ。
+// 外側のスコープでは ref より前の最後の宣言を選び、前になければ、後から定義される名前を
+// 関数の中から参照しているとみなして最初の宣言を選ぶ
+func definition(b *Binding, ref *ast.Identifier, local bool) *ast.Identifier {
+	if local {
+		return b.latest
+	}
+	found := b.Declarations[0]
+	for _, decl := range b.Declarations {
+		if before(decl.Token.Pos, ref.Token.Pos) {
+			found = decl
+		}
+	}
+	return found
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
+
+// unquoteCalls は quote された node の中から unquote と unquote_splice の呼び出しを探して解決する
+func (a *Analysis) unquoteCalls(node ast.Node, s *Scope) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		if name := call.Function.TokenLiteral(); name != "unquote" && name != "unquote_splice" {
+			return true
+		}
+		for _, arg := range call.Arguments {
+			a.expression(arg, s)
+		}
+		return false
+	})
+}
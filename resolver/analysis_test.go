@@ -0,0 +1,87 @@
+package resolver
+
+import (
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/parser"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestAnalyzeDefinitions(t *testing.T) {
+	tests := []struct {
+		input string
+		// expected は参照の位置から、指す宣言の位置を引く
+		expected map[string]string
+	}{
+		{
+			input:    `let x = 1; let x = x + 1; x`,
+			expected: map[string]string{"1:20": "1:5", "1:27": "1:16"},
+		},
+		{
+			// let より前の参照は外側の束縛を指す
+			input:    `let x = 1; fn() { let y = x; let x = 2; x }`,
+			expected: map[string]string{"1:27": "1:5", "1:41": "1:34"},
+		},
+		{
+			// 外側のスコープでは後ろで定義される名前も見る
+			input:    `let f = fn() { g() }; let g = fn(a) { a };`,
+			expected: map[string]string{"1:16": "1:27", "1:39": "1:34"},
+		},
+		{
+			// if の中の let も関数のスコープに束縛する
+			input:    `fn() { fn() { y }; if (true) { let y = 1; } }`,
+			expected: map[string]string{"1:15": "1:36"},
+		},
+		{
+			// quote の中は unquote の引数だけを解決する
+			input:    `let x = 1; quote(x + unquote(x))`,
+			expected: map[string]string{"1:30": "1:5"},
+		},
+		{
+			// 構文解析に失敗した文は飛ばす
+			input:    `let x = 1; let = 2; x`,
+			expected: map[string]string{"1:21": "1:5"},
+		},
+	}
+
+	for _, tt := range tests {
+		a := Analyze(parser.New(lexer.New(tt.input)).ParseProgram())
+		definitions := make(map[string]string)
+		for ref, decl := range a.Definitions {
+			definitions[ref.Token.Pos.String()] = decl.Token.Pos.String()
+		}
+		if !cmp.Equal(definitions, tt.expected) {
+			t.Errorf("case: %s. diff %s[-got, +expected]", tt.input, cmp.Diff(definitions, tt.expected))
+		}
+	}
+}
+
+func TestAnalyzeBindings(t *testing.T) {
+	a := Analyze(parser.New(lexer.New(`let f = fn(a, b) { let c = a; let a = 2; c };`)).ParseProgram())
+
+	var fn *Scope
+	for _, s := range a.Functions {
+		fn = s
+	}
+	if fn == nil || fn.Outer != a.Global {
+		t.Fatalf("function scope not found: %+v", a.Functions)
+	}
+
+	// 引数と let は同じスコープの束縛で、同じ名前は同じスロットを使う
+	names := make([]string, len(fn.Bindings))
+	for i, b := range fn.Bindings {
+		names[i] = b.Name
+		if b.Slot != i {
+			t.Errorf("%s: slot wrong. expected=%d, got=%d", b.Name, i, b.Slot)
+		}
+	}
+	if !cmp.Equal(names, []string{"a", "b", "c"}) {
+		t.Errorf("bindings wrong. got=%v", names)
+	}
+	if got := len(fn.Bindings[0].Declarations); got != 2 {
+		t.Errorf("a: declarations wrong. expected=2, got=%d", got)
+	}
+	if b := fn.Lookup("f"); b == nil || b.Scope != a.Global {
+		t.Errorf("f: lookup wrong. got=%+v", b)
+	}
+}
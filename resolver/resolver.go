@@ -0,0 +1,92 @@
+package resolver
+
+import (
+	"fmt"
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/token"
+)
+
+// Resolver は評価の前に識別子の参照先を決める。
+// 関数のローカル変数には ast.Resolution を割り当て、評価器が名前を使わずに引けるようにする。
+// トップレベルの変数は REPL やモジュールから後で増えるので、名前で引くまま残す
+type Resolver struct {
+	// defined はプログラムの外で定義済みの名前 (組み込み関数や以前の入力など) かどうかを返す
+	defined func(name string) bool
+	// LateBound が true なら、関数の中から参照した未定義の名前を報告しない。
+	// REPL のように、後の入力で定義されるトップレベルの変数を呼び出しのときに名前で引く場合に使う
+	LateBound bool
+	// wildcard は名前を列挙しない import があったかどうか。あれば未定義の名前を報告できない
+	wildcard bool
+	errors   []string
+}
+
+func New(defined func(name string) bool) *Resolver {
+	return &Resolver{defined: defined, errors: []string{}}
+}
+
+func (r *Resolver) Errors() []string {
+	return r.errors
+}
+
+func (r *Resolver) errorf(pos token.Position, format string, a ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf("%s: %s", pos, fmt.Sprintf(format, a...)))
+}
+
+// Resolve は program の識別子を解決する。見つかった問題は Errors で取り出せる
+func (r *Resolver) Resolve(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		if stmt, ok := stmt.(*ast.ImportStatement); ok && stmt.Names == nil {
+			r.wildcard = true
+		}
+	}
+
+	a := Analyze(program)
+	for _, ident := range a.Identifiers {
+		ref := a.References[ident]
+		// マクロの本体は展開のときに名前で評価するので、解決しない
+		if inMacro(ref.Scope) {
+			continue
+		}
+		if a.Duplicates[ident] {
+			if _, ok := a.Params[ident]; ok {
+				r.errorf(ident.Token.Pos, "duplicate parameter: %s", ident.Value)
+			} else {
+				r.errorf(ident.Token.Pos, "duplicate let: %s", ident.Value)
+			}
+		}
+		r.resolveIdentifier(ident, ref)
+	}
+}
+
+func inMacro(s *Scope) bool {
+	for ; s != nil; s = s.Outer {
+		if _, ok := s.Function.(*ast.MacroLiteral); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveIdentifier は関数のローカル変数を指す ident に位置を割り当てる。束縛が見つからなければ報告する
+func (r *Resolver) resolveIdentifier(ident *ast.Identifier, ref Reference) {
+	// 以前に解決した結果が残っていても、名前で引く変数なら取り除く
+	ident.Resolved = nil
+	if ref.Binding != nil {
+		if !ref.Binding.Scope.IsGlobal() {
+			ident.Resolved = &ast.Resolution{Depth: ref.Depth(), Slot: ref.Binding.Slot}
+		}
+		return
+	}
+
+	if ident.Value == "quote" || ident.Value == "unquote" || ident.Value == "unquote_splice" {
+		return
+	}
+	if r.wildcard || (r.defined != nil && r.defined(ident.Value)) || (r.LateBound && !ref.Scope.IsGlobal()) {
+		return
+	}
+	if ref.Pending {
+		r.errorf(ident.Token.Pos, "used before definition: %s", ident.Value)
+		return
+	}
+	r.errorf(ident.Token.Pos, "identifier not found: %s", ident.Value)
+}
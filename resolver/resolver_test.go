@@ -0,0 +1,203 @@
+package resolver
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/parser"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func resolve(t *testing.T, input string) (*ast.Program, []string) {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	r := New(func(name string) bool { return name == "len" })
+	r.Resolve(program)
+	return program, r.Errors()
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    `let a = 1; let f = fn(x) { x + a + len(b) }; let b = 2;`,
+			expected: []string{},
+		},
+		{
+			input:    `let f = fn() { g() }; let g = fn() { f() };`,
+			expected: []string{},
+		},
+		{
+			input:    `foo + 1`,
+			expected: []string{"1:1: identifier not found: foo"},
+		},
+		{
+			input:    `let f = fn(x) { x + y };`,
+			expected: []string{"1:21: identifier not found: y"},
+		},
+		{
+			input:    `let f = fn(x) { let y = 1; y }; y`,
+			expected: []string{"1:33: identifier not found: y"},
+		},
+		{
+			input:    `let x = 1; let f = fn() { let x = x + 1; x };`,
+			expected: []string{},
+		},
+		{
+			input:    `let f = fn() { let y = x; let x = 1; y };`,
+			expected: []string{"1:24: used before definition: x"},
+		},
+		{
+			input:    `let y = x; let x = 1;`,
+			expected: []string{"1:9: used before definition: x"},
+		},
+		{
+			input:    `let f = fn() { let g = fn() { x }; let x = 1; g() };`,
+			expected: []string{},
+		},
+		{
+			input:    `let a = 1; let a = 2;`,
+			expected: []string{"1:16: duplicate let: a"},
+		},
+		{
+			input:    `fn(x) { let x = 1; }`,
+			expected: []string{"1:13: duplicate let: x"},
+		},
+		{
+			input:    `fn(x, x) { x }`,
+			expected: []string{"1:7: duplicate parameter: x"},
+		},
+		{
+			input:    `fn(x) { if (x) { let y = 1; y } else { let y = 2; y } }`,
+			expected: []string{},
+		},
+		{
+			input:    `quote(foo + unquote(1 + 2))`,
+			expected: []string{},
+		},
+		{
+			input:    `quote(unquote(foo))`,
+			expected: []string{"1:15: identifier not found: foo"},
+		},
+		{
+			input:    `quote(f(unquote_splice(foo)))`,
+			expected: []string{"1:24: identifier not found: foo"},
+		},
+		{
+			input:    `import { a } from "lib"; a + b`,
+			expected: []string{"1:30: identifier not found: b"},
+		},
+		{
+			input:    `import "lib"; a + b`,
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		_, errors := resolve(t, tt.input)
+		if !cmp.Equal(errors, tt.expected) {
+			t.Errorf("case: %s. diff %s[-got, +expected]", tt.input, cmp.Diff(errors, tt.expected))
+		}
+	}
+}
+
+func TestResolution(t *testing.T) {
+	input := `
+let g = 1;
+let f = fn(a, b) {
+  let c = a;
+  fn(d) { a + c + d + g }
+};
+`
+	program, errors := resolve(t, input)
+	if len(errors) != 0 {
+		t.Fatalf("resolver errors: %v", errors)
+	}
+
+	let := program.Statements[1].(*ast.LetStatement)
+	if let.Name.Resolved != nil {
+		t.Errorf("global %s has resolution %+v", let.Name.Value, let.Name.Resolved)
+	}
+
+	outer := let.Value.(*ast.FunctionLiteral)
+	inner := outer.Body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	body := inner.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+
+	// この言語の + は右結合なので a + (c + (d + g)) と解析される
+	rest := body.Right.(*ast.InfixExpression)
+	last := rest.Right.(*ast.InfixExpression)
+	tests := []struct {
+		ident    *ast.Identifier
+		expected *ast.Resolution
+	}{
+		{ident: outer.Parameters[0], expected: &ast.Resolution{Depth: 0, Slot: 0}},
+		{ident: outer.Parameters[1], expected: &ast.Resolution{Depth: 0, Slot: 1}},
+		{ident: outer.Body.Statements[0].(*ast.LetStatement).Name, expected: &ast.Resolution{Depth: 0, Slot: 2}},
+		{ident: inner.Parameters[0], expected: &ast.Resolution{Depth: 0, Slot: 0}},
+		{ident: body.Left.(*ast.Identifier), expected: &ast.Resolution{Depth: 1, Slot: 0}},
+		{ident: rest.Left.(*ast.Identifier), expected: &ast.Resolution{Depth: 1, Slot: 2}},
+		{ident: last.Left.(*ast.Identifier), expected: &ast.Resolution{Depth: 0, Slot: 0}},
+		{ident: last.Right.(*ast.Identifier), expected: nil},
+	}
+
+	for _, tt := range tests {
+		if !cmp.Equal(tt.ident.Resolved, tt.expected) {
+			t.Errorf("%s: diff %s[-got, +expected]", tt.ident.Value, cmp.Diff(tt.ident.Resolved, tt.expected))
+		}
+	}
+}
+
+func TestResolutionBeforeLet(t *testing.T) {
+	program, errors := resolve(t, `fn(x) { fn() { let x = x + 1; x } }`)
+	if len(errors) != 0 {
+		t.Fatalf("resolver errors: %v", errors)
+	}
+
+	outer := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	inner := outer.Body.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	let := inner.Body.Statements[0].(*ast.LetStatement)
+	tests := []struct {
+		ident    *ast.Identifier
+		expected *ast.Resolution
+	}{
+		// let の右辺ではまだ内側の x は定義されていない
+		{ident: let.Value.(*ast.InfixExpression).Left.(*ast.Identifier), expected: &ast.Resolution{Depth: 1, Slot: 0}},
+		{ident: let.Name, expected: &ast.Resolution{Depth: 0, Slot: 0}},
+		{ident: inner.Body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.Identifier), expected: &ast.Resolution{Depth: 0, Slot: 0}},
+	}
+
+	for _, tt := range tests {
+		if !cmp.Equal(tt.ident.Resolved, tt.expected) {
+			t.Errorf("%s: diff %s[-got, +expected]", tt.ident.Value, cmp.Diff(tt.ident.Resolved, tt.expected))
+		}
+	}
+}
+
+func TestResolveLateBound(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let f = fn() { g() };`, []string{}},
+		{`let f = fn() { fn() { g } };`, []string{}},
+		// トップレベルからの参照はすぐに評価されるので報告する
+		{`g()`, []string{"1:1: identifier not found: g"}},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		r := New(nil)
+		r.LateBound = true
+		r.Resolve(program)
+		if !cmp.Equal(r.Errors(), tt.expected) {
+			t.Errorf("case: %s. diff %s[-got, +expected]", tt.input, cmp.Diff(r.Errors(), tt.expected))
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package token
 
+import "fmt"
+
 type Type string
 
 var keywords = map[string]Type{
@@ -11,6 +13,8 @@ var keywords = map[string]Type{
 	"else":   ELSE,
 	"return": RETURN,
 	"macro":  MACRO,
+	"import": IMPORT,
+	"export": EXPORT,
 }
 
 func LookupIdent(ident string) Type {
@@ -42,6 +46,9 @@ const (
 	LT     = "<"
 	GT     = ">"
 
+	// 型の注釈で戻り値の型の前に書く
+	ARROW = "->"
+
 	// デリミタ
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -63,13 +70,35 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	MACRO    = "MACRO"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
 )
 
 type Token struct {
-	Type    Type
-	Literal string
+	Type    Type     `json:"type"`
+	Literal string   `json:"literal"`
+	Pos     Position `json:"pos"`
 }
 
 func NewToken(tokenType Type, ch byte) Token {
 	return Token{Type: tokenType, Literal: string(ch)}
 }
+
+// Position はソース上の位置で、行・列ともに1始まり
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// IsValid は位置が記録されているかを返す。手で組み立てたトークンは位置を持たない
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Comment は // から行末までのコメント。Text は // を含む
+type Comment struct {
+	Pos  Position
+	Text string
+}
@@ -0,0 +1,176 @@
+package typecheck
+
+import (
+	"sort"
+	"strings"
+)
+
+// signature は組み込み関数の型。Params のうち先頭の Required 個は必須で、
+// Rest が nil でなければ残りの引数はすべて Rest の型になる
+type signature struct {
+	Params   []Type
+	Required int
+	Rest     Type
+	Return   Type
+}
+
+// builtinSignatures は組み込み関数の型を、型変数を新しく作って返す。
+// 配列と文字列のどちらも受け取る引数には seq を、いくつかの型のどれかを受け取る引数には oneOf を使う
+var builtinSignatures = map[string]func(fresh func() Type) signature{
+	"len": func(fresh func() Type) signature {
+		return fixed(Int, &oneOf{[]Type{&Array{fresh()}, String, &Hash{fresh(), fresh()}}})
+	},
+	"first": func(fresh func() Type) signature { a := fresh(); return fixed(a, &seq{a}) },
+	"last":  func(fresh func() Type) signature { a := fresh(); return fixed(a, &seq{a}) },
+	// 配列なら配列を、文字列なら文字列を返す
+	"rest": func(fresh func() Type) signature { a := &seq{fresh()}; return fixed(a, a) },
+	"push": func(fresh func() Type) signature {
+		a := fresh()
+		return fixed(&Array{a}, &Array{a}, a)
+	},
+	"puts": func(fresh func() Type) signature { return signature{Rest: Any, Return: Null} },
+
+	"map": func(fresh func() Type) signature {
+		a, b := fresh(), fresh()
+		return fixed(&Array{b}, &seq{a}, fn(b, a))
+	},
+	"filter": func(fresh func() Type) signature {
+		a := fresh()
+		return fixed(&Array{a}, &seq{a}, fn(fresh(), a))
+	},
+	"reduce": func(fresh func() Type) signature {
+		a, b := fresh(), fresh()
+		return fixed(b, &seq{a}, b, fn(b, b, a))
+	},
+	"each": func(fresh func() Type) signature {
+		a := fresh()
+		return fixed(Null, &seq{a}, fn(fresh(), a))
+	},
+	"find": func(fresh func() Type) signature {
+		a := fresh()
+		return fixed(a, &seq{a}, fn(fresh(), a))
+	},
+	"any": func(fresh func() Type) signature {
+		a := fresh()
+		return fixed(Bool, &seq{a}, fn(fresh(), a))
+	},
+	"all": func(fresh func() Type) signature {
+		a := fresh()
+		return fixed(Bool, &seq{a}, fn(fresh(), a))
+	},
+	"sort": func(fresh func() Type) signature {
+		a := fresh()
+		return optional(&Array{a}, 1, &Array{a}, fn(fresh(), a, a))
+	},
+	"reverse": func(fresh func() Type) signature { a := fresh(); return fixed(&Array{a}, &Array{a}) },
+	// zip は要素の型が違う配列を組にするので、組の要素の型は分からない
+	"zip": func(fresh func() Type) signature {
+		return signature{Params: []Type{&Array{fresh()}}, Required: 1, Rest: &Array{Any}, Return: &Array{&Array{Any}}}
+	},
+	"range":   func(fresh func() Type) signature { return optional(&Array{Int}, 1, Int, Int, Int) },
+	"flatten": func(fresh func() Type) signature { return fixed(&Array{Any}, &Array{fresh()}) },
+	"uniq":    func(fresh func() Type) signature { a := fresh(); return fixed(&Array{a}, &Array{a}) },
+
+	"keys": func(fresh func() Type) signature {
+		k, v := fresh(), fresh()
+		return fixed(&Array{k}, &Hash{k, v})
+	},
+	"values": func(fresh func() Type) signature {
+		k, v := fresh(), fresh()
+		return fixed(&Array{v}, &Hash{k, v})
+	},
+	"entries": func(fresh func() Type) signature {
+		return fixed(&Array{&Array{Any}}, &Hash{fresh(), fresh()})
+	},
+	"has_key": func(fresh func() Type) signature {
+		k, v := fresh(), fresh()
+		return fixed(Bool, &Hash{k, v}, k)
+	},
+	"delete": func(fresh func() Type) signature {
+		k, v := fresh(), fresh()
+		return fixed(&Hash{k, v}, &Hash{k, v}, k)
+	},
+	"put": func(fresh func() Type) signature {
+		k, v := fresh(), fresh()
+		return fixed(&Hash{k, v}, &Hash{k, v}, k, v)
+	},
+	"merge": func(fresh func() Type) signature {
+		k, v := fresh(), fresh()
+		return signature{Params: []Type{&Hash{k, v}}, Required: 1, Rest: &Hash{k, v}, Return: &Hash{k, v}}
+	},
+
+	"split":       func(fresh func() Type) signature { return fixed(&Array{String}, String, String) },
+	"join":        func(fresh func() Type) signature { return fixed(String, &Array{String}, String) },
+	"trim":        func(fresh func() Type) signature { return optional(String, 1, String, String) },
+	"upper":       func(fresh func() Type) signature { return fixed(String, String) },
+	"lower":       func(fresh func() Type) signature { return fixed(String, String) },
+	"replace":     func(fresh func() Type) signature { return fixed(String, String, String, String) },
+	"contains":    func(fresh func() Type) signature { return fixed(Bool, String, String) },
+	"starts_with": func(fresh func() Type) signature { return fixed(Bool, String, String) },
+	"ends_with":   func(fresh func() Type) signature { return fixed(Bool, String, String) },
+	"index_of":    func(fresh func() Type) signature { return fixed(Int, String, String) },
+	"substr":      func(fresh func() Type) signature { return optional(String, 2, String, Int, Int) },
+	"repeat":      func(fresh func() Type) signature { return fixed(String, String, Int) },
+	"chars":       func(fresh func() Type) signature { return fixed(&Array{String}, String) },
+	"format": func(fresh func() Type) signature {
+		return signature{Params: []Type{String}, Required: 1, Rest: Any, Return: String}
+	},
+	"to_int":    func(fresh func() Type) signature { return fixed(Int, &oneOf{[]Type{Int, String, Bool}}) },
+	"to_string": func(fresh func() Type) signature { return fixed(String, fresh()) },
+
+	"gensym":        func(fresh func() Type) signature { return optional(Quote, 0, String) },
+	"macroexpand":   func(fresh func() Type) signature { return fixed(Quote, Quote) },
+	"macroexpand_1": func(fresh func() Type) signature { return fixed(Quote, Quote) },
+	"node_kind":     func(fresh func() Type) signature { return fixed(String, Quote) },
+	"node_field":    func(fresh func() Type) signature { return fixed(Any, Quote, String) },
+	"make_node":     func(fresh func() Type) signature { return fixed(Quote, String, &Hash{String, Any}) },
+}
+
+// fixed は引数の個数が決まっている組み込み関数の型
+func fixed(ret Type, params ...Type) signature {
+	return signature{Params: params, Required: len(params), Return: ret}
+}
+
+// optional は後ろの引数を省略できる組み込み関数の型
+func optional(ret Type, required int, params ...Type) signature {
+	return signature{Params: params, Required: required, Return: ret}
+}
+
+func fn(ret Type, params ...Type) *Function {
+	return &Function{Params: params, Return: ret}
+}
+
+// BuiltinNames は型の分かる組み込み関数の名前を辞書順に返す
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtinSignatures))
+	for name := range builtinSignatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BuiltinType は組み込み関数の型を fn(string, int?) -> string のような形で返す。
+// 省略できる引数には ? を、いくつでも渡せる引数には ... をつける
+func BuiltinType(name string) (string, bool) {
+	newSignature, ok := builtinSignatures[name]
+	if !ok {
+		return "", false
+	}
+	c := &checker{}
+	sig := newSignature(c.fresh)
+
+	p := &printer{names: make(map[*Variable]string)}
+	var params []string
+	for i, param := range sig.Params {
+		s := p.print(param)
+		if i >= sig.Required {
+			s += "?"
+		}
+		params = append(params, s)
+	}
+	if sig.Rest != nil {
+		params = append(params, "..."+p.print(sig.Rest))
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + p.print(sig.Return), true
+}
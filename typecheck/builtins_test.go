@@ -0,0 +1,51 @@
+package typecheck
+
+import (
+	"github.com/care0717/monkey-interpreter/evaluator"
+	"testing"
+)
+
+// 組み込み関数の型の引数の個数が、評価器の builtin と揃っているかを確かめる
+func TestBuiltinSignaturesMatchArity(t *testing.T) {
+	c := &checker{}
+	for name, newSignature := range builtinSignatures {
+		arity, ok := evaluator.BuiltinArity(name)
+		if !ok {
+			t.Errorf("%s: not a builtin", name)
+			continue
+		}
+		sig := newSignature(c.fresh)
+		max := len(sig.Params)
+		if sig.Rest != nil {
+			max = -1
+		}
+		if arity.Min != sig.Required || arity.Max != max {
+			t.Errorf("%s: arity mismatch. builtin=%d..%d, signature=%d..%d", name, arity.Min, arity.Max, sig.Required, max)
+		}
+	}
+}
+
+func TestBuiltinType(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"len", "fn([a] | string | {b: c}) -> int"},
+		{"rest", "fn([a] | string) -> [a] | string"},
+		{"to_int", "fn(int | string | bool) -> int"},
+		{"first", "fn([a] | string) -> a"},
+		{"map", "fn([a] | string, fn(a) -> b) -> [b]"},
+		{"substr", "fn(string, int, int?) -> string"},
+		{"puts", "fn(...any) -> null"},
+		{"merge", "fn({a: b}, ...{a: b}) -> {a: b}"},
+	}
+	for _, tt := range tests {
+		got, ok := BuiltinType(tt.name)
+		if !ok || got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.name, tt.expected, got)
+		}
+	}
+	if _, ok := BuiltinType("nothing"); ok {
+		t.Errorf("expected no type for an unknown name")
+	}
+}
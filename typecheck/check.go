@@ -0,0 +1,806 @@
+package typecheck
+
+import (
+	"fmt"
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/resolver"
+	"github.com/care0717/monkey-interpreter/token"
+)
+
+// Error は検査器が見つけたひとつの型の誤り
+type Error struct {
+	File string `json:"file,omitempty"`
+	token.Position
+	Message string `json:"message"`
+}
+
+func (e Error) String() string {
+	if e.File == "" {
+		return fmt.Sprintf("%s: %s", e.Position, e.Message)
+	}
+	return fmt.Sprintf("%s:%s: %s", e.File, e.Position, e.Message)
+}
+
+// Binding はトップレベルの let で束縛された名前と、推論した型
+type Binding struct {
+	token.Position
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (b Binding) String() string {
+	return fmt.Sprintf("%s: %s", b.Name, b.Type)
+}
+
+// scheme は let で束縛した値の型。Vars の型変数は参照のたびに新しい型変数に置き換える
+type scheme struct {
+	vars []*Variable
+	t    Type
+}
+
+type checker struct {
+	analysis *resolver.Analysis
+	// schemes は束縛の型。同じスコープで let し直すと置き換える
+	schemes map[*resolver.Binding]*scheme
+	vars    int
+	// trail は単一化で決めた型変数。単一化に失敗したら、そこまでの決定を取り消す
+	trail []*Variable
+	// ret は検査中の関数の戻り値の型。トップレベルでは nil
+	ret Type
+	// bindings はトップレベルの let の型。後の文で決まる型変数もあるので、最後に文字列にする
+	bindings []binding
+	errors   []Error
+}
+
+type binding struct {
+	ident  *ast.Identifier
+	scheme *scheme
+}
+
+// Check は program を評価せずに型を推論し、トップレベルの let の型と見つかった誤りを返す。
+// 要素の型が揃わない配列やハッシュ、条件によって型の違う if、定義の見えない名前は any として扱い、誤りにしない。
+// マクロを展開した後の program に使う
+func Check(program *ast.Program) ([]Binding, []Error) {
+	c := &checker{analysis: resolver.Analyze(program), schemes: make(map[*resolver.Binding]*scheme)}
+	s := c.analysis.Global
+	c.declareLets(s)
+	for _, stmt := range program.Statements {
+		c.statement(stmt, s, true)
+	}
+
+	bindings := make([]Binding, len(c.bindings))
+	for i, b := range c.bindings {
+		bindings[i] = Binding{Position: b.ident.Token.Pos, Name: b.ident.Value, Type: TypeString(b.scheme.t)}
+	}
+	return bindings, c.errors
+}
+
+func (c *checker) errorf(pos token.Position, format string, a ...interface{}) {
+	c.errors = append(c.errors, Error{Position: pos, Message: fmt.Sprintf(format, a...)})
+}
+
+// typeErrorf は errorf と同じだが、引数の型はひとつの誤りの中で型変数の名前を揃えて文字列にする
+func (c *checker) typeErrorf(pos token.Position, format string, a ...interface{}) {
+	p := &printer{names: make(map[*Variable]string)}
+	for i, arg := range a {
+		if t, ok := arg.(Type); ok {
+			a[i] = p.print(t)
+		}
+	}
+	c.errorf(pos, format, a...)
+}
+
+func (c *checker) fresh() Type {
+	c.vars++
+	return &Variable{id: c.vars}
+}
+
+// unify は a と b を同じ型にする。できなければ途中で決めた型変数を元に戻して false を返す
+func (c *checker) unify(a, b Type) bool {
+	mark := len(c.trail)
+	if c.unifyRec(a, b) {
+		return true
+	}
+	for _, v := range c.trail[mark:] {
+		v.Instance = nil
+	}
+	c.trail = c.trail[:mark]
+	return false
+}
+
+func (c *checker) unifyRec(a, b Type) bool {
+	a, b = prune(a), prune(b)
+	if a == Any || b == Any || a == b {
+		return true
+	}
+	if v, ok := a.(*Variable); ok {
+		return c.bind(v, b)
+	}
+	if v, ok := b.(*Variable); ok {
+		return c.bind(v, a)
+	}
+
+	switch a := a.(type) {
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && c.unifyRec(a.Elem, b.Elem)
+	case *Hash:
+		b, ok := b.(*Hash)
+		return ok && c.unifyRec(a.Key, b.Key) && c.unifyRec(a.Value, b.Value)
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok || len(a.Params) != len(b.Params) {
+			return false
+		}
+		for i := range a.Params {
+			if !c.unifyRec(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+		return c.unifyRec(a.Return, b.Return)
+	}
+	return false
+}
+
+func (c *checker) bind(v *Variable, t Type) bool {
+	if occursIn(v, t) {
+		return false
+	}
+	v.Instance = t
+	c.trail = append(c.trail, v)
+	return true
+}
+
+// generalize は t の型変数のうち、s から見える名前の型に現れないものを参照ごとに変えられるようにする
+func (c *checker) generalize(t Type, s *resolver.Scope) *scheme {
+	inScope := make(map[*Variable]bool)
+	for ; s != nil; s = s.Outer {
+		for _, b := range s.Bindings {
+			bound, ok := c.schemes[b]
+			if !ok {
+				continue
+			}
+			free := make(map[*Variable]bool)
+			for _, v := range bound.vars {
+				free[v] = false
+			}
+			collectVariables(bound.t, func(v *Variable) {
+				if _, quantified := free[v]; !quantified {
+					inScope[v] = true
+				}
+			})
+		}
+	}
+
+	var vars []*Variable
+	seen := make(map[*Variable]bool)
+	collectVariables(t, func(v *Variable) {
+		if !inScope[v] && !seen[v] {
+			seen[v] = true
+			vars = append(vars, v)
+		}
+	})
+	return &scheme{vars: vars, t: t}
+}
+
+func collectVariables(t Type, f func(*Variable)) {
+	switch t := prune(t).(type) {
+	case *Variable:
+		f(t)
+	case *Array:
+		collectVariables(t.Elem, f)
+	case *Hash:
+		collectVariables(t.Key, f)
+		collectVariables(t.Value, f)
+	case *Function:
+		for _, param := range t.Params {
+			collectVariables(param, f)
+		}
+		collectVariables(t.Return, f)
+	case *seq:
+		collectVariables(t.Elem, f)
+	case *oneOf:
+		for _, alt := range t.Types {
+			collectVariables(alt, f)
+		}
+	}
+}
+
+// instantiate は sc の型の、参照ごとに変えられる型変数を新しい型変数にした型を返す
+func (c *checker) instantiate(sc *scheme) Type {
+	if len(sc.vars) == 0 {
+		return sc.t
+	}
+	mapping := make(map[*Variable]Type, len(sc.vars))
+	for _, v := range sc.vars {
+		mapping[v] = c.fresh()
+	}
+	return substitute(sc.t, mapping)
+}
+
+func substitute(t Type, mapping map[*Variable]Type) Type {
+	switch t := prune(t).(type) {
+	case *Variable:
+		if replaced, ok := mapping[t]; ok {
+			return replaced
+		}
+		return t
+	case *Array:
+		return &Array{Elem: substitute(t.Elem, mapping)}
+	case *Hash:
+		return &Hash{Key: substitute(t.Key, mapping), Value: substitute(t.Value, mapping)}
+	case *Function:
+		params := make([]Type, len(t.Params))
+		for i, param := range t.Params {
+			params[i] = substitute(param, mapping)
+		}
+		return &Function{Params: params, Return: substitute(t.Return, mapping)}
+	default:
+		return t
+	}
+}
+
+// declareLets は s で一度だけ let される名前に型変数を割り当てる。
+// これで定義より前に書かれた関数の本体や、再帰呼び出しからも型が分かる
+func (c *checker) declareLets(s *resolver.Scope) {
+	for _, b := range s.Bindings {
+		if len(b.Declarations) != 1 {
+			continue
+		}
+		let, ok := c.analysis.Lets[b.Declarations[0]]
+		if !ok {
+			continue
+		}
+		if let.Type != nil {
+			c.schemes[b] = &scheme{t: fromAnnotation(let.Type)}
+		} else {
+			c.schemes[b] = &scheme{t: c.fresh()}
+		}
+	}
+}
+
+// binding は宣言か参照の識別子が指す束縛を返す。束縛がなければ nil
+func (c *checker) binding(ident *ast.Identifier) *resolver.Binding {
+	return c.analysis.References[ident].Binding
+}
+
+// statement は文を検査し、文の値の型を返す
+func (c *checker) statement(stmt ast.Statement, s *resolver.Scope, topLevel bool) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(stmt, s, topLevel)
+		return Null
+	case *ast.ExportStatement:
+		c.let(stmt.Let, s, topLevel)
+		return Null
+	case *ast.ImportStatement:
+		for _, name := range stmt.Names {
+			c.schemes[c.binding(name)] = &scheme{t: Any}
+		}
+		return Null
+	case *ast.ReturnStatement:
+		t := c.expression(stmt.ReturnValue, s)
+		if c.ret != nil && !c.unify(c.ret, t) {
+			c.typeErrorf(startPos(stmt.ReturnValue), "cannot use %s as return value of type %s", t, c.ret)
+		}
+		return t
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression, s)
+	}
+	return Any
+}
+
+func (c *checker) let(stmt *ast.LetStatement, s *resolver.Scope, topLevel bool) {
+	name := stmt.Name.Value
+	var t Type = Any
+	if _, ok := stmt.Value.(*ast.MacroLiteral); !ok {
+		t = c.expression(stmt.Value, s)
+	}
+	// 注釈があれば、推論した型ではなく注釈の型で束縛する
+	if stmt.Type != nil {
+		annotated := fromAnnotation(stmt.Type)
+		if !c.unify(annotated, t) {
+			c.typeErrorf(stmt.Name.Token.Pos, "cannot use %s as %s in let %s", t, annotated, name)
+		}
+		t = annotated
+	}
+
+	// 先に割り当てた型変数と推論した型を揃えてから、自分自身を除いたスコープで一般化する
+	b := c.binding(stmt.Name)
+	if declared, ok := c.schemes[b]; ok && len(declared.vars) == 0 {
+		c.unify(declared.t, t)
+	}
+	delete(c.schemes, b)
+	sc := c.generalize(t, s)
+	c.schemes[b] = sc
+
+	if topLevel {
+		c.bindings = append(c.bindings, binding{ident: stmt.Name, scheme: sc})
+	}
+}
+
+// block は文を順に検査し、最後の文の型をブロックの値の型として返す
+func (c *checker) block(block *ast.BlockStatement, s *resolver.Scope) Type {
+	if block == nil || len(block.Statements) == 0 {
+		return Null
+	}
+	var t Type
+	for _, stmt := range block.Statements {
+		t = c.statement(stmt, s, false)
+	}
+	return t
+}
+
+func (c *checker) expression(exp ast.Expression, s *resolver.Scope) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.Boolean:
+		return Bool
+	case *ast.StringLiteral:
+		return String
+	case *ast.Identifier:
+		return c.identifier(exp)
+	case *ast.PrefixExpression:
+		return c.prefix(exp, s)
+	case *ast.InfixExpression:
+		return c.infix(exp, s)
+	case *ast.IfExpression:
+		c.expression(exp.Condition, s)
+		consequence := c.block(exp.Consequence, s)
+		if exp.Alternative == nil {
+			return consequence
+		}
+		alternative := c.block(exp.Alternative, s)
+		if !c.unify(consequence, alternative) {
+			return Any
+		}
+		return consequence
+	case *ast.FunctionLiteral:
+		return c.function(exp, nil)
+	case *ast.CallExpression:
+		return c.call(exp, s)
+	case *ast.ArrayLiteral:
+		elem := c.fresh()
+		for _, e := range exp.Elements {
+			if !c.unify(elem, c.expression(e, s)) {
+				elem = Any
+			}
+		}
+		return &Array{Elem: elem}
+	case *ast.IndexExpression:
+		return c.index(exp, s)
+	case *ast.SliceExpression:
+		return c.slice(exp, s)
+	case *ast.HashLiteral:
+		return c.hash(exp, s)
+	}
+	return Any
+}
+
+// identifier は名前の型を返す。組み込み関数は値としても使える。定義の見えない名前は any にする
+func (c *checker) identifier(ident *ast.Identifier) Type {
+	if b := c.binding(ident); b != nil {
+		if sc, ok := c.schemes[b]; ok {
+			return c.instantiate(sc)
+		}
+		// 何度も let される名前を、定義より前に書かれた関数から参照している
+		return Any
+	}
+	if newSignature, ok := builtinSignatures[ident.Value]; ok {
+		sig := newSignature(c.fresh)
+		if sig.Rest != nil || sig.Required != len(sig.Params) {
+			return Any
+		}
+		// 値として使う組み込み関数は、配列と文字列のどちらも受け取る引数を配列に、
+		// いくつかの型のどれかを受け取る引数を any にする
+		params := make([]Type, len(sig.Params))
+		for i, param := range sig.Params {
+			switch p := param.(type) {
+			case *seq:
+				param = &Array{Elem: p.Elem}
+			case *oneOf:
+				param = Any
+			}
+			params[i] = param
+		}
+		ret := sig.Return
+		if sq, ok := ret.(*seq); ok {
+			ret = &Array{Elem: sq.Elem}
+		}
+		return &Function{Params: params, Return: ret}
+	}
+	return Any
+}
+
+func (c *checker) prefix(exp *ast.PrefixExpression, s *resolver.Scope) Type {
+	right := c.expression(exp.Right, s)
+	switch exp.Operator {
+	case "!":
+		return Bool
+	case "-":
+		if !c.unify(right, Int) {
+			c.errorf(exp.Token.Pos, "unknown operator: -%s", TypeString(right))
+			return Any
+		}
+		return Int
+	}
+	return Any
+}
+
+func (c *checker) infix(exp *ast.InfixExpression, s *resolver.Scope) Type {
+	left := c.expression(exp.Left, s)
+	right := c.expression(exp.Right, s)
+
+	switch exp.Operator {
+	case "+":
+		// int 同士なら足し算、string 同士なら連結
+		operand := prune(left)
+		if _, ok := operand.(*Variable); ok {
+			operand = prune(right)
+		}
+		switch operand {
+		case Int, String, Any:
+		default:
+			if _, ok := operand.(*Variable); !ok {
+				return c.operatorError(exp, left, right)
+			}
+		}
+		if !c.unify(left, right) || !c.unify(left, operand) {
+			return c.operatorError(exp, left, right)
+		}
+		if prune(left) == Any {
+			return right
+		}
+		return left
+	case "-", "*", "/":
+		if !c.unify(left, Int) || !c.unify(right, Int) {
+			return c.operatorError(exp, left, right)
+		}
+		return Int
+	case "<", ">":
+		if !c.unify(left, Int) || !c.unify(right, Int) {
+			return c.operatorError(exp, left, right)
+		}
+		return Bool
+	case "==", "!=":
+		// 型の違う値は比べられるが、文字列同士は比べられない
+		if prune(left) == String && prune(right) == String {
+			return c.operatorError(exp, left, right)
+		}
+		return Bool
+	}
+	return Any
+}
+
+// operatorError は評価器と同じ言葉で演算子の誤りを報告する
+func (c *checker) operatorError(exp *ast.InfixExpression, left, right Type) Type {
+	if TypeString(left) == TypeString(right) {
+		c.typeErrorf(exp.Token.Pos, "unknown operator: %s %s %s", left, exp.Operator, right)
+	} else {
+		c.typeErrorf(exp.Token.Pos, "type mismatch: %s %s %s", left, exp.Operator, right)
+	}
+	return Any
+}
+
+// function は関数リテラルを検査する。引数として渡す関数なら、expected に受け取る側が期待する関数の型を渡す。
+// 引数の型を先に決めておくと、誤りを関数の本体の中の位置で報告できる
+func (c *checker) function(fl *ast.FunctionLiteral, expected *Function) Type {
+	s := c.analysis.Functions[fl]
+	params := make([]Type, len(fl.Parameters))
+	for i, param := range fl.Parameters {
+		if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+			params[i] = fromAnnotation(fl.ParameterTypes[i])
+		} else if expected != nil && len(expected.Params) == len(params) {
+			params[i] = expected.Params[i]
+		} else {
+			params[i] = c.fresh()
+		}
+		c.schemes[c.binding(param)] = &scheme{t: params[i]}
+	}
+	c.declareLets(s)
+
+	var ret Type
+	if fl.ReturnType != nil {
+		ret = fromAnnotation(fl.ReturnType)
+	} else {
+		ret = c.fresh()
+	}
+	saved := c.ret
+	c.ret = ret
+	body := c.block(fl.Body, s)
+	c.ret = saved
+
+	// return で抜けた場合はその値が、そうでなければ最後の文の値が戻り値になる
+	if !endsWithReturn(fl.Body) && !c.unify(ret, body) {
+		c.typeErrorf(lastPos(fl.Body), "cannot use %s as return value of type %s", body, ret)
+	}
+	return &Function{Params: params, Return: ret}
+}
+
+func endsWithReturn(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ReturnStatement)
+	return ok
+}
+
+func (c *checker) call(call *ast.CallExpression, s *resolver.Scope) Type {
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		switch ident.Value {
+		case "quote":
+			// quote の中はデータなので検査しない
+			return Quote
+		case "unquote", "unquote_splice":
+			return Any
+		}
+		if c.binding(ident) == nil {
+			if newSignature, ok := builtinSignatures[ident.Value]; ok {
+				return c.builtinCall(call, ident.Value, newSignature(c.fresh), s)
+			}
+		}
+	}
+
+	callee := c.expression(call.Function, s)
+	if fn, ok := prune(callee).(*Function); ok && len(fn.Params) == len(call.Arguments) {
+		for i, arg := range call.Arguments {
+			t := c.argument(arg, fn.Params[i], s)
+			if !c.unify(fn.Params[i], t) {
+				c.typeErrorf(startPos(arg), "cannot use %s as %s in argument %d to %s",
+					t, fn.Params[i], i+1, calleeName(call))
+				return Any
+			}
+		}
+		return fn.Return
+	}
+
+	args := make([]Type, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		args[i] = c.expression(arg, s)
+	}
+	switch fn := prune(callee).(type) {
+	case *Function:
+		// f(f(x)) のように、引数を検査する間に呼び出す側の型が関数に決まることがある
+		if len(fn.Params) == len(args) {
+			for i, arg := range call.Arguments {
+				if !c.unify(fn.Params[i], args[i]) {
+					c.typeErrorf(startPos(arg), "cannot use %s as %s in argument %d to %s",
+						args[i], fn.Params[i], i+1, calleeName(call))
+					return Any
+				}
+			}
+			return fn.Return
+		}
+		c.errorf(call.Token.Pos, "wrong number of arguments to %s. got=%d, want=%d", calleeName(call), len(args), len(fn.Params))
+		return Any
+	case *Variable:
+		ret := c.fresh()
+		if !c.unify(fn, &Function{Params: args, Return: ret}) {
+			return Any
+		}
+		return ret
+	case *Basic:
+		if fn == Any {
+			return Any
+		}
+	}
+	c.errorf(call.Token.Pos, "not a function: %s", TypeString(callee))
+	return Any
+}
+
+// argument は呼び出しの引数を検査する。関数リテラルには受け取る側が期待する型を伝える
+func (c *checker) argument(arg ast.Expression, param Type, s *resolver.Scope) Type {
+	if fl, ok := arg.(*ast.FunctionLiteral); ok {
+		if expected, ok := prune(param).(*Function); ok {
+			return c.function(fl, expected)
+		}
+	}
+	return c.expression(arg, s)
+}
+
+func (c *checker) builtinCall(call *ast.CallExpression, name string, sig signature, s *resolver.Scope) Type {
+	if n := len(call.Arguments); n < sig.Required || (sig.Rest == nil && n > len(sig.Params)) {
+		for _, arg := range call.Arguments {
+			c.expression(arg, s)
+		}
+		want := fmt.Sprint(len(sig.Params))
+		switch {
+		case sig.Rest != nil:
+			want = fmt.Sprintf("%d or more", sig.Required)
+		case sig.Required != len(sig.Params):
+			want = fmt.Sprintf("%d to %d", sig.Required, len(sig.Params))
+		}
+		c.errorf(call.Token.Pos, "wrong number of arguments to `%s`. got=%d, want=%s", name, len(call.Arguments), want)
+		return Any
+	}
+
+	// chosen は配列と文字列のどちらも受け取る引数に、実際に渡された型
+	chosen := make(map[*seq]Type)
+	for i, arg := range call.Arguments {
+		param := sig.Rest
+		if i < len(sig.Params) {
+			param = sig.Params[i]
+		}
+		var t Type
+		switch p := param.(type) {
+		case *seq:
+			// 配列と文字列のどちらも受け取る引数は、渡された型に合わせる
+			t = c.expression(arg, s)
+			if prune(t) == String {
+				param = String
+				c.unify(p.Elem, String)
+			} else {
+				param = &Array{Elem: p.Elem}
+			}
+			chosen[p] = param
+		case *oneOf:
+			t = c.expression(arg, s)
+			param = c.choose(p, t)
+		default:
+			t = c.argument(arg, param, s)
+		}
+		if !c.unify(param, t) {
+			c.typeErrorf(startPos(arg), "cannot use %s as %s in argument %d to `%s`",
+				t, param, i+1, name)
+			return Any
+		}
+	}
+	// 配列なら配列を、文字列なら文字列を返す組み込み関数もある
+	if sq, ok := sig.Return.(*seq); ok {
+		return chosen[sq]
+	}
+	return sig.Return
+}
+
+// choose は alts の型のうち t と単一化できるものを返す。t の型がまだ分からなければ t をそのまま返し、
+// どれとも単一化できなければ alts を返す
+func (c *checker) choose(alts *oneOf, t Type) Type {
+	if _, ok := prune(t).(*Variable); ok || prune(t) == Any {
+		return t
+	}
+	for _, alt := range alts.Types {
+		if c.unify(alt, t) {
+			return alt
+		}
+	}
+	return alts
+}
+
+func calleeName(call *ast.CallExpression) string {
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		return ident.Value
+	}
+	return "function"
+}
+
+func (c *checker) index(exp *ast.IndexExpression, s *resolver.Scope) Type {
+	left := c.expression(exp.Left, s)
+	index := c.expression(exp.Index, s)
+
+	switch l := prune(left).(type) {
+	case *Array:
+		if !c.unify(index, Int) {
+			c.typeErrorf(startPos(exp.Index), "cannot use %s as int in index of %s", index, left)
+			return Any
+		}
+		return l.Elem
+	case *Hash:
+		if !c.unify(index, l.Key) {
+			c.typeErrorf(startPos(exp.Index), "cannot use %s as %s in index of %s", index, l.Key, left)
+			return Any
+		}
+		return l.Value
+	case *Variable:
+		return Any
+	case *Basic:
+		switch l {
+		case Any:
+			return Any
+		case String:
+			if !c.unify(index, Int) {
+				c.errorf(startPos(exp.Index), "cannot use %s as int in index of string", TypeString(index))
+				return Any
+			}
+			return String
+		}
+	}
+	c.errorf(exp.Token.Pos, "index operator not supported: %s", TypeString(left))
+	return Any
+}
+
+func (c *checker) slice(exp *ast.SliceExpression, s *resolver.Scope) Type {
+	left := c.expression(exp.Left, s)
+	for _, bound := range []ast.Expression{exp.Low, exp.High} {
+		if bound == nil {
+			continue
+		}
+		if t := c.expression(bound, s); !c.unify(t, Int) {
+			c.errorf(startPos(bound), "cannot use %s as int in slice bound", TypeString(t))
+		}
+	}
+
+	switch l := prune(left).(type) {
+	case *Array, *Variable:
+		return l
+	case *Basic:
+		if l == String || l == Any {
+			return l
+		}
+	}
+	c.errorf(exp.Token.Pos, "slice operator not supported: %s", TypeString(left))
+	return Any
+}
+
+func (c *checker) hash(exp *ast.HashLiteral, s *resolver.Scope) Type {
+	key, value := c.fresh(), c.fresh()
+	for _, pair := range exp.Pairs {
+		k := c.expression(pair.Key, s)
+		switch pruned := prune(k).(type) {
+		case *Variable:
+		case *Basic:
+			if pruned == Int || pruned == String || pruned == Bool || pruned == Any {
+				break
+			}
+			c.errorf(startPos(pair.Key), "unusable as hash key: %s", TypeString(k))
+		default:
+			c.errorf(startPos(pair.Key), "unusable as hash key: %s", TypeString(k))
+		}
+		if !c.unify(key, k) {
+			key = Any
+		}
+		if !c.unify(value, c.expression(pair.Value, s)) {
+			value = Any
+		}
+	}
+	return &Hash{Key: key, Value: value}
+}
+
+// startPos は式の先頭のトークンの位置を返す
+func startPos(exp ast.Expression) token.Position {
+	for {
+		switch e := exp.(type) {
+		case *ast.InfixExpression:
+			exp = e.Left
+		case *ast.CallExpression:
+			exp = e.Function
+		case *ast.IndexExpression:
+			exp = e.Left
+		case *ast.SliceExpression:
+			exp = e.Left
+		case *ast.Identifier:
+			return e.Token.Pos
+		case *ast.IntegerLiteral:
+			return e.Token.Pos
+		case *ast.Boolean:
+			return e.Token.Pos
+		case *ast.StringLiteral:
+			return e.Token.Pos
+		case *ast.PrefixExpression:
+			return e.Token.Pos
+		case *ast.IfExpression:
+			return e.Token.Pos
+		case *ast.FunctionLiteral:
+			return e.Token.Pos
+		case *ast.MacroLiteral:
+			return e.Token.Pos
+		case *ast.ArrayLiteral:
+			return e.Token.Pos
+		case *ast.HashLiteral:
+			return e.Token.Pos
+		default:
+			return token.Position{}
+		}
+	}
+}
+
+// lastPos はブロックの最後の文の位置を返す
+func lastPos(block *ast.BlockStatement) token.Position {
+	if len(block.Statements) == 0 {
+		return block.Token.Pos
+	}
+	if es, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement); ok {
+		return startPos(es.Expression)
+	}
+	return block.Token.Pos
+}
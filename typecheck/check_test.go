@@ -0,0 +1,135 @@
+package typecheck
+
+import (
+	"github.com/care0717/monkey-interpreter/ast"
+	"github.com/care0717/monkey-interpreter/lexer"
+	"github.com/care0717/monkey-interpreter/parser"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+)
+
+func TestCheckBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let a = 1; let b = "s"; let c = true;`, []string{"a: int", "b: string", "c: bool"}},
+		{`let xs = [1, 2]; let h = {"a": [true]};`, []string{"xs: [int]", "h: {string: [bool]}"}},
+		{`let add = fn(a, b) { a - b };`, []string{"add: fn(int, int) -> int"}},
+		{`let concat = fn(a, b) { a + b };`, []string{"concat: fn(a, a) -> a"}},
+		{`let greet = fn(name) { "hello " + name };`, []string{"greet: fn(string) -> string"}},
+		// let で束縛した関数は呼び出しごとに型を変えられる
+		{`let id = fn(x) { x }; let n = id(1); let s = id("s");`, []string{"id: fn(a) -> a", "n: int", "s: string"}},
+		{`let compose = fn(f, g) { fn(x) { f(g(x)) } };`, []string{"compose: fn(fn(a) -> b, fn(c) -> a) -> fn(c) -> b"}},
+		{`let twice = fn(f) { fn(x) { f(f(x)) } };`, []string{"twice: fn(fn(a) -> a) -> fn(a) -> a"}},
+		{`let h = fn(f) { f(f(1)) };`, []string{"h: fn(fn(int) -> int) -> int"}},
+		// 再帰呼び出しと、定義より前に書かれた参照
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } };`, []string{"fact: fn(int) -> int"}},
+		{`let f = fn() { g() }; let g = fn() { 1 };`, []string{"f: fn() -> int", "g: fn() -> int"}},
+		// 同じ関数の中で let より前に書かれた参照は外側の名前を指す
+		{`let x = 1; let f = fn() { let x = x + 1; x };`, []string{"x: int", "f: fn() -> int"}},
+		{`let x = 1; let g = fn() { let y = x; let x = "a"; y };`, []string{"x: int", "g: fn() -> int"}},
+		{`let f = fn(x) { if (x) { return 1; } 2 };`, []string{"f: fn(a) -> int"}},
+		// 組み込み関数
+		{`let xs = map([1, 2], fn(x) { x * 2 });`, []string{"xs: [int]"}},
+		{`let cs = map("abc", upper);`, []string{"cs: [string]"}},
+		{`let n = reduce([1, 2], 0, fn(acc, x) { acc + x });`, []string{"n: int"}},
+		{`let ks = keys({1: "a"}); let s = rest("abc");`, []string{"ks: [int]", "s: string"}},
+		{`let l = len; let f = format;`, []string{"l: fn(any) -> int", "f: any"}},
+		{`let a = len([1]); let b = len("ab"); let c = len({1: 2});`, []string{"a: int", "b: int", "c: int"}},
+		{`let xs = rest([1, 2]); let r = rest;`, []string{"xs: [int]", "r: fn([a]) -> [a]"}},
+		{`let f = fn(x) { to_int(x) }; let n = to_int(true) + to_int("1");`, []string{"f: fn(a) -> int", "n: int"}},
+		// 型の揃わない値と、定義の見えない名前は any
+		{`let xs = [1, "a"];`, []string{"xs: [any]"}},
+		{`let v = if (c) { 1 } else { "a" };`, []string{"v: any"}},
+		{`let v = unknown(1);`, []string{"v: any"}},
+		{`import { m } from "m"; let v = m + 1;`, []string{"v: int"}},
+		{`let q = quote(1 + "a");`, []string{"q: quote"}},
+		{`let v = [1, 2][0]; let s = "abc"[1:]; let h = {"a": 1}["a"];`, []string{"v: int", "s: string", "h: int"}},
+		{`export let n = 1;`, []string{"n: int"}},
+		{`let f = fn() { let n = 1; n };`, []string{"f: fn() -> int"}},
+		// 型の注釈
+		{`let id = fn(x: int) { x };`, []string{"id: fn(int) -> int"}},
+		{`let f = fn(x) -> string { x };`, []string{"f: fn(string) -> string"}},
+		{`let xs: [any] = []; let v: any = 1;`, []string{"xs: [any]", "v: any"}},
+		{`let f: fn(int) -> int = fn(x) { x }; let g = fn() { h(1) }; let h: fn(int) -> bool = fn(x) { true };`,
+			[]string{"f: fn(int) -> int", "g: fn() -> bool", "h: fn(int) -> bool"}},
+	}
+
+	for _, tt := range tests {
+		bindings, errors := Check(testParseProgram(t, tt.input))
+		if len(errors) != 0 {
+			t.Errorf("case: %s. unexpected errors: %v", tt.input, errors)
+		}
+		got := make([]string, len(bindings))
+		for i, b := range bindings {
+			got[i] = b.String()
+		}
+		if diff := cmp.Diff(tt.expected, got); diff != "" {
+			t.Errorf("case: %s. bindings mismatch (-want +got):\n%s", tt.input, diff)
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`"a" - 1`, []string{"1:5: type mismatch: string - int"}},
+		{`"a" * "b"`, []string{"1:5: unknown operator: string * string"}},
+		{`"a" == "b"`, []string{"1:5: unknown operator: string == string"}},
+		{`1 + true`, []string{"1:3: type mismatch: int + bool"}},
+		{`[1] + [2]`, []string{"1:5: unknown operator: [int] + [int]"}},
+		{`-"a"`, []string{"1:1: unknown operator: -string"}},
+		{`let f = fn(x) { x * 2 }; f("a")`, []string{`1:28: cannot use string as int in argument 1 to f`}},
+		{`let f = fn(x) { x }; f(1, 2)`, []string{"1:23: wrong number of arguments to f. got=2, want=1"}},
+		{`5()`, []string{"1:2: not a function: int"}},
+		{`upper(1)`, []string{"1:7: cannot use int as string in argument 1 to `upper`"}},
+		{`len()`, []string{"1:4: wrong number of arguments to `len`. got=0, want=1"}},
+		{`len(5)`, []string{"1:5: cannot use int as [a] | string | {b: c} in argument 1 to `len`"}},
+		{`rest(5)`, []string{"1:6: cannot use int as [a] in argument 1 to `rest`"}},
+		{`rest("abc") + 1`, []string{"1:13: type mismatch: string + int"}},
+		{`to_int([1])`, []string{"1:8: cannot use [int] as int | string | bool in argument 1 to `to_int`"}},
+		{`substr("a")`, []string{"1:7: wrong number of arguments to `substr`. got=1, want=2 to 3"}},
+		{`format()`, []string{"1:7: wrong number of arguments to `format`. got=0, want=1 or more"}},
+		{`map([1], fn(x) { x + "a" })`, []string{"1:20: type mismatch: int + string"}},
+		{`map([1], fn(x, y) { x })`, []string{"1:10: cannot use fn(a, b) -> a as fn(int) -> c in argument 2 to `map`"}},
+		{`[1, 2]["a"]`, []string{`1:8: cannot use string as int in index of [int]`}},
+		{`1[0]`, []string{"1:2: index operator not supported: int"}},
+		{`"abc"[true:]`, []string{"1:7: cannot use bool as int in slice bound"}},
+		{`{[1]: 2}`, []string{"1:2: unusable as hash key: [int]"}},
+		{`let f = fn(x) { if (x) { return 1; } "a" };`, []string{"1:38: cannot use string as return value of type int"}},
+		// 誤りのある式は any になり、誤りを重ねて報告しない
+		{`let a = "a" - 1; a * 2`, []string{"1:13: type mismatch: string - int"}},
+		{"let a = 1;\nlet b = a + \"s\";", []string{`2:11: type mismatch: int + string`}},
+		// 型の注釈
+		{`let n: string = 1;`, []string{"1:5: cannot use int as string in let n"}},
+		{`let xs: [int] = ["a"];`, []string{"1:5: cannot use [string] as [int] in let xs"}},
+		{`let f = fn(x: int) { x }; f("a")`, []string{"1:29: cannot use string as int in argument 1 to f"}},
+		{`let f = fn(x: string) { x * 2 };`, []string{"1:27: type mismatch: string * int"}},
+		{`let f = fn() -> bool { 1 };`, []string{"1:24: cannot use int as return value of type bool"}},
+		{`map([1], fn(x: string) { x })`, []string{"1:10: cannot use fn(string) -> string as fn(int) -> a in argument 2 to `map`"}},
+	}
+
+	for _, tt := range tests {
+		_, errors := Check(testParseProgram(t, tt.input))
+		got := make([]string, len(errors))
+		for i, e := range errors {
+			got[i] = e.String()
+		}
+		if diff := cmp.Diff(tt.expected, got); diff != "" {
+			t.Errorf("case: %s. errors mismatch (-want +got):\n%s", tt.input, diff)
+		}
+	}
+}
+
+func testParseProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("case: %s. parser errors: %v", input, p.Errors())
+	}
+	return program
+}
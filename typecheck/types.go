@@ -0,0 +1,181 @@
+package typecheck
+
+import (
+	"fmt"
+	"github.com/care0717/monkey-interpreter/ast"
+	"strings"
+)
+
+// Type は検査器が推論する型
+type Type interface {
+	typ()
+}
+
+// Basic は int や string などの中身を持たない型
+type Basic struct {
+	Name string
+}
+
+var (
+	Int    = &Basic{Name: "int"}
+	Bool   = &Basic{Name: "bool"}
+	String = &Basic{Name: "string"}
+	Null   = &Basic{Name: "null"}
+	Quote  = &Basic{Name: "quote"}
+	// Any は型が分からない値。どの型とも単一化でき、何も制約しない。
+	// 要素の型が揃わない配列や、定義の見えない名前の型に使う
+	Any = &Basic{Name: "any"}
+)
+
+// Array は要素の型が Elem の配列
+type Array struct {
+	Elem Type
+}
+
+// Hash はキーの型が Key、値の型が Value のハッシュ
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+// Function は引数の型が Params、戻り値の型が Return の関数
+type Function struct {
+	Params []Type
+	Return Type
+}
+
+// Variable はまだ決まっていない型。単一化で Instance が決まる
+type Variable struct {
+	id       int
+	Instance Type
+}
+
+// seq は組み込み関数の引数だけに使う、要素の型が Elem の配列か文字列
+type seq struct {
+	Elem Type
+}
+
+// oneOf は組み込み関数の引数だけに使う、Types のどれかの型
+type oneOf struct {
+	Types []Type
+}
+
+func (*Basic) typ()    {}
+func (*Array) typ()    {}
+func (*Hash) typ()     {}
+func (*Function) typ() {}
+func (*Variable) typ() {}
+func (*seq) typ()      {}
+func (*oneOf) typ()    {}
+
+// fromAnnotation は型の注釈を型にする
+func fromAnnotation(t ast.TypeExpression) Type {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		for _, basic := range []*Basic{Int, Bool, String, Null, Quote, Any} {
+			if basic.Name == t.Name {
+				return basic
+			}
+		}
+	case *ast.ArrayType:
+		return &Array{Elem: fromAnnotation(t.Elem)}
+	case *ast.HashType:
+		return &Hash{Key: fromAnnotation(t.Key), Value: fromAnnotation(t.Value)}
+	case *ast.FunctionType:
+		params := make([]Type, len(t.Params))
+		for i, param := range t.Params {
+			params[i] = fromAnnotation(param)
+		}
+		return &Function{Params: params, Return: fromAnnotation(t.Return)}
+	}
+	return Any
+}
+
+// prune は決まっている型変数をたどり、その先の型を返す
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Variable)
+		if !ok || v.Instance == nil {
+			return t
+		}
+		t = v.Instance
+	}
+}
+
+// occursIn は型変数 v が t の中に現れるかを返す
+func occursIn(v *Variable, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Variable:
+		return t == v
+	case *Array:
+		return occursIn(v, t.Elem)
+	case *Hash:
+		return occursIn(v, t.Key) || occursIn(v, t.Value)
+	case *Function:
+		for _, param := range t.Params {
+			if occursIn(v, param) {
+				return true
+			}
+		}
+		return occursIn(v, t.Return)
+	case *seq:
+		return occursIn(v, t.Elem)
+	case *oneOf:
+		for _, alt := range t.Types {
+			if occursIn(v, alt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TypeString は t を読める形にする。型変数は現れた順に a, b, c ... と名前をつける
+func TypeString(t Type) string {
+	p := &printer{names: make(map[*Variable]string)}
+	return p.print(t)
+}
+
+type printer struct {
+	names map[*Variable]string
+}
+
+func (p *printer) print(t Type) string {
+	switch t := prune(t).(type) {
+	case *Basic:
+		return t.Name
+	case *Array:
+		return "[" + p.print(t.Elem) + "]"
+	case *Hash:
+		return "{" + p.print(t.Key) + ": " + p.print(t.Value) + "}"
+	case *Function:
+		params := make([]string, len(t.Params))
+		for i, param := range t.Params {
+			params[i] = p.print(param)
+		}
+		return "fn(" + strings.Join(params, ", ") + ") -> " + p.print(t.Return)
+	case *Variable:
+		name, ok := p.names[t]
+		if !ok {
+			name = variableName(len(p.names))
+			p.names[t] = name
+		}
+		return name
+	case *seq:
+		return "[" + p.print(t.Elem) + "] | string"
+	case *oneOf:
+		alts := make([]string, len(t.Types))
+		for i, alt := range t.Types {
+			alts[i] = p.print(alt)
+		}
+		return strings.Join(alts, " | ")
+	}
+	return "?"
+}
+
+func variableName(i int) string {
+	if i < 26 {
+		return string(rune('a' + i))
+	}
+	return fmt.Sprintf("t%d", i)
+}